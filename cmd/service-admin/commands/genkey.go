@@ -1,7 +1,7 @@
 package commands
 
 import (
-	"crypto/rand"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -9,15 +9,53 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
 )
 
-// GenKey creates an x509 private/public key for auth tokens.
-func GenKey() error {
+// GenKey creates an x509 private/public key for auth tokens signed with the
+// algorithm. RS256 is used if no algorithm is given.
+func GenKey(algorithm string) error {
+	if algorithm == "" {
+		algorithm = "RS256"
+	}
 
-	// Generate a new private key.
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	// Generate a new private key suited to the algorithm.
+	privateKey, err := auth.GenerateKey(algorithm)
 	if err != nil {
-		return ErrHelp
+		if errors.Cause(err) == auth.ErrUnsupportedAlgorithm {
+			fmt.Println("genkey: RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512 or EdDSA")
+			return ErrHelp
+		}
+		return errors.Wrap(err, "generating key")
+	}
+
+	// Construct a PEM block for the private key. RSA and ECDSA keys keep their
+	// traditional forms, Ed25519 keys only have a PKCS #8 one.
+	var privateBlock pem.Block
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		privateBlock = pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return errors.Wrap(err, "marshaling private key")
+		}
+		privateBlock = pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return errors.Wrap(err, "marshaling private key")
+		}
+		privateBlock = pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
 	}
 
 	// Create a file for the private key information in PEM form.
@@ -27,19 +65,13 @@ func GenKey() error {
 	}
 	defer privateFile.Close()
 
-	// Construct a PEM block for the private key.
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-
 	// Write the private key to the private key file.
 	if err := pem.Encode(privateFile, &privateBlock); err != nil {
 		return errors.Wrap(err, "encoding to private file")
 	}
 
 	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
 		return errors.Wrap(err, "marshaling public key")
 	}
@@ -49,19 +81,19 @@ func GenKey() error {
 	if err != nil {
 		return errors.Wrap(err, "creating public file")
 	}
-	defer privateFile.Close()
+	defer publicFile.Close()
 
 	// Construct a PEM block for the public key.
 	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  "PUBLIC KEY",
 		Bytes: asn1Bytes,
 	}
 
-	// Write the public key to the private key file.
+	// Write the public key to the public key file.
 	if err := pem.Encode(publicFile, &publicBlock); err != nil {
 		return errors.Wrap(err, "encoding to public file")
 	}
 
-	fmt.Println("private and public key files generated")
+	fmt.Printf("%s private and public key files generated\n", algorithm)
 	return nil
 }
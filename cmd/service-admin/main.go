@@ -94,14 +94,14 @@ func run(log *log.Logger) error {
 		}
 
 	case "genkey":
-		if err := commands.GenKey(); err != nil {
+		if err := commands.GenKey(cfg.Args.Num(1)); err != nil {
 			return errors.Wrap(err, "key generation")
 		}
 
 	default:
 		fmt.Println("migrate: create the schema in the database")
 		fmt.Println("seed: add data to the database")
-		fmt.Println("genkey: generate a set of private/public key files")
+		fmt.Println("genkey: generate a set of private/public key files, for RS256 unless an algorithm is given")
 		fmt.Println("provide a command to get more help.")
 		return commands.ErrHelp
 	}
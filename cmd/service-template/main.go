@@ -2,10 +2,9 @@ package main
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto/rand"
 	"expvar" // Register the expvar handlers
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	_ "net/http/pprof" // Register the pprof handlers
@@ -15,13 +14,14 @@ import (
 	"time"
 
 	"github.com/ardanlabs/conf"
-	"github.com/dgrijalva/jwt-go"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
 	"github.com/pkg/errors"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
 	"github.com/santiagoh1997/service-template/internal/auth"
 	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/pkg/cursor"
 	"github.com/santiagoh1997/service-template/internal/pkg/database"
+	"github.com/santiagoh1997/service-template/internal/pkg/mail"
 	"github.com/santiagoh1997/service-template/internal/repository"
 	"github.com/santiagoh1997/service-template/internal/service"
 	"go.opentelemetry.io/otel"
@@ -61,6 +61,7 @@ func run(log *log.Logger) error {
 			ReadTimeout     time.Duration `conf:"default:5s"`
 			WriteTimeout    time.Duration `conf:"default:5s"`
 			ShutdownTimeout time.Duration `conf:"default:5s"`
+			CursorKey       string        `conf:"noprint"`
 		}
 		DB struct {
 			User       string `conf:"default:postgres"`
@@ -69,10 +70,40 @@ func run(log *log.Logger) error {
 			Name       string `conf:"default:postgres"`
 			DisableTLS bool   `conf:"default:true"`
 		}
+		Users struct {
+			DeletedRetention time.Duration `conf:"default:720h"`
+			PurgeInterval    time.Duration `conf:"default:1h"`
+			ResetTokenTTL    time.Duration `conf:"default:1h"`
+			VerifyTokenTTL   time.Duration `conf:"default:24h"`
+			RequireVerified  bool          `conf:"default:false,help:reject tokens for users with unverified emails"`
+		}
+		Mail struct {
+			Driver   string `conf:"default:log,help:log or smtp"`
+			File     string `conf:"help:file the log driver writes to instead of the service log"`
+			Host     string
+			Port     int `conf:"default:587"`
+			Username string
+			Password string        `conf:"noprint"`
+			From     string        `conf:"default:noreply@example.com"`
+			Queue    int           `conf:"default:100,help:how many emails can wait to be sent"`
+			Timeout  time.Duration `conf:"default:10s,help:how long sending an email can take"`
+		}
 		Auth struct {
-			KeyID          string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
-			PrivateKeyFile string `conf:"default:/service/private.pem"`
-			Algorithm      string `conf:"default:RS256"`
+			Issuer          string        `conf:"default:http://localhost:3000,help:URL the service is reached at and tokens are issued by"`
+			KeyID           string        `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1,help:kid of the signing key unless the key directory has an active file"`
+			KeysDir         string        `conf:"default:/service/keys,help:directory of the PEM signing keys, named after their kid"`
+			KeysReload      time.Duration `conf:"default:1m,help:how often the key directory is reloaded"`
+			Algorithm       string        `conf:"default:RS256,help:RS*/PS*/ES*/EdDSA matching the type of the signing keys"`
+			PermissionsTTL  time.Duration `conf:"default:1m,help:how long role permissions are cached"`
+			PolicyFile      string        `conf:"help:JSON file with the rules of the authorization policy"`
+			LogDecisions    bool          `conf:"default:true,help:log every authorization decision"`
+			EmbedGroups     bool          `conf:"default:false,help:embed the groups of users in their tokens"`
+			ImpersonateTTL  time.Duration `conf:"default:15m,help:how long the tokens of impersonated sessions last"`
+			AccessTokenTTL  time.Duration `conf:"default:1h,help:how long access tokens last"`
+			RefreshTokenTTL time.Duration `conf:"default:720h,help:how long refresh tokens last since they were issued"`
+			AuthCodeTTL     time.Duration `conf:"default:10m,help:how long OAuth authorization codes last"`
+			Denylist        string        `conf:"default:postgres,help:memory or postgres, where revoked tokens are kept"`
+			AuditKey        string        `conf:"noprint,help:key signing the head of the chain of audit events"`
 		}
 		Zipkin struct {
 			ReporterURI string  `conf:"default:http://zipkin:9411/api/v2/spans"`
@@ -125,28 +156,40 @@ func run(log *log.Logger) error {
 
 	log.Println("main : Started : Initializing authentication support")
 
-	privatePEM, err := ioutil.ReadFile(cfg.Auth.PrivateKeyFile)
-	if err != nil {
-		return errors.Wrap(err, "reading auth private key")
+	// Rules in the policy file are evaluated along with the permissions
+	// granted by roles. Requests nothing allows are denied.
+	var policy auth.Policy
+	if cfg.Auth.PolicyFile != "" {
+		log.Printf("main : Loading authorization policy : %s", cfg.Auth.PolicyFile)
+		rules, err := auth.LoadPolicyFile(cfg.Auth.PolicyFile)
+		if err != nil {
+			return errors.Wrap(err, "loading authorization policy")
+		}
+		policy = rules
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+	authenticator, err := auth.New(cfg.Auth.Algorithm, nil, nil)
 	if err != nil {
-		return errors.Wrap(err, "parsing auth private key")
+		return errors.Wrap(err, "constructing auth")
 	}
 
-	lookup := func(kid string) (*rsa.PublicKey, error) {
-		switch kid {
-		case cfg.Auth.KeyID:
-			return &privateKey.PublicKey, nil
-		}
-		return nil, fmt.Errorf("no public key found for the specified kid: %s", kid)
+	// Keys removed from the directory keep verifying tokens until the ones
+	// they signed expire.
+	log.Printf("main : Loading signing keys : %s", cfg.Auth.KeysDir)
+	retireFor := cfg.Auth.AccessTokenTTL
+	if cfg.Auth.ImpersonateTTL > retireFor {
+		retireFor = cfg.Auth.ImpersonateTTL
 	}
-
-	auth, err := auth.New(cfg.Auth.Algorithm, lookup, auth.Keys{cfg.Auth.KeyID: privateKey})
+	keys, err := auth.NewKeyDirWatcher(authenticator, cfg.Auth.KeysDir, cfg.Auth.KeyID, log, cfg.Auth.KeysReload, retireFor)
 	if err != nil {
-		return errors.Wrap(err, "constructing auth")
+		return errors.Wrap(err, "creating key watcher")
 	}
+	if err := keys.Reload(time.Now()); err != nil {
+		return errors.Wrap(err, "loading signing keys")
+	}
+	keysCtx, stopKeys := context.WithCancel(context.Background())
+	defer stopKeys()
+	go keys.Run(keysCtx)
 
 	// =========================================================================
 	// Start Database
@@ -252,12 +295,111 @@ func run(log *log.Logger) error {
 	if err != nil {
 		return errors.Wrap(err, "creating repository")
 	}
-	us, err := service.New(ur, requestCount, requestLatency)
+
+	// Revoked tokens are only seen by every instance of the service when
+	// they're kept in the DB.
+	var denylist auth.Denylist
+	switch cfg.Auth.Denylist {
+	case "postgres":
+		if denylist, err = repository.NewDenylist(db); err != nil {
+			return errors.Wrap(err, "creating denylist")
+		}
+	case "memory":
+		denylist = auth.NewMemoryDenylist()
+	default:
+		return errors.Errorf("unknown denylist %q", cfg.Auth.Denylist)
+	}
+	// =========================================================================
+	// Initialize mail support
+
+	log.Printf("main: Initializing mail support : %s", cfg.Mail.Driver)
+
+	var mailer mail.Mailer
+	switch cfg.Mail.Driver {
+	case "smtp":
+		mailer, err = mail.NewSMTPMailer(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+		if err != nil {
+			return errors.Wrap(err, "creating smtp mailer")
+		}
+	case "log":
+		mailLog := log
+		if cfg.Mail.File != "" {
+			f, err := os.OpenFile(cfg.Mail.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
+				return errors.Wrap(err, "opening mail file")
+			}
+			defer f.Close()
+			mailLog = newFileLogger(f)
+		}
+		mailer = mail.NewLogMailer(mailLog)
+	default:
+		return errors.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+
+	// Without a key, events removed from the end of the audit chain along
+	// with its head can't be detected.
+	if cfg.Auth.AuditKey == "" {
+		log.Println("main: No audit key configured, the head of the audit chain won't be signed")
+	}
+
+	// Mail is sent in the background, so requests don't wait for it and
+	// callers can't tell from their timing whether it was sent.
+	worker, err := service.NewWorker(log, cfg.Mail.Queue, cfg.Mail.Timeout)
+	if err != nil {
+		return errors.Wrap(err, "creating worker")
+	}
+	go worker.Run()
+
+	scfg := service.Config{
+		Issuer:               cfg.Auth.Issuer,
+		AccessTokenTTL:       cfg.Auth.AccessTokenTTL,
+		RefreshTokenTTL:      cfg.Auth.RefreshTokenTTL,
+		AuthorizationCodeTTL: cfg.Auth.AuthCodeTTL,
+		ResetTokenTTL:        cfg.Users.ResetTokenTTL,
+		VerificationTokenTTL: cfg.Users.VerifyTokenTTL,
+		RequireVerifiedEmail: cfg.Users.RequireVerified,
+		ImpersonationTTL:     cfg.Auth.ImpersonateTTL,
+		PermissionsTTL:       cfg.Auth.PermissionsTTL,
+		Policy:               policy,
+		Denylist:             denylist,
+		EmbedGroups:          cfg.Auth.EmbedGroups,
+		Log:                  log,
+		Worker:               worker,
+		AuditKey:             []byte(cfg.Auth.AuditKey),
+	}
+	if cfg.Auth.LogDecisions {
+		scfg.DecisionLog = log
+	}
+	us, err := service.New(ur, mailer, scfg, requestCount, requestLatency)
 	if err != nil {
 		return errors.Wrap(err, "creating service")
 	}
 
-	handler := handlers.NewHTTPHandler(build, shutdown, us, log, errorCount, redMetrics, auth, db)
+	// Deleted users are kept for the retention period so they can be restored.
+	purger, err := service.NewPurger(ur, log, cfg.Users.DeletedRetention, cfg.Users.PurgeInterval)
+	if err != nil {
+		return errors.Wrap(err, "creating purger")
+	}
+	purgeCtx, stopPurger := context.WithCancel(context.Background())
+	defer stopPurger()
+	go purger.Run(purgeCtx)
+
+	// Pagination cursors are signed so clients can't tamper with them. Without a
+	// configured key a random one is used, which won't be shared across replicas.
+	cursorKey := []byte(cfg.Web.CursorKey)
+	if len(cursorKey) == 0 {
+		log.Println("main: No cursor key configured, generating a random one")
+		cursorKey = make([]byte, 32)
+		if _, err := rand.Read(cursorKey); err != nil {
+			return errors.Wrap(err, "generating cursor key")
+		}
+	}
+	cursors, err := cursor.NewSigner(cursorKey)
+	if err != nil {
+		return errors.Wrap(err, "creating cursor signer")
+	}
+
+	handler := handlers.NewHTTPHandler(build, shutdown, us, log, errorCount, redMetrics, authenticator, denylist, db, cursors, cfg.Auth.Issuer)
 
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
@@ -296,7 +438,18 @@ func run(log *log.Logger) error {
 			api.Close()
 			return errors.Wrap(err, "could not stop server gracefully")
 		}
+
+		// Sending the mail of the requests that were served.
+		if err := worker.Shutdown(ctx); err != nil {
+			return errors.Wrap(err, "could not send pending mail")
+		}
 	}
 
 	return nil
 }
+
+// newFileLogger constructs a logger writing to f. It exists because run
+// shadows the log package with the service logger.
+func newFileLogger(f *os.File) *log.Logger {
+	return log.New(f, "", log.LstdFlags)
+}
@@ -1,19 +1,34 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
 	"sync"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
 )
 
-// These are the expected values for Claims.Roles.
+// These are the built-in values for Claims.Roles. Other roles can be
+// defined at runtime.
 const (
-	RoleAdmin = "ADMIN"
-	RoleUser  = "USER"
+	RoleSuperAdmin = "SUPERADMIN"
+	RoleAdmin      = "ADMIN"
+	RoleUser       = "USER"
 )
 
+// DefaultRole is the role given to users that sign up by themselves.
+const DefaultRole = RoleUser
+
+// ErrTokenRevoked is returned when a token is correctly signed and not expired
+// but it's not valid anymore, for example because the user changed their password.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// ClaimsValidator defines the signature of a function that performs additional
+// checks on the claims of a valid token.
+type ClaimsValidator func(ctx context.Context, claims Claims) error
+
 // ctxKey represents the type of value for the context key.
 type ctxKey int
 
@@ -21,9 +36,49 @@ type ctxKey int
 const Key ctxKey = 1
 
 // Claims represents the authorization claims transmitted via a JWT.
+// TenantID is the organization the user belongs to. Groups holds the IDs of
+// the groups of the user, when they're embedded in the token. Actor is only
+// set on the tokens of impersonated sessions. ClientID and Scope are only set
+// on the tokens issued to OAuth clients, Scope holding the space separated
+// scopes the user consented to, which limit the permissions of the token.
+// Machine is set on the tokens of service accounts, whose Subject is the
+// service account rather than a user and whose Scope holds the permissions
+// granted to the token instead of roles.
+// IssuedAtMicro holds the issue time in microseconds so tokens can be told
+// apart from changes made in the same second.
 type Claims struct {
 	jwt.StandardClaims
-	Roles []string `json:"roles"`
+	TenantID      string   `json:"tenant_id,omitempty"`
+	Roles         []string `json:"roles"`
+	Groups        []string `json:"groups,omitempty"`
+	EmailVerified bool     `json:"email_verified"`
+	Actor         *Actor   `json:"act,omitempty"`
+	ClientID      string   `json:"client_id,omitempty"`
+	Scope         string   `json:"scope,omitempty"`
+	Machine       bool     `json:"machine,omitempty"`
+	IssuedAtMicro int64    `json:"iat_us,omitempty"`
+}
+
+// Actor identifies the user acting on behalf of the subject of a token, as
+// the act claim defined by RFC 8693.
+type Actor struct {
+	Subject  string `json:"sub"`
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// Impersonated reports whether the claims belong to a session in which
+// someone else is acting as the subject.
+func (c Claims) Impersonated() bool {
+	return c.Actor != nil
+}
+
+// IssuedBefore reports whether the token was issued before t. Tokens without
+// IssuedAtMicro are compared in seconds.
+func (c Claims) IssuedBefore(t time.Time) bool {
+	if c.IssuedAtMicro == 0 {
+		return c.IssuedAt < t.Unix()
+	}
+	return c.IssuedAtMicro < t.UnixNano()/int64(time.Microsecond)
 }
 
 // Authorized returns true if the claims has at least one of the provided roles.
@@ -38,11 +93,13 @@ func (c Claims) Authorized(roles ...string) bool {
 	return false
 }
 
-// Keys represents an in memory store of keys.
-type Keys map[string]*rsa.PrivateKey
+// Keys represents an in memory store of keys. They must be RSA keys for the
+// RS* and PS* algorithms, ECDSA keys for ES* and Ed25519 keys for EdDSA.
+type Keys map[string]crypto.Signer
 
 // PublicKeyLookup defines the signature of a function to lookup public keys.
-type PublicKeyLookup func(kid string) (*rsa.PublicKey, error)
+// The keys must be of the same type as the public part of Keys.
+type PublicKeyLookup func(kid string) (crypto.PublicKey, error)
 
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
@@ -53,25 +110,25 @@ type Auth struct {
 	keyFunc   func(t *jwt.Token) (interface{}, error)
 	parser    *jwt.Parser
 	keys      Keys
+	retired   map[string]retiredKey
+	active    string
 }
 
-// New creates an *Authenticator for use.
+// New creates an *Authenticator for use. Tokens are verified with the keys
+// of the Auth, including the retired ones, and lookup is only used for keys
+// it doesn't know about. lookup can be nil.
 func New(algorithm string, lookup PublicKeyLookup, keys Keys) (*Auth, error) {
 	method := jwt.GetSigningMethod(algorithm)
 	if method == nil {
 		return nil, errors.Errorf("unknown algorithm %v", algorithm)
 	}
-
-	keyFunc := func(t *jwt.Token) (interface{}, error) {
-		kid, ok := t.Header["kid"]
-		if !ok {
-			return nil, errors.New("missing key id (kid) in token header")
-		}
-		kidID, ok := kid.(string)
-		if !ok {
-			return nil, errors.New("user token key id (kid) must be string")
+	if !supported(method) {
+		return nil, errors.Errorf("unsupported algorithm %v", algorithm)
+	}
+	for kid, privateKey := range keys {
+		if err := checkKey(method, privateKey); err != nil {
+			return nil, errors.Wrapf(err, "key %s", kid)
 		}
-		return lookup(kidID)
 	}
 
 	// Create the token parser to use. The algorithm used to sign the JWT must be
@@ -80,22 +137,67 @@ func New(algorithm string, lookup PublicKeyLookup, keys Keys) (*Auth, error) {
 		ValidMethods: []string{algorithm},
 	}
 
+	if keys == nil {
+		keys = make(Keys)
+	}
+
 	a := Auth{
 		algorithm: algorithm,
 		method:    method,
-		keyFunc:   keyFunc,
 		parser:    &parser,
 		keys:      keys,
+		retired:   make(map[string]retiredKey),
+	}
+
+	a.keyFunc = func(t *jwt.Token) (interface{}, error) {
+		kid, ok := t.Header["kid"]
+		if !ok {
+			return nil, errors.New("missing key id (kid) in token header")
+		}
+		kidID, ok := kid.(string)
+		if !ok {
+			return nil, errors.New("user token key id (kid) must be string")
+		}
+		if key, ok := a.publicKey(kidID); ok {
+			return key, nil
+		}
+		if lookup == nil {
+			return nil, errors.Errorf("no public key found for the specified kid: %s", kidID)
+		}
+		return lookup(kidID)
 	}
 
 	return &a, nil
 }
 
+// publicKey returns the public key of one of the keys of the Auth, as long
+// as it's not a retired key whose tokens have expired.
+func (a *Auth) publicKey(kid string) (crypto.PublicKey, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if privateKey, ok := a.keys[kid]; ok {
+		return privateKey.Public(), true
+	}
+	if rk, ok := a.retired[kid]; ok && time.Now().Before(rk.until) {
+		return rk.key, true
+	}
+	return nil, false
+}
+
 // AddKey adds a private key and combination kid id to our local store.
-func (a *Auth) AddKey(privateKey *rsa.PrivateKey, kid string) {
+// Retired keys added again are no longer retired. The key must suit the
+// algorithm of the Auth.
+func (a *Auth) AddKey(privateKey crypto.Signer, kid string) error {
+	if err := checkKey(a.method, privateKey); err != nil {
+		return errors.Wrapf(err, "key %s", kid)
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.keys[kid] = privateKey
+	delete(a.retired, kid)
+	return nil
 }
 
 // RemoveKey removes a private key and combination kid id to our local store.
@@ -103,30 +205,32 @@ func (a *Auth) RemoveKey(kid string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	delete(a.keys, kid)
+	delete(a.retired, kid)
 }
 
-// GenerateToken generates a signed JWT token string representing the user Claims.
-func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = kid
-
-	var privateKey *rsa.PrivateKey
+// ActiveKID returns the kid of the key tokens are signed with by default.
+func (a *Auth) ActiveKID() string {
 	a.mu.RLock()
-	{
-		var ok bool
-		privateKey, ok = a.keys[kid]
-		if !ok {
-			return "", errors.New("kid lookup failed")
-		}
-	}
-	a.mu.RUnlock()
+	defer a.mu.RUnlock()
+	return a.active
+}
 
-	str, err := token.SignedString(privateKey)
-	if err != nil {
-		return "", errors.Wrap(err, "signing token")
+// SetActiveKID sets the key tokens are signed with by default. The key must
+// be one of the keys of the Auth that isn't retired.
+func (a *Auth) SetActiveKID(kid string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.keys[kid]; !ok {
+		return errors.Errorf("unknown key id (kid) %q", kid)
 	}
+	a.active = kid
+	return nil
+}
 
-	return str, nil
+// GenerateToken generates a signed JWT token string representing the user Claims.
+func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
+	return a.sign(kid, claims)
 }
 
 // ValidateToken recreates the Claims that were used to generate a token. It
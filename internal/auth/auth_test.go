@@ -1,6 +1,7 @@
 package auth_test
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -32,7 +33,7 @@ func TestAuth(t *testing.T) {
 			// The key id we are stating represents the public key in the
 			// public key store.
 			const keyID = "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
-			lookup := func(kid string) (*rsa.PublicKey, error) {
+			lookup := func(kid string) (crypto.PublicKey, error) {
 				switch kid {
 				case keyID:
 					return &privateKey.PublicKey, nil
@@ -85,3 +86,25 @@ func TestAuth(t *testing.T) {
 		}
 	}
 }
+
+func TestIssuedBefore(t *testing.T) {
+	issued := time.Date(2018, time.October, 1, 0, 0, 0, 500000000, time.UTC)
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{IssuedAt: issued.Unix()},
+		IssuedAtMicro:  issued.UnixNano() / int64(time.Microsecond),
+	}
+
+	if !claims.IssuedBefore(issued.Add(time.Millisecond)) {
+		t.Fatalf("\t%s\tShould be issued before a change later in the same second.", failed)
+	}
+	if claims.IssuedBefore(issued.Add(-time.Millisecond)) {
+		t.Fatalf("\t%s\tShould not be issued before a change earlier in the same second.", failed)
+	}
+	t.Logf("\t%s\tShould compare the issue time in microseconds.", success)
+
+	claims.IssuedAtMicro = 0
+	if claims.IssuedBefore(issued.Add(time.Millisecond)) || !claims.IssuedBefore(issued.Add(time.Second)) {
+		t.Fatalf("\t%s\tShould compare tokens without microseconds in seconds.", failed)
+	}
+	t.Logf("\t%s\tShould compare tokens without microseconds in seconds.", success)
+}
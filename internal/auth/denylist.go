@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Denylist keeps track of tokens revoked before they expire. Revocations
+// only need to be kept until the tokens they apply to have expired.
+type Denylist interface {
+	// Revoke revokes the token with the given ID (jti).
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+
+	// RevokeSubject revokes every token of the subject issued up to the
+	// given time. expiresAt is when the last of them expires.
+	RevokeSubject(ctx context.Context, subject string, issuedBefore, expiresAt time.Time) error
+
+	// Revoked reports whether the token of the claims was revoked.
+	Revoked(ctx context.Context, claims Claims) (bool, error)
+}
+
+// IssuedBy reports whether the claims were issued up to the given time. The
+// issue time of tokens only has a precision of seconds, so tokens issued
+// during the same second are included as well.
+func IssuedBy(claims Claims, t time.Time) bool {
+	return claims.IssuedAt <= t.Unix()
+}
+
+// MemoryDenylist is a Denylist that keeps revocations in memory. It's only
+// suitable for a single instance of the service.
+type MemoryDenylist struct {
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	subjects map[string]subjectRevocation
+}
+
+// subjectRevocation is the revocation of the tokens of a subject.
+type subjectRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// NewMemoryDenylist constructs an empty MemoryDenylist.
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]subjectRevocation),
+	}
+}
+
+// Revoke implements the Denylist interface.
+func (d *MemoryDenylist) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep(time.Now())
+	d.tokens[tokenID] = expiresAt
+	return nil
+}
+
+// RevokeSubject implements the Denylist interface. Revoking the tokens of a
+// subject again extends the previous revocation.
+func (d *MemoryDenylist) RevokeSubject(ctx context.Context, subject string, issuedBefore, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep(time.Now())
+	rev := d.subjects[subject]
+	if issuedBefore.After(rev.issuedBefore) {
+		rev.issuedBefore = issuedBefore
+	}
+	if expiresAt.After(rev.expiresAt) {
+		rev.expiresAt = expiresAt
+	}
+	d.subjects[subject] = rev
+	return nil
+}
+
+// Revoked implements the Denylist interface.
+func (d *MemoryDenylist) Revoked(ctx context.Context, claims Claims) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := d.tokens[claims.Id]; ok && claims.Id != "" && now.Before(expiresAt) {
+		return true, nil
+	}
+	if rev, ok := d.subjects[claims.Subject]; ok && now.Before(rev.expiresAt) && IssuedBy(claims, rev.issuedBefore) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// sweep discards the revocations that expired.
+func (d *MemoryDenylist) sweep(now time.Time) {
+	for id, expiresAt := range d.tokens {
+		if !now.Before(expiresAt) {
+			delete(d.tokens, id)
+		}
+	}
+	for subject, rev := range d.subjects {
+		if !now.Before(rev.expiresAt) {
+			delete(d.subjects, subject)
+		}
+	}
+}
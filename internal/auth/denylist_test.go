@@ -0,0 +1,61 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+func TestMemoryDenylist(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	claims := func(id, subject string, issuedAt time.Time) auth.Claims {
+		return auth.Claims{
+			StandardClaims: jwt.StandardClaims{
+				Id:       id,
+				Subject:  subject,
+				IssuedAt: issuedAt.Unix(),
+			},
+		}
+	}
+
+	d := auth.NewMemoryDenylist()
+	if err := d.Revoke(ctx, "revoked", now.Add(time.Hour)); err != nil {
+		t.Fatalf("\t%s\tRevoke() err = %v, want %v", failed, err, nil)
+	}
+	if err := d.Revoke(ctx, "expired", now.Add(-time.Second)); err != nil {
+		t.Fatalf("\t%s\tRevoke() err = %v, want %v", failed, err, nil)
+	}
+	if err := d.RevokeSubject(ctx, "subject", now, now.Add(time.Hour)); err != nil {
+		t.Fatalf("\t%s\tRevokeSubject() err = %v, want %v", failed, err, nil)
+	}
+
+	tt := []struct {
+		name   string
+		claims auth.Claims
+		want   bool
+	}{
+		{"Revoked token", claims("revoked", "other", now), true},
+		{"Expired revocation", claims("expired", "other", now), false},
+		{"Token without ID", claims("", "other", now), false},
+		{"Token of revoked subject", claims("a", "subject", now.Add(-time.Minute)), true},
+		{"Token issued after revocation", claims("b", "subject", now.Add(time.Minute)), false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := d.Revoked(ctx, tc.claims)
+			if err != nil {
+				t.Fatalf("\t%s\tRevoked() err = %v, want %v", failed, err, nil)
+			}
+			if got != tc.want {
+				t.Fatalf("\t%s\tRevoked() = %v, want %v", failed, got, tc.want)
+			}
+			t.Logf("\t%s\tShould get the expected result.", success)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// JWK is the public part of a signing key as a JSON Web Key (RFC 7517). N
+// and E are only set for RSA keys, and Crv, X and Y for ECDSA (RFC 7518) and
+// Ed25519 (RFC 8037) keys, the latter without Y.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// retiredKey is a key that doesn't sign tokens anymore but is still
+// published until the tokens it signed expire.
+type retiredKey struct {
+	key   crypto.PublicKey
+	until time.Time
+}
+
+// RetireKey stops signing tokens with a key. The public key keeps being
+// published until the given time, by when every token signed with it must
+// have expired.
+func (a *Auth) RetireKey(kid string, until time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	privateKey, ok := a.keys[kid]
+	if !ok {
+		return
+	}
+	delete(a.keys, kid)
+	a.retired[kid] = retiredKey{key: privateKey.Public(), until: until}
+}
+
+// JWKS returns the public keys tokens are currently signed with along with
+// the retired ones whose tokens may not have expired yet, sorted by kid.
+func (a *Auth) JWKS() JWKS {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	set := JWKS{Keys: []JWK{}}
+	for kid, privateKey := range a.keys {
+		set.Keys = append(set.Keys, a.jwk(kid, privateKey.Public()))
+	}
+	for kid, rk := range a.retired {
+		if !now.Before(rk.until) {
+			delete(a.retired, kid)
+			continue
+		}
+		set.Keys = append(set.Keys, a.jwk(kid, rk.key))
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+// jwk returns the JWK of a public key.
+func (a *Auth) jwk(kid string, key crypto.PublicKey) JWK {
+	k := JWK{
+		Kid: kid,
+		Alg: a.algorithm,
+		Use: "sig",
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// The coordinates are padded to the size of the curve.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		k.Kty = "EC"
+		k.Crv = key.Curve.Params().Name
+		k.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		k.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		k.Kty = "OKP"
+		k.Crv = "Ed25519"
+		k.X = base64.RawURLEncoding.EncodeToString(key)
+	}
+
+	return k
+}
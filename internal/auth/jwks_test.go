@@ -0,0 +1,64 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+func TestJWKS(t *testing.T) {
+	keys := make(map[string]*rsa.PrivateKey)
+	for _, kid := range []string{"active", "retired", "expired"} {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a private key: %v", failed, err)
+		}
+		keys[kid] = privateKey
+	}
+	lookup := func(kid string) (crypto.PublicKey, error) {
+		return &keys[kid].PublicKey, nil
+	}
+
+	a, err := auth.New("RS256", lookup, auth.Keys{"active": keys["active"], "retired": keys["retired"], "expired": keys["expired"]})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create an authenticator: %v", failed, err)
+	}
+	a.RetireKey("retired", time.Now().Add(time.Hour))
+	a.RetireKey("expired", time.Now().Add(-time.Second))
+
+	if _, err := a.GenerateToken("retired", auth.Claims{}); err == nil {
+		t.Fatalf("\t%s\tShould not sign tokens with retired keys.", failed)
+	}
+	t.Logf("\t%s\tShould not sign tokens with retired keys.", success)
+
+	set := a.JWKS()
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "active" || set.Keys[1].Kid != "retired" {
+		t.Fatalf("\t%s\tShould publish the active and retired keys : got %+v", failed, set.Keys)
+	}
+	t.Logf("\t%s\tShould publish the active and retired keys.", success)
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || k.Alg != "RS256" || k.Use != "sig" {
+			t.Fatalf("\t%s\tShould describe the key : got %+v", failed, k)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			t.Fatalf("\t%s\tShould encode the modulus : %v", failed, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			t.Fatalf("\t%s\tShould encode the exponent : %v", failed, err)
+		}
+		pub := keys[k.Kid].PublicKey
+		if new(big.Int).SetBytes(n).Cmp(pub.N) != 0 || int(new(big.Int).SetBytes(e).Int64()) != pub.E {
+			t.Fatalf("\t%s\tShould publish the public key of %s.", failed, k.Kid)
+		}
+	}
+	t.Logf("\t%s\tShould publish the public keys.", success)
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// keyExt is the extension of the files of a key directory holding keys.
+const keyExt = ".pem"
+
+// activeFile is the name of the optional file of a key directory holding
+// the kid of the key tokens are signed with.
+const activeFile = "active"
+
+// LoadKeyDir reads every PEM encoded private key of a directory. The kid
+// of each key is the name of its file without the .pem extension. The kid in
+// the active file of the directory is returned as well, if there's one.
+func LoadKeyDir(dir string) (Keys, string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading key directory")
+	}
+
+	keys := make(Keys)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != keyExt {
+			continue
+		}
+
+		privatePEM, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "reading key %s", f.Name())
+		}
+		privateKey, err := ParsePrivateKeyPEM(privatePEM)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "parsing key %s", f.Name())
+		}
+
+		keys[strings.TrimSuffix(f.Name(), keyExt)] = privateKey
+	}
+
+	active, err := ioutil.ReadFile(filepath.Join(dir, activeFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", errors.Wrap(err, "reading active key id")
+	}
+
+	return keys, strings.TrimSpace(string(active)), nil
+}
+
+// KeyDirWatcher keeps the keys of an Auth in sync with a key directory. Keys
+// added to the directory are added to the Auth, and the ones removed from it
+// are retired so the tokens they signed can still be verified until they
+// expire. The active key is the one in the active file of the directory, or
+// the default one when there's no such file.
+type KeyDirWatcher struct {
+	auth          *Auth
+	dir           string
+	defaultActive string
+	log           *log.Logger
+	interval      time.Duration
+	retireFor     time.Duration
+	loaded        Keys
+}
+
+// NewKeyDirWatcher constructs a KeyDirWatcher. retireFor must be at least
+// as long as the longest lived tokens.
+func NewKeyDirWatcher(a *Auth, dir, defaultActive string, log *log.Logger, interval, retireFor time.Duration) (*KeyDirWatcher, error) {
+	if a == nil {
+		return nil, errors.New("auth can't be nil")
+	}
+	if log == nil {
+		return nil, errors.New("log can't be nil")
+	}
+	if interval <= 0 || retireFor <= 0 {
+		return nil, errors.New("interval and retireFor must be positive")
+	}
+
+	return &KeyDirWatcher{
+		auth:          a,
+		dir:           dir,
+		defaultActive: defaultActive,
+		log:           log,
+		interval:      interval,
+		retireFor:     retireFor,
+		loaded:        make(Keys),
+	}, nil
+}
+
+// Reload syncs the keys of the Auth with the directory. Nothing changes if
+// the directory can't be read, the active key isn't in it or one of its keys
+// doesn't suit the algorithm of the Auth.
+func (w *KeyDirWatcher) Reload(now time.Time) error {
+	keys, active, err := LoadKeyDir(w.dir)
+	if err != nil {
+		return err
+	}
+	if active == "" {
+		active = w.defaultActive
+	}
+	if _, ok := keys[active]; !ok {
+		return errors.Errorf("active key %q not found in key directory", active)
+	}
+	for kid, privateKey := range keys {
+		if err := checkKey(w.auth.method, privateKey); err != nil {
+			return errors.Wrapf(err, "key %s", kid)
+		}
+	}
+
+	for kid, privateKey := range keys {
+		if loaded, ok := w.loaded[kid]; !ok || !equalKeys(loaded, privateKey) {
+			if err := w.auth.AddKey(privateKey, kid); err != nil {
+				return err
+			}
+			w.log.Printf("keys: loaded key %s", kid)
+		}
+	}
+	if err := w.auth.SetActiveKID(active); err != nil {
+		return err
+	}
+	for kid := range w.loaded {
+		if _, ok := keys[kid]; !ok {
+			w.auth.RetireKey(kid, now.Add(w.retireFor))
+			w.log.Printf("keys: retired key %s", kid)
+		}
+	}
+
+	w.loaded = keys
+	return nil
+}
+
+// equalKeys reports whether two private keys are the same. Every private key
+// of the standard library has an Equal method.
+func equalKeys(x, y crypto.Signer) bool {
+	k, ok := x.(interface{ Equal(crypto.PrivateKey) bool })
+	return ok && k.Equal(y)
+}
+
+// Run reloads the keys on every interval until the context is cancelled.
+func (w *KeyDirWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := w.Reload(now); err != nil {
+				w.log.Printf("keys: ERROR: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+func TestKeyDirWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create the key directory: %v", failed, err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeKey := func(kid string) {
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a private key: %v", failed, err)
+		}
+		block := pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(&block), 0600); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the key: %v", failed, err)
+		}
+	}
+	writeKey("old")
+
+	a, err := auth.New("RS256", nil, nil)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create an authenticator: %v", failed, err)
+	}
+	w, err := auth.NewKeyDirWatcher(a, dir, "old", log.New(ioutil.Discard, "", 0), time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a watcher: %v", failed, err)
+	}
+	if err := w.Reload(time.Now()); err != nil {
+		t.Fatalf("\t%s\tShould be able to load the keys: %v", failed, err)
+	}
+	if a.ActiveKID() != "old" {
+		t.Fatalf("\t%s\tShould sign with the default key : got %q", failed, a.ActiveKID())
+	}
+	t.Logf("\t%s\tShould sign with the default key.", success)
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+	token, err := a.GenerateToken(a.ActiveKID(), claims)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to generate a token: %v", failed, err)
+	}
+
+	// Rotate to a new key and remove the old one.
+	writeKey("new")
+	if err := ioutil.WriteFile(filepath.Join(dir, "active"), []byte("new\n"), 0600); err != nil {
+		t.Fatalf("\t%s\tShould be able to write the active file: %v", failed, err)
+	}
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatalf("\t%s\tShould be able to remove the key: %v", failed, err)
+	}
+	if err := w.Reload(time.Now()); err != nil {
+		t.Fatalf("\t%s\tShould be able to reload the keys: %v", failed, err)
+	}
+	if a.ActiveKID() != "new" {
+		t.Fatalf("\t%s\tShould sign with the key of the active file : got %q", failed, a.ActiveKID())
+	}
+	t.Logf("\t%s\tShould sign with the key of the active file.", success)
+
+	if _, err := a.GenerateToken("old", claims); err == nil {
+		t.Fatalf("\t%s\tShould not sign with removed keys.", failed)
+	}
+	if _, err := a.ValidateToken(token); err != nil {
+		t.Fatalf("\t%s\tShould verify the tokens of retired keys: %v", failed, err)
+	}
+	if set := a.JWKS(); len(set.Keys) != 2 {
+		t.Fatalf("\t%s\tShould publish the retired key : got %+v", failed, set.Keys)
+	}
+	t.Logf("\t%s\tShould retire removed keys.", success)
+
+	if err := os.Remove(filepath.Join(dir, "new.pem")); err != nil {
+		t.Fatalf("\t%s\tShould be able to remove the key: %v", failed, err)
+	}
+	if err := w.Reload(time.Now()); err == nil || a.ActiveKID() != "new" {
+		t.Fatalf("\t%s\tShould keep the keys when the active one is missing.", failed)
+	}
+	t.Logf("\t%s\tShould keep the keys when the active one is missing.", success)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// ErrEdDSAVerification is returned when the signature of a token signed with
+// an Ed25519 key is invalid.
+var ErrEdDSAVerification = errors.New("ed25519: verification error")
+
+// ErrUnsupportedAlgorithm is returned when keys are generated for an
+// algorithm tokens can't be signed with.
+var ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+
+// SigningMethodEdDSA signs tokens with Ed25519 keys, as the EdDSA algorithm
+// of RFC 8037, which jwt-go doesn't implement.
+type SigningMethodEdDSA struct{}
+
+// SigningMethodEd25519 is the EdDSA signing method, registered as "EdDSA".
+var SigningMethodEd25519 = &SigningMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEd25519.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEd25519
+	})
+}
+
+// Alg implements the jwt.SigningMethod interface.
+func (m *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+// Verify implements the jwt.SigningMethod interface. The key must be an
+// ed25519.PublicKey.
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return ErrEdDSAVerification
+	}
+	return nil
+}
+
+// Sign implements the jwt.SigningMethod interface. The key must be an
+// ed25519.PrivateKey.
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// supported reports whether tokens can be signed with the method using the
+// keys of an Auth. HMAC and none are left out on purpose: the keys of an Auth
+// are asymmetric so the public ones can be published.
+func supported(method jwt.SigningMethod) bool {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *SigningMethodEdDSA:
+		return true
+	}
+	return false
+}
+
+// checkKey returns an error if tokens can't be signed with the method using
+// the key. ECDSA keys must be on the curve of the method, so P-256 for ES256.
+func checkKey(method jwt.SigningMethod, key crypto.Signer) error {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := key.(*rsa.PrivateKey); ok {
+			return nil
+		}
+		return errors.Errorf("%s requires an RSA key, got %T", method.Alg(), key)
+	case *jwt.SigningMethodECDSA:
+		if k, ok := key.(*ecdsa.PrivateKey); ok && k.Curve.Params().BitSize == m.CurveBits {
+			return nil
+		}
+		return errors.Errorf("%s requires an ECDSA key on a %d bits curve", m.Alg(), m.CurveBits)
+	case *SigningMethodEdDSA:
+		if _, ok := key.(ed25519.PrivateKey); ok {
+			return nil
+		}
+		return errors.Errorf("%s requires an Ed25519 key, got %T", m.Alg(), key)
+	}
+	return errors.Errorf("unsupported algorithm %v", method.Alg())
+}
+
+// GenerateKey generates a private key tokens can be signed with using the
+// algorithm.
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil || !supported(method) {
+		return nil, errors.Wrap(ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	switch m := method.(type) {
+	case *jwt.SigningMethodECDSA:
+		var curve elliptic.Curve
+		switch m.CurveBits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			curve = elliptic.P521()
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case *SigningMethodEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded private key. RSA keys can be in
+// PKCS #1 form, ECDSA keys in SEC 1 form, and any of them in PKCS #8 form,
+// which is the only one Ed25519 keys have.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key must be PEM encoded")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	return nil, errors.Errorf("unsupported PEM block type %q", block.Type)
+}
@@ -0,0 +1,90 @@
+package auth_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+func TestKeyTypes(t *testing.T) {
+	tt := []struct {
+		algorithm string
+		kty       string
+		crv       string
+	}{
+		{"RS256", "RSA", ""},
+		{"PS384", "RSA", ""},
+		{"ES256", "EC", "P-256"},
+		{"ES384", "EC", "P-384"},
+		{"ES512", "EC", "P-521"},
+		{"EdDSA", "OKP", "Ed25519"},
+	}
+
+	for _, tc := range tt {
+		privateKey, err := auth.GenerateKey(tc.algorithm)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a %s key: %v", failed, tc.algorithm, err)
+		}
+
+		// Keys must survive being stored in a key directory.
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to marshal the %s key: %v", failed, tc.algorithm, err)
+		}
+		privateKey, err = auth.ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to parse the %s key: %v", failed, tc.algorithm, err)
+		}
+
+		a, err := auth.New(tc.algorithm, nil, auth.Keys{"kid": privateKey})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a %s authenticator: %v", failed, tc.algorithm, err)
+		}
+
+		claims := auth.Claims{
+			StandardClaims: jwt.StandardClaims{
+				Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+				ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			},
+		}
+		token, err := a.GenerateToken("kid", claims)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to sign a %s token: %v", failed, tc.algorithm, err)
+		}
+		parsed, err := a.ValidateToken(token)
+		if err != nil || parsed.Subject != claims.Subject {
+			t.Fatalf("\t%s\tShould be able to verify a %s token: %v", failed, tc.algorithm, err)
+		}
+
+		set := a.JWKS()
+		if len(set.Keys) != 1 || set.Keys[0].Kty != tc.kty || set.Keys[0].Crv != tc.crv || set.Keys[0].Alg != tc.algorithm {
+			t.Fatalf("\t%s\tShould publish the %s key : got %+v", failed, tc.algorithm, set.Keys)
+		}
+		t.Logf("\t%s\tShould sign and verify %s tokens.", success, tc.algorithm)
+	}
+
+	ecKey, err := auth.GenerateKey("ES384")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a key: %v", failed, err)
+	}
+	if _, err := auth.New("ES256", nil, auth.Keys{"kid": ecKey}); err == nil {
+		t.Fatalf("\t%s\tShould reject keys on the wrong curve.", failed)
+	}
+	if _, err := auth.New("RS256", nil, auth.Keys{"kid": ecKey}); err == nil {
+		t.Fatalf("\t%s\tShould reject keys of the wrong type.", failed)
+	}
+	if _, err := auth.New("HS256", nil, nil); err == nil {
+		t.Fatalf("\t%s\tShould reject symmetric algorithms.", failed)
+	}
+	t.Logf("\t%s\tShould reject keys that don't suit the algorithm.", success)
+
+	if _, err := auth.GenerateKey("HS256"); errors.Cause(err) != auth.ErrUnsupportedAlgorithm {
+		t.Fatalf("\t%s\tShould not generate keys for symmetric algorithms : %v", failed, err)
+	}
+	t.Logf("\t%s\tShould not generate keys for unsupported algorithms.", success)
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// These are the scopes defined by OpenID Connect. ScopeOpenID asks for an ID
+// token, the rest for the claims of the User they're named after.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopeAddress = "address"
+)
+
+// Profile holds the standard claims describing a User (OpenID Connect Core
+// section 5.1). Only the claims of the scopes granted to a client are set.
+type Profile struct {
+	Name          string   `json:"name,omitempty"`
+	GivenName     string   `json:"given_name,omitempty"`
+	FamilyName    string   `json:"family_name,omitempty"`
+	UpdatedAt     int64    `json:"updated_at,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	Address       *Address `json:"address,omitempty"`
+}
+
+// Address is the postal address of a User.
+type Address struct {
+	Country string `json:"country,omitempty"`
+}
+
+// IDClaims represents the claims of an OpenID Connect ID token, which tells
+// a client who the User that signed in is. AuthTime is when the User
+// entered their credentials and Nonce is the value the client sent along
+// with its authorization request.
+type IDClaims struct {
+	jwt.StandardClaims
+	AuthTime int64  `json:"auth_time"`
+	Nonce    string `json:"nonce,omitempty"`
+	Profile
+}
+
+// UserInfo is the response of the UserInfo endpoint.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	Profile
+}
+
+// Algorithm returns the algorithm tokens are signed with.
+func (a *Auth) Algorithm() string {
+	return a.algorithm
+}
+
+// GenerateIDToken generates a signed ID token for the claims.
+func (a *Auth) GenerateIDToken(kid string, claims IDClaims) (string, error) {
+	return a.sign(kid, claims)
+}
+
+// sign returns the JWT of the claims, signed with the key of kid.
+func (a *Auth) sign(kid string, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(a.method, claims)
+	token.Header["kid"] = kid
+
+	a.mu.RLock()
+	privateKey, ok := a.keys[kid]
+	a.mu.RUnlock()
+	if !ok {
+		return "", errors.New("kid lookup failed")
+	}
+
+	str, err := token.SignedString(privateKey)
+	if err != nil {
+		return "", errors.Wrap(err, "signing token")
+	}
+
+	return str, nil
+}
@@ -0,0 +1,61 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+func TestIDToken(t *testing.T) {
+	privateKey, err := auth.GenerateKey("ES256")
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a key: %v", failed, err)
+	}
+	a, err := auth.New("ES256", nil, auth.Keys{"kid": privateKey})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create an authenticator: %v", failed, err)
+	}
+
+	verified := true
+	claims := auth.IDClaims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+			Audience:  "7b0ad5b8-5c54-4b1c-9a45-7c0a8fd5f3a1",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		AuthTime: time.Now().Unix(),
+		Nonce:    "n-0S6_WzA2Mj",
+		Profile: auth.Profile{
+			Email:         "user@example.com",
+			EmailVerified: &verified,
+		},
+	}
+	token, err := a.GenerateIDToken("kid", claims)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to sign an ID token: %v", failed, err)
+	}
+	if _, err := a.ValidateToken(token); err != nil {
+		t.Fatalf("\t%s\tShould be able to verify an ID token: %v", failed, err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to decode the ID token: %v", failed, err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("\t%s\tShould be able to unmarshal the ID token: %v", failed, err)
+	}
+	if fields["sub"] != claims.Subject || fields["nonce"] != claims.Nonce || fields["email"] != "user@example.com" || fields["email_verified"] != true {
+		t.Fatalf("\t%s\tShould carry the claims flattened. Received: %v", failed, fields)
+	}
+	if _, ok := fields["name"]; ok {
+		t.Fatalf("\t%s\tShould leave out the claims that aren't set. Received: %v", failed, fields)
+	}
+	t.Logf("\t%s\tShould sign ID tokens.", success)
+}
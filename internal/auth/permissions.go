@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// These are the permissions roles can grant. A permission with the self
+// scope grants the same access restricted to the user's own resources.
+// Permissions apply within the tenant of the user, unless tenants:all is
+// also granted.
+const (
+	PermUsersRead             = "users:read"
+	PermUsersWrite            = "users:write"
+	PermUsersDelete           = "users:delete"
+	PermUsersImpersonate      = "users:impersonate"
+	PermTokensRevoke          = "tokens:revoke"
+	PermRolesManage           = "roles:manage"
+	PermGroupsManage          = "groups:manage"
+	PermAuditRead             = "audit:read"
+	PermOrganizationsManage   = "organizations:manage"
+	PermClientsManage         = "clients:manage"
+	PermServiceAccountsManage = "service_accounts:manage"
+	PermTenantsAll            = "tenants:all"
+)
+
+// selfScope is the suffix of permissions restricted to the user's own resources.
+const selfScope = ":self"
+
+// Self returns the permission restricted to the user's own resources.
+func Self(perm string) string {
+	return perm + selfScope
+}
+
+// ValidPermission reports whether perm is one of the permissions known by the service.
+func ValidPermission(perm string) bool {
+	switch strings.TrimSuffix(perm, selfScope) {
+	case PermUsersRead, PermUsersWrite, PermUsersDelete, PermTokensRevoke:
+		return true
+	case PermUsersImpersonate, PermRolesManage, PermGroupsManage, PermAuditRead, PermOrganizationsManage, PermClientsManage, PermServiceAccountsManage, PermTenantsAll:
+		return !strings.HasSuffix(perm, selfScope)
+	}
+	return false
+}
+
+// ServiceAccountPermission reports whether perm can be granted to service
+// accounts. They don't own resources, so self scoped permissions are of no
+// use to them, and they can't impersonate users.
+func ServiceAccountPermission(perm string) bool {
+	return ValidPermission(perm) && !strings.HasSuffix(perm, selfScope) && perm != PermUsersImpersonate
+}
+
+// Permissions is the set of permissions granted to a user.
+type Permissions map[string]bool
+
+// Has reports whether the permission is granted without restrictions.
+func (p Permissions) Has(perm string) bool {
+	return p[perm]
+}
+
+// Allows reports whether the permission is granted for a resource owned by
+// ownerID to the given subject, either without restrictions or restricted
+// to the subject's own resources.
+func (p Permissions) Allows(perm, subject, ownerID string) bool {
+	if p[perm] {
+		return true
+	}
+	return ownerID != "" && subject == ownerID && p[Self(perm)]
+}
+
+// Includes reports whether every permission in other is also granted by p,
+// either with the same scope or without restrictions.
+func (p Permissions) Includes(other Permissions) bool {
+	for perm := range other {
+		if !p[perm] && !p[strings.TrimSuffix(perm, selfScope)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Within returns the permissions that are also granted by other, either
+// with the same scope or without restrictions.
+func (p Permissions) Within(other Permissions) Permissions {
+	within := make(Permissions)
+	for perm := range p {
+		if other.Includes(Permissions{perm: true}) {
+			within[perm] = true
+		}
+	}
+	return within
+}
+
+// ScopePermissions returns the permissions the tokens issued to OAuth
+// clients are limited to by their space separated scope. Scopes name the
+// permissions they cover, except for the openid scope, which covers reading
+// the profile of the user.
+func ScopePermissions(scope string) Permissions {
+	perms := make(Permissions)
+	for _, s := range strings.Fields(scope) {
+		switch s {
+		case ScopeOpenID:
+			perms[Self(PermUsersRead)] = true
+		default:
+			perms[s] = true
+		}
+	}
+	return perms
+}
+
+// PermissionsFunc defines the signature of a function that resolves the
+// permissions granted to the holder of a set of claims.
+type PermissionsFunc func(ctx context.Context, claims Claims) (Permissions, error)
+
+// RolesFunc defines the signature of a function that loads the permissions
+// granted by every role.
+type RolesFunc func(ctx context.Context) (map[string][]string, error)
+
+// Resolver resolves the permissions granted by roles. Roles are loaded all
+// at once and cached for a period of time.
+type Resolver struct {
+	load RolesFunc
+	ttl  time.Duration
+
+	mu      sync.RWMutex
+	roles   map[string][]string
+	expires time.Time
+}
+
+// NewResolver constructs a Resolver that caches the roles returned by load
+// for the given period of time.
+func NewResolver(load RolesFunc, ttl time.Duration) *Resolver {
+	return &Resolver{
+		load: load,
+		ttl:  ttl,
+	}
+}
+
+// Permissions returns the union of the permissions granted by the roles.
+// Unknown roles don't grant any permission.
+func (r *Resolver) Permissions(ctx context.Context, roles []string) (Permissions, error) {
+	all, err := r.cached(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make(Permissions)
+	for _, role := range roles {
+		for _, perm := range all[role] {
+			perms[perm] = true
+		}
+	}
+
+	return perms, nil
+}
+
+// Granted returns the permissions granted to the holder of the claims: the
+// ones of their roles, or the ones in the scope of the tokens of service
+// accounts. The tokens of OAuth clients only keep the permissions of the
+// roles the user consented to share with the client.
+func (r *Resolver) Granted(ctx context.Context, claims Claims) (Permissions, error) {
+	if !claims.Machine {
+		perms, err := r.Permissions(ctx, claims.Roles)
+		if err != nil || claims.ClientID == "" {
+			return perms, err
+		}
+		return perms.Within(ScopePermissions(claims.Scope)), nil
+	}
+
+	perms := make(Permissions)
+	for _, perm := range strings.Fields(claims.Scope) {
+		if ServiceAccountPermission(perm) {
+			perms[perm] = true
+		}
+	}
+	return perms, nil
+}
+
+// RoleExists reports whether the role is defined.
+func (r *Resolver) RoleExists(ctx context.Context, role string) (bool, error) {
+	all, err := r.cached(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := all[role]
+	return ok, nil
+}
+
+// Invalidate discards the cached roles so they are loaded again on next use.
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.roles = nil
+}
+
+// cached returns the cached roles, loading them if they expired.
+func (r *Resolver) cached(ctx context.Context) (map[string][]string, error) {
+	r.mu.RLock()
+	roles, expires := r.roles, r.expires
+	r.mu.RUnlock()
+
+	if roles != nil && time.Now().Before(expires) {
+		return roles, nil
+	}
+
+	roles, err := r.load(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading roles")
+	}
+
+	r.mu.Lock()
+	r.roles = roles
+	r.expires = time.Now().Add(r.ttl)
+	r.mu.Unlock()
+
+	return roles, nil
+}
@@ -0,0 +1,158 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+func TestPermissions(t *testing.T) {
+	perms := auth.Permissions{
+		auth.PermUsersRead:             true,
+		auth.Self(auth.PermUsersWrite): true,
+	}
+
+	tt := []struct {
+		name    string
+		perm    string
+		subject string
+		owner   string
+		want    bool
+	}{
+		{"Unrestricted permission", auth.PermUsersRead, "a", "b", true},
+		{"Self permission on own resource", auth.PermUsersWrite, "a", "a", true},
+		{"Self permission on other resource", auth.PermUsersWrite, "a", "b", false},
+		{"Self permission without owner", auth.PermUsersWrite, "a", "", false},
+		{"Missing permission", auth.PermUsersDelete, "a", "a", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := perms.Allows(tc.perm, tc.subject, tc.owner); got != tc.want {
+				t.Fatalf("\t%s\tAllows() = %v, want %v", failed, got, tc.want)
+			}
+			t.Logf("\t%s\tShould get the expected result.", success)
+		})
+	}
+}
+
+func TestResolver(t *testing.T) {
+	var loads int
+	roles := map[string][]string{
+		"ADMIN":  {auth.PermUsersRead, auth.PermUsersDelete},
+		"USER":   {auth.Self(auth.PermUsersRead)},
+		"EDITOR": {auth.PermUsersWrite},
+	}
+	load := func(ctx context.Context) (map[string][]string, error) {
+		loads++
+		return roles, nil
+	}
+
+	r := auth.NewResolver(load, time.Hour)
+	ctx := context.Background()
+
+	perms, err := r.Permissions(ctx, []string{"USER", "EDITOR", "UNKNOWN"})
+	if err != nil {
+		t.Fatalf("\t%s\tPermissions() err = %v, want %v", failed, err, nil)
+	}
+	want := auth.Permissions{auth.Self(auth.PermUsersRead): true, auth.PermUsersWrite: true}
+	if len(perms) != len(want) || !perms[auth.Self(auth.PermUsersRead)] || !perms[auth.PermUsersWrite] {
+		t.Fatalf("\t%s\tShould get the union of the permissions of the roles : got %v", failed, perms)
+	}
+	t.Logf("\t%s\tShould get the union of the permissions of the roles.", success)
+
+	if ok, _ := r.RoleExists(ctx, "EDITOR"); !ok {
+		t.Fatalf("\t%s\tShould find an existing role.", failed)
+	}
+	if ok, _ := r.RoleExists(ctx, "UNKNOWN"); ok {
+		t.Fatalf("\t%s\tShould not find an unknown role.", failed)
+	}
+	if loads != 1 {
+		t.Fatalf("\t%s\tShould cache the roles : loaded %d times", failed, loads)
+	}
+	t.Logf("\t%s\tShould cache the roles.", success)
+
+	r.Invalidate()
+	if _, err := r.Permissions(ctx, []string{"ADMIN"}); err != nil {
+		t.Fatalf("\t%s\tPermissions() err = %v, want %v", failed, err, nil)
+	}
+	if loads != 2 {
+		t.Fatalf("\t%s\tShould load the roles again after invalidating them : loaded %d times", failed, loads)
+	}
+	t.Logf("\t%s\tShould load the roles again after invalidating them.", success)
+
+	machine := auth.Claims{Roles: []string{"ADMIN"}, Scope: "users:read users:impersonate unknown", Machine: true}
+	perms, err = r.Granted(ctx, machine)
+	if err != nil {
+		t.Fatalf("\t%s\tGranted() err = %v, want %v", failed, err, nil)
+	}
+	if len(perms) != 1 || !perms[auth.PermUsersRead] {
+		t.Fatalf("\t%s\tShould only grant service accounts the permissions of their scope : got %v", failed, perms)
+	}
+	t.Logf("\t%s\tShould only grant service accounts the permissions of their scope.", success)
+
+	client := auth.Claims{Roles: []string{"ADMIN", "USER"}, ClientID: "client-id", Scope: "openid users:delete users:write"}
+	perms, err = r.Granted(ctx, client)
+	if err != nil {
+		t.Fatalf("\t%s\tGranted() err = %v, want %v", failed, err, nil)
+	}
+	if len(perms) != 2 || !perms[auth.PermUsersDelete] || !perms[auth.Self(auth.PermUsersRead)] {
+		t.Fatalf("\t%s\tShould only grant OAuth clients the permissions of the user in their scope : got %v", failed, perms)
+	}
+	t.Logf("\t%s\tShould only grant OAuth clients the permissions of the user in their scope.", success)
+}
+
+func TestValidPermission(t *testing.T) {
+	for _, perm := range []string{auth.PermUsersRead, auth.Self(auth.PermUsersDelete), auth.PermRolesManage, auth.PermGroupsManage, auth.Self(auth.PermTokensRevoke), auth.PermClientsManage, auth.PermServiceAccountsManage} {
+		if !auth.ValidPermission(perm) {
+			t.Fatalf("\t%s\tShould accept %q.", failed, perm)
+		}
+	}
+	for _, perm := range []string{"users", "users:admin", auth.Self(auth.PermRolesManage), auth.Self(auth.PermClientsManage), auth.Self(auth.PermServiceAccountsManage)} {
+		if auth.ValidPermission(perm) {
+			t.Fatalf("\t%s\tShould reject %q.", failed, perm)
+		}
+	}
+	t.Logf("\t%s\tShould only accept known permissions.", success)
+}
+
+func TestServiceAccountPermission(t *testing.T) {
+	for _, perm := range []string{auth.PermUsersRead, auth.PermTokensRevoke, auth.PermAuditRead} {
+		if !auth.ServiceAccountPermission(perm) {
+			t.Fatalf("\t%s\tShould accept %q.", failed, perm)
+		}
+	}
+	for _, perm := range []string{"users:admin", auth.Self(auth.PermUsersRead), auth.PermUsersImpersonate} {
+		if auth.ServiceAccountPermission(perm) {
+			t.Fatalf("\t%s\tShould reject %q.", failed, perm)
+		}
+	}
+	t.Logf("\t%s\tShould only accept the permissions service accounts can use.", success)
+}
+
+func TestPermissionsIncludes(t *testing.T) {
+	admin := auth.Permissions{auth.PermUsersRead: true, auth.PermUsersWrite: true}
+
+	tt := []struct {
+		name  string
+		other auth.Permissions
+		want  bool
+	}{
+		{"Same permissions", auth.Permissions{auth.PermUsersRead: true}, true},
+		{"Self scoped permissions", auth.Permissions{auth.Self(auth.PermUsersWrite): true}, true},
+		{"No permissions", auth.Permissions{}, true},
+		{"Missing permission", auth.Permissions{auth.PermUsersRead: true, auth.PermRolesManage: true}, false},
+		{"Missing self scoped permission", auth.Permissions{auth.Self(auth.PermUsersDelete): true}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := admin.Includes(tc.other); got != tc.want {
+				t.Fatalf("\t%s\tIncludes() = %v, want %v", failed, got, tc.want)
+			}
+			t.Logf("\t%s\tShould get the expected result.", success)
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Effect is the outcome of evaluating a Policy.
+type Effect int
+
+// These are the effects a Policy can have on a Request.
+const (
+	NotApplicable Effect = iota
+	Allow
+	Deny
+)
+
+// String implements the fmt.Stringer interface.
+func (e Effect) String() string {
+	switch e {
+	case Allow:
+		return "allow"
+	case Deny:
+		return "deny"
+	default:
+		return "not applicable"
+	}
+}
+
+// Attributes describe the subject or the resource of a Request.
+type Attributes map[string]string
+
+// These are the attributes the service sets on the resources of a Request.
+// The owner of a resource is the only one granted self scoped permissions
+// over it.
+const (
+	AttrType     = "type"
+	AttrID       = "id"
+	AttrOwnerID  = "owner_id"
+	AttrTenantID = "tenant_id"
+)
+
+// Request is what a Policy is evaluated with: the claims of the subject and
+// any attributes of it not present in the claims, the action being performed
+// and the attributes of the resource the action is performed on. TraceID
+// is only used to log the decision.
+type Request struct {
+	TraceID  string
+	Claims   Claims
+	Subject  Attributes
+	Action   string
+	Resource Attributes
+}
+
+// subject returns an attribute of the subject. The id and tenant_id
+// attributes are always the ones of the claims.
+func (r Request) subject(name string) string {
+	switch name {
+	case AttrID:
+		return r.Claims.Subject
+	case AttrTenantID:
+		return r.Claims.TenantID
+	}
+	return r.Subject[name]
+}
+
+// Decision is the result of evaluating a Policy. Reason identifies what
+// decided the effect, for logging purposes.
+type Decision struct {
+	Effect Effect
+	Reason string
+}
+
+// Policy decides whether a Request is allowed. Policies that have nothing
+// to say about a Request return NotApplicable.
+type Policy interface {
+	Evaluate(ctx context.Context, req Request) (Decision, error)
+}
+
+// Evaluator combines a set of policies. Any denial overrides the policies
+// allowing the Request and Requests no policy applies to are denied.
+type Evaluator struct {
+	log      *log.Logger
+	policies []Policy
+}
+
+// NewEvaluator constructs an Evaluator of the policies. Every decision is
+// written to log, unless it's nil.
+func NewEvaluator(log *log.Logger, policies ...Policy) *Evaluator {
+	return &Evaluator{
+		log:      log,
+		policies: policies,
+	}
+}
+
+// Evaluate implements the Policy interface. The effect is always either
+// Allow or Deny.
+func (e *Evaluator) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	d := Decision{Effect: Deny, Reason: "default"}
+
+	for _, p := range e.policies {
+		pd, err := p.Evaluate(ctx, req)
+		if err != nil {
+			return Decision{}, err
+		}
+		if pd.Effect == Deny {
+			d = pd
+			break
+		}
+		if pd.Effect == Allow && d.Effect != Allow {
+			d = pd
+		}
+	}
+
+	if e.log != nil {
+		e.log.Printf("%s : policy : %s : sub[%s] roles%v action[%s] resource%s : %s",
+			req.TraceID, d.Effect, req.Claims.Subject, req.Claims.Roles, req.Action, formatAttributes(req.Resource), d.Reason)
+	}
+
+	return d, nil
+}
+
+// formatAttributes returns the attributes sorted by name so decisions are
+// logged consistently.
+func formatAttributes(attrs Attributes) string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + attrs[name]
+	}
+	return "[" + strings.Join(pairs, " ") + "]"
+}
+
+// PermissionPolicy allows the actions granted as permissions by the roles of
+// the subject, or by the scope of the tokens of service accounts. Self scoped
+// permissions only apply to the resources the subject owns.
+type PermissionPolicy struct {
+	Resolver *Resolver
+}
+
+// Evaluate implements the Policy interface.
+func (p PermissionPolicy) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	perms, err := p.Resolver.Granted(ctx, req.Claims)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if !perms.Allows(req.Action, req.Claims.Subject, req.Resource[AttrOwnerID]) {
+		return Decision{}, nil
+	}
+	return Decision{Effect: Allow, Reason: "permission " + req.Action}, nil
+}
+
+// ScopePolicy denies the actions outside of the scope of the tokens issued
+// to OAuth clients, so no other policy can allow a client more than the
+// user consented to.
+type ScopePolicy struct{}
+
+// Evaluate implements the Policy interface.
+func (ScopePolicy) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	if req.Claims.ClientID == "" {
+		return Decision{}, nil
+	}
+
+	if !ScopePermissions(req.Claims.Scope).Allows(req.Action, req.Claims.Subject, req.Resource[AttrOwnerID]) {
+		return Decision{Effect: Deny, Reason: "scope " + req.Claims.Scope}, nil
+	}
+	return Decision{}, nil
+}
+
+// Condition matches a Request when a resource attribute is equal to a fixed
+// value or to an attribute of the subject. Missing attributes never match.
+type Condition struct {
+	Attribute     string `json:"attribute"`
+	Equals        string `json:"equals,omitempty"`
+	EqualsSubject string `json:"equals_subject,omitempty"`
+}
+
+func (c Condition) matches(req Request) bool {
+	got := req.Resource[c.Attribute]
+	if got == "" {
+		return false
+	}
+	if c.EqualsSubject != "" {
+		return got == req.subject(c.EqualsSubject)
+	}
+	return got == c.Equals
+}
+
+// Rule allows or denies some actions to the subjects holding any of the
+// roles, or to every subject when no role is listed, as long as all the
+// conditions match.
+type Rule struct {
+	Name       string      `json:"name"`
+	Effect     string      `json:"effect"`
+	Actions    []string    `json:"actions"`
+	Roles      []string    `json:"roles"`
+	Conditions []Condition `json:"conditions"`
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("rule without name")
+	}
+	if r.Effect != "allow" && r.Effect != "deny" {
+		return errors.Errorf("rule %q: unknown effect %q", r.Name, r.Effect)
+	}
+	if len(r.Actions) == 0 {
+		return errors.Errorf("rule %q: no actions", r.Name)
+	}
+	for _, c := range r.Conditions {
+		if c.Attribute == "" {
+			return errors.Errorf("rule %q: condition without attribute", r.Name)
+		}
+		if (c.Equals == "") == (c.EqualsSubject == "") {
+			return errors.Errorf("rule %q: condition on %q needs either equals or equals_subject", r.Name, c.Attribute)
+		}
+	}
+	return nil
+}
+
+func (r Rule) matches(req Request) bool {
+	if !contains(r.Actions, req.Action) {
+		return false
+	}
+	if len(r.Roles) > 0 && !req.Claims.Authorized(r.Roles...) {
+		return false
+	}
+	for _, c := range r.Conditions {
+		if !c.matches(req) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// RulePolicy is a Policy declared as a list of rules. Denying rules take
+// precedence over the ones allowing a Request. For example, this policy lets
+// support staff read the Users of their own country:
+//
+//	{
+//	  "rules": [{
+//	    "name": "support-reads-own-country",
+//	    "effect": "allow",
+//	    "actions": ["users:read"],
+//	    "roles": ["SUPPORT"],
+//	    "conditions": [{"attribute": "country", "equals_subject": "country"}]
+//	  }]
+//	}
+type RulePolicy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParsePolicy decodes and validates a RulePolicy from its JSON document.
+func ParsePolicy(r io.Reader) (*RulePolicy, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var p RulePolicy
+	if err := dec.Decode(&p); err != nil {
+		return nil, errors.Wrap(err, "decoding policy")
+	}
+
+	for _, rule := range p.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// LoadPolicyFile reads a RulePolicy from a JSON file.
+func LoadPolicyFile(path string) (*RulePolicy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening policy file")
+	}
+	defer f.Close()
+
+	return ParsePolicy(f)
+}
+
+// Evaluate implements the Policy interface.
+func (p *RulePolicy) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	var d Decision
+	for _, rule := range p.Rules {
+		if !rule.matches(req) {
+			continue
+		}
+		if rule.Effect == "deny" {
+			return Decision{Effect: Deny, Reason: "rule " + rule.Name}, nil
+		}
+		if d.Effect == NotApplicable {
+			d = Decision{Effect: Allow, Reason: "rule " + rule.Name}
+		}
+	}
+	return d, nil
+}
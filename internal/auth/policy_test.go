@@ -0,0 +1,192 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+const testPolicy = `{
+	"rules": [
+		{
+			"name": "support-reads-own-country",
+			"effect": "allow",
+			"actions": ["users:read"],
+			"roles": ["SUPPORT"],
+			"conditions": [{"attribute": "country", "equals_subject": "country"}]
+		},
+		{
+			"name": "nobody-deletes-admins",
+			"effect": "deny",
+			"actions": ["users:delete"],
+			"conditions": [{"attribute": "id", "equals": "admin-id"}]
+		}
+	]
+}`
+
+func TestRulePolicy(t *testing.T) {
+	p, err := auth.ParsePolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatalf("\t%s\tParsePolicy() err = %v, want %v", failed, err, nil)
+	}
+
+	support := auth.Claims{
+		StandardClaims: jwt.StandardClaims{Subject: "support-id"},
+		Roles:          []string{"SUPPORT"},
+	}
+
+	tt := []struct {
+		name string
+		req  auth.Request
+		want auth.Effect
+	}{
+		{
+			"Same country",
+			auth.Request{Claims: support, Subject: auth.Attributes{"country": "Argentina"}, Action: auth.PermUsersRead, Resource: auth.Attributes{"country": "Argentina"}},
+			auth.Allow,
+		},
+		{
+			"Other country",
+			auth.Request{Claims: support, Subject: auth.Attributes{"country": "Argentina"}, Action: auth.PermUsersRead, Resource: auth.Attributes{"country": "Chile"}},
+			auth.NotApplicable,
+		},
+		{
+			"Missing attributes",
+			auth.Request{Claims: support, Action: auth.PermUsersRead, Resource: auth.Attributes{}},
+			auth.NotApplicable,
+		},
+		{
+			"Other action",
+			auth.Request{Claims: support, Subject: auth.Attributes{"country": "Argentina"}, Action: auth.PermUsersWrite, Resource: auth.Attributes{"country": "Argentina"}},
+			auth.NotApplicable,
+		},
+		{
+			"Other role",
+			auth.Request{Claims: auth.Claims{Roles: []string{auth.RoleUser}}, Subject: auth.Attributes{"country": "Argentina"}, Action: auth.PermUsersRead, Resource: auth.Attributes{"country": "Argentina"}},
+			auth.NotApplicable,
+		},
+		{
+			"Denied",
+			auth.Request{Claims: support, Action: auth.PermUsersDelete, Resource: auth.Attributes{auth.AttrID: "admin-id"}},
+			auth.Deny,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := p.Evaluate(context.Background(), tc.req)
+			if err != nil {
+				t.Fatalf("\t%s\tEvaluate() err = %v, want %v", failed, err, nil)
+			}
+			if d.Effect != tc.want {
+				t.Fatalf("\t%s\tEvaluate() effect = %v, want %v", failed, d.Effect, tc.want)
+			}
+			t.Logf("\t%s\tShould get the expected effect.", success)
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tt := []struct {
+		name   string
+		policy string
+	}{
+		{"Unknown field", `{"rules":[{"name":"a","effect":"allow","actions":["users:read"],"role":"ADMIN"}]}`},
+		{"Unknown effect", `{"rules":[{"name":"a","effect":"permit","actions":["users:read"]}]}`},
+		{"Missing name", `{"rules":[{"effect":"allow","actions":["users:read"]}]}`},
+		{"Missing actions", `{"rules":[{"name":"a","effect":"allow"}]}`},
+		{"Ambiguous condition", `{"rules":[{"name":"a","effect":"allow","actions":["users:read"],"conditions":[{"attribute":"country"}]}]}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := auth.ParsePolicy(strings.NewReader(tc.policy)); err == nil {
+				t.Fatalf("\t%s\tShould reject the policy.", failed)
+			}
+			t.Logf("\t%s\tShould reject the policy.", success)
+		})
+	}
+}
+
+// staticPolicy is a Policy that always has the same effect.
+type staticPolicy auth.Effect
+
+func (p staticPolicy) Evaluate(ctx context.Context, req auth.Request) (auth.Decision, error) {
+	return auth.Decision{Effect: auth.Effect(p), Reason: auth.Effect(p).String()}, nil
+}
+
+func TestScopePolicy(t *testing.T) {
+	client := auth.Claims{
+		StandardClaims: jwt.StandardClaims{Subject: "user-id"},
+		Roles:          []string{auth.RoleAdmin},
+		ClientID:       "client-id",
+		Scope:          "openid users:delete",
+	}
+	user := client
+	user.ClientID, user.Scope = "", ""
+
+	tt := []struct {
+		name string
+		req  auth.Request
+		want auth.Effect
+	}{
+		{"In scope", auth.Request{Claims: client, Action: auth.PermUsersDelete}, auth.NotApplicable},
+		{"Own profile", auth.Request{Claims: client, Action: auth.PermUsersRead, Resource: auth.Attributes{auth.AttrOwnerID: "user-id"}}, auth.NotApplicable},
+		{"Other profile", auth.Request{Claims: client, Action: auth.PermUsersRead, Resource: auth.Attributes{auth.AttrOwnerID: "other-id"}}, auth.Deny},
+		{"Out of scope", auth.Request{Claims: client, Action: auth.PermRolesManage}, auth.Deny},
+		{"Not a client", auth.Request{Claims: user, Action: auth.PermRolesManage}, auth.NotApplicable},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := auth.ScopePolicy{}.Evaluate(context.Background(), tc.req)
+			if err != nil {
+				t.Fatalf("\t%s\tEvaluate() err = %v, want %v", failed, err, nil)
+			}
+			if d.Effect != tc.want {
+				t.Fatalf("\t%s\tEvaluate() effect = %v, want %v", failed, d.Effect, tc.want)
+			}
+			t.Logf("\t%s\tShould get the expected effect.", success)
+		})
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	tt := []struct {
+		name     string
+		policies []auth.Policy
+		want     auth.Effect
+	}{
+		{"No policies", nil, auth.Deny},
+		{"Not applicable", []auth.Policy{staticPolicy(auth.NotApplicable)}, auth.Deny},
+		{"Allowed", []auth.Policy{staticPolicy(auth.NotApplicable), staticPolicy(auth.Allow)}, auth.Allow},
+		{"Denial overrides", []auth.Policy{staticPolicy(auth.Allow), staticPolicy(auth.Deny)}, auth.Deny},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := auth.NewEvaluator(log.New(&buf, "", 0), tc.policies...)
+
+			req := auth.Request{TraceID: "trace", Action: auth.PermUsersRead, Resource: auth.Attributes{auth.AttrID: "42"}}
+			d, err := e.Evaluate(context.Background(), req)
+			if err != nil {
+				t.Fatalf("\t%s\tEvaluate() err = %v, want %v", failed, err, nil)
+			}
+			if d.Effect != tc.want {
+				t.Fatalf("\t%s\tEvaluate() effect = %v, want %v", failed, d.Effect, tc.want)
+			}
+			t.Logf("\t%s\tShould get the expected effect.", success)
+
+			if !strings.HasPrefix(buf.String(), "trace : policy : "+tc.want.String()) || !strings.Contains(buf.String(), "resource[id=42]") {
+				t.Fatalf("\t%s\tShould log the decision : got %q", failed, buf.String())
+			}
+			t.Logf("\t%s\tShould log the decision.", success)
+		})
+	}
+}
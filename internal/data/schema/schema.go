@@ -33,4 +33,367 @@ CREATE TABLE users (
 	PRIMARY KEY (user_id)
 );`,
 	},
+	{
+		Version:     1.2,
+		Description: "Add index to seek users by creation date",
+		Script: `
+CREATE INDEX users_date_created_user_id_idx ON users (date_created, user_id);`,
+	},
+	{
+		Version:     1.3,
+		Description: "Add indexes for fuzzy searching users",
+		Script: `
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_search_trgm_idx ON users
+	USING GIN ((coalesce(name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(email, '')) gin_trgm_ops);
+
+CREATE INDEX users_search_tsv_idx ON users
+	USING GIN (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(email, '')));`,
+	},
+	{
+		Version:     1.4,
+		Description: "Add version to users",
+		Script: `
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	},
+	{
+		Version:     1.5,
+		Description: "Add soft deletion to users",
+		Script: `
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+
+CREATE UNIQUE INDEX users_email_active_idx ON users (email) WHERE deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;`,
+	},
+	{
+		Version:     1.6,
+		Description: "Track password changes of users",
+		Script: `
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP;`,
+	},
+	{
+		Version:     1.7,
+		Description: "Create table password_reset_tokens",
+		Script: `
+CREATE TABLE password_reset_tokens (
+	token_hash   TEXT,
+	user_id      UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+	expires_at   TIMESTAMP NOT NULL,
+	used_at      TIMESTAMP,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (token_hash)
+);
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);`,
+	},
+	{
+		Version:     1.8,
+		Description: "Track email verification of users",
+		Script: `
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+CREATE TABLE email_verification_tokens (
+	token_hash   TEXT,
+	user_id      UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+	email        TEXT NOT NULL,
+	expires_at   TIMESTAMP NOT NULL,
+	used_at      TIMESTAMP,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (token_hash)
+);
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens (user_id);`,
+	},
+	{
+		Version:     1.9,
+		Description: "Create table email_change_tokens",
+		Script: `
+CREATE TABLE email_change_tokens (
+	token_hash   TEXT,
+	user_id      UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+	old_email    TEXT NOT NULL,
+	new_email    TEXT NOT NULL,
+	expires_at   TIMESTAMP NOT NULL,
+	used_at      TIMESTAMP,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (token_hash)
+);
+CREATE INDEX email_change_tokens_user_id_idx ON email_change_tokens (user_id);`,
+	},
+	{
+		Version:     2.1,
+		Description: "Create table role_changes",
+		Script: `
+CREATE TABLE role_changes (
+	change_id    UUID,
+	user_id      UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+	actor_id     UUID NOT NULL,
+	granted      TEXT[] NOT NULL,
+	revoked      TEXT[] NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (change_id)
+);
+CREATE INDEX role_changes_user_id_date_created_idx ON role_changes (user_id, date_created);`,
+	},
+	{
+		Version:     2.2,
+		Description: "Create tables roles and role_permissions",
+		Script: `
+CREATE TABLE roles (
+	name         TEXT,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (name)
+);
+CREATE TABLE role_permissions (
+	role       TEXT REFERENCES roles (name) ON DELETE CASCADE,
+	permission TEXT,
+
+	PRIMARY KEY (role, permission)
+);
+INSERT INTO roles (name, date_created) VALUES
+	('ADMIN', now() AT TIME ZONE 'UTC'),
+	('USER', now() AT TIME ZONE 'UTC');
+INSERT INTO role_permissions (role, permission) VALUES
+	('ADMIN', 'users:read'),
+	('ADMIN', 'users:write'),
+	('ADMIN', 'users:delete'),
+	('ADMIN', 'roles:manage'),
+	('USER', 'users:read:self'),
+	('USER', 'users:write:self'),
+	('USER', 'users:delete:self');`,
+	},
+	{
+		Version:     2.3,
+		Description: "Create table organizations and add tenant_id to users",
+		Script: `
+CREATE TABLE organizations (
+	organization_id UUID,
+	name            TEXT NOT NULL,
+	date_created    TIMESTAMP NOT NULL,
+	date_updated    TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (organization_id),
+	UNIQUE (name)
+);
+INSERT INTO organizations (organization_id, name, date_created, date_updated) VALUES
+	('e9b53a4c-1a7f-4d2c-9a55-3c3f2a4d8e71', 'Default', now() AT TIME ZONE 'UTC', now() AT TIME ZONE 'UTC');
+ALTER TABLE users ADD COLUMN tenant_id UUID REFERENCES organizations (organization_id);
+UPDATE users SET tenant_id = 'e9b53a4c-1a7f-4d2c-9a55-3c3f2a4d8e71';
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX users_tenant_id_date_created_user_id_idx ON users (tenant_id, date_created, user_id);
+INSERT INTO roles (name, date_created) VALUES
+	('SUPERADMIN', now() AT TIME ZONE 'UTC');
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'users:read'),
+	('SUPERADMIN', 'users:write'),
+	('SUPERADMIN', 'users:delete'),
+	('SUPERADMIN', 'roles:manage'),
+	('SUPERADMIN', 'organizations:manage'),
+	('SUPERADMIN', 'tenants:all');`,
+	},
+	{
+		Version:     2.4,
+		Description: "Create tables groups and group_members",
+		Script: `
+CREATE TABLE groups (
+	group_id     UUID,
+	tenant_id    UUID NOT NULL REFERENCES organizations (organization_id),
+	name         TEXT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_updated TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (group_id),
+	UNIQUE (tenant_id, name)
+);
+CREATE TABLE group_members (
+	group_id     UUID REFERENCES groups (group_id) ON DELETE CASCADE,
+	user_id      UUID REFERENCES users (user_id) ON DELETE CASCADE,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (group_id, user_id)
+);
+CREATE INDEX group_members_user_id_idx ON group_members (user_id);
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'groups:manage'),
+	('ADMIN', 'groups:manage');`,
+	},
+	{
+		Version:     2.5,
+		Description: "Grant users:impersonate to admins",
+		Script: `
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'users:impersonate'),
+	('ADMIN', 'users:impersonate');`,
+	},
+	{
+		Version:     2.6,
+		Description: "Create table audit_events",
+		Script: `
+CREATE TABLE audit_events (
+	seq             BIGSERIAL,
+	event_id        UUID NOT NULL UNIQUE,
+	actor_id        TEXT NOT NULL,
+	impersonator_id TEXT NOT NULL,
+	tenant_id       UUID,
+	action          TEXT NOT NULL,
+	target_id       TEXT NOT NULL,
+	trace_id        TEXT NOT NULL,
+	client_ip       TEXT NOT NULL,
+	changes         JSON NOT NULL,
+	error           TEXT NOT NULL,
+	date_created    TIMESTAMP NOT NULL,
+	prev_hash       TEXT NOT NULL,
+	hash            TEXT NOT NULL,
+
+	PRIMARY KEY (seq)
+);
+CREATE INDEX audit_events_tenant_id_date_created_idx ON audit_events (tenant_id, date_created);
+CREATE INDEX audit_events_actor_id_date_created_idx ON audit_events (actor_id, date_created);
+CREATE INDEX audit_events_target_id_date_created_idx ON audit_events (target_id, date_created);
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'audit:read'),
+	('ADMIN', 'audit:read');`,
+	},
+	{
+		Version:     2.7,
+		Description: "Create table refresh_tokens",
+		Script: `
+CREATE TABLE refresh_tokens (
+	token_hash   TEXT,
+	family_id    UUID NOT NULL,
+	user_id      UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+	auth_time    TIMESTAMP NOT NULL,
+	expires_at   TIMESTAMP NOT NULL,
+	used_at      TIMESTAMP,
+	revoked_at   TIMESTAMP,
+	date_created TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (token_hash)
+);
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);`,
+	},
+	{
+		Version:     2.8,
+		Description: "Create the token denylist and grant tokens:revoke",
+		Script: `
+CREATE TABLE revoked_tokens (
+	token_id   TEXT,
+	expires_at TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (token_id)
+);
+CREATE TABLE revoked_subjects (
+	subject       TEXT,
+	issued_before TIMESTAMP NOT NULL,
+	expires_at    TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (subject)
+);
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'tokens:revoke'),
+	('ADMIN', 'tokens:revoke'),
+	('USER', 'tokens:revoke:self');`,
+	},
+	{
+		Version:     2.9,
+		Description: "Create the tables of the OAuth authorization server",
+		Script: `
+CREATE TABLE oauth_clients (
+	client_id     UUID,
+	name          TEXT NOT NULL,
+	redirect_uris TEXT[] NOT NULL,
+	scopes        TEXT[] NOT NULL,
+	date_created  TIMESTAMP NOT NULL,
+	date_updated  TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (client_id)
+);
+CREATE TABLE oauth_consents (
+	user_id      UUID REFERENCES users (user_id) ON DELETE CASCADE,
+	client_id    UUID REFERENCES oauth_clients (client_id) ON DELETE CASCADE,
+	scopes       TEXT[] NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_updated TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (user_id, client_id)
+);
+CREATE INDEX oauth_consents_client_id_idx ON oauth_consents (client_id);
+CREATE TABLE oauth_codes (
+	code_hash      TEXT,
+	client_id      UUID NOT NULL REFERENCES oauth_clients (client_id) ON DELETE CASCADE,
+	user_id        UUID NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+	redirect_uri   TEXT NOT NULL,
+	scopes         TEXT[] NOT NULL,
+	code_challenge TEXT NOT NULL,
+	family_id      UUID,
+	auth_time      TIMESTAMP NOT NULL,
+	expires_at     TIMESTAMP NOT NULL,
+	used_at        TIMESTAMP,
+	date_created   TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (code_hash)
+);
+CREATE INDEX oauth_codes_expires_at_idx ON oauth_codes (expires_at);
+ALTER TABLE refresh_tokens ADD COLUMN client_id UUID REFERENCES oauth_clients (client_id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens ADD COLUMN scopes TEXT[];
+CREATE INDEX refresh_tokens_client_id_idx ON refresh_tokens (client_id);
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'clients:manage');`,
+	},
+	{
+		Version:     3.0,
+		Description: "Add the nonce of OpenID Connect requests to authorization codes",
+		Script: `
+ALTER TABLE oauth_codes ADD COLUMN nonce TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		Version:     3.1,
+		Description: "Create table service_accounts",
+		Script: `
+CREATE TABLE service_accounts (
+	service_account_id UUID,
+	tenant_id          UUID NOT NULL REFERENCES organizations (organization_id),
+	name               TEXT NOT NULL,
+	secret_hash        TEXT NOT NULL,
+	permissions        TEXT[] NOT NULL,
+	date_created       TIMESTAMP NOT NULL,
+	date_updated       TIMESTAMP NOT NULL,
+
+	PRIMARY KEY (service_account_id)
+);
+CREATE INDEX service_accounts_tenant_id_idx ON service_accounts (tenant_id);
+INSERT INTO role_permissions (role, permission) VALUES
+	('SUPERADMIN', 'service_accounts:manage'),
+	('ADMIN', 'service_accounts:manage');`,
+	},
+	{
+		Version:     3.2,
+		Description: "Create table audit_chain_head",
+		Script: `
+CREATE TABLE audit_chain_head (
+	id   BOOLEAN DEFAULT TRUE CHECK (id),
+	seq  BIGINT NOT NULL,
+	hash TEXT NOT NULL,
+
+	PRIMARY KEY (id)
+);
+INSERT INTO audit_chain_head (seq, hash)
+SELECT
+	COALESCE(MAX(seq), 0),
+	COALESCE((SELECT hash FROM audit_events ORDER BY seq DESC LIMIT 1), '')
+FROM audit_events;`,
+	},
+	{
+		Version:     3.3,
+		Description: "Sign the head of the audit chain",
+		Script: `
+ALTER TABLE audit_chain_head ADD COLUMN signature TEXT NOT NULL DEFAULT '';`,
+	},
 }
@@ -23,10 +23,10 @@ func Seed(db *sqlx.DB) error {
 }
 
 const seeds = `
--- Create admin and regular User with password "password"
-INSERT INTO users (user_id, name, last_name, email, country, roles, password_hash, date_created, date_updated) VALUES
-	('5cf37266-3473-4006-984f-9325122678b7', 'Example', 'Admin', 'admin@example.com', 'Albania', '{ADMIN,USER}', '$2a$10$v79.Q7kMpIZFH0QYi.IoieRZikqOIr8a7Mo5Xk59sjeexFeuG22Oq', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
-	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'Example', 'User', 'user@example.com', 'Algeria', '{USER}', '$2a$10$v79.Q7kMpIZFH0QYi.IoieRZikqOIr8a7Mo5Xk59sjeexFeuG22Oq', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+-- Create admin and regular User of the default organization with password "password"
+INSERT INTO users (user_id, tenant_id, name, last_name, email, country, roles, password_hash, date_created, date_updated, email_verified_at) VALUES
+	('5cf37266-3473-4006-984f-9325122678b7', 'e9b53a4c-1a7f-4d2c-9a55-3c3f2a4d8e71', 'Example', 'Admin', 'admin@example.com', 'Albania', '{ADMIN,USER}', '$2a$10$v79.Q7kMpIZFH0QYi.IoieRZikqOIr8a7Mo5Xk59sjeexFeuG22Oq', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'e9b53a4c-1a7f-4d2c-9a55-3c3f2a4d8e71', 'Example', 'User', 'user@example.com', 'Algeria', '{USER}', '$2a$10$v79.Q7kMpIZFH0QYi.IoieRZikqOIr8a7Mo5Xk59sjeexFeuG22Oq', '2019-03-24 00:00:00', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
 	ON CONFLICT DO NOTHING;
 `
 
@@ -50,4 +50,10 @@ func DeleteAll(db *sqlx.DB) error {
 }
 
 const deleteAll = `
-DELETE FROM users;`
+DELETE FROM audit_events;
+UPDATE audit_chain_head SET seq = 0, hash = '', signature = '';
+DELETE FROM oauth_clients;
+DELETE FROM groups;
+DELETE FROM service_accounts;
+DELETE FROM users;
+DELETE FROM organizations WHERE organization_id != 'e9b53a4c-1a7f-4d2c-9a55-3c3f2a4d8e71';`
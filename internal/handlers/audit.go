@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type auditHandler struct {
+	svc service.UserService
+}
+
+// query returns a page of audit events, most recent first. Events can be
+// filtered by the actor and target query string parameters and by the time
+// range between from and to.
+func (ah auditHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.auditHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	qs := r.URL.Query()
+
+	filter := service.AuditFilter{
+		ActorID:  qs.Get("actor"),
+		TargetID: qs.Get("target"),
+	}
+
+	var err error
+	if s := qs.Get("from"); s != "" {
+		if filter.From, err = time.Parse(time.RFC3339, s); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "invalid from"), http.StatusBadRequest)
+		}
+	}
+	if s := qs.Get("to"); s != "" {
+		if filter.To, err = time.Parse(time.RFC3339, s); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "invalid to"), http.StatusBadRequest)
+		}
+	}
+
+	rowsPerPage, err := intParam(qs, "limit", service.DefaultRowsPerPage)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	page, err := intParam(qs, "page", 1)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	ar, err := ah.svc.QueryAuditEvents(ctx, v.TraceID, claims, filter, page, rowsPerPage)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidPagination:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying audit events")
+		}
+	}
+
+	return web.Respond(ctx, w, ar, http.StatusOK)
+}
+
+// verify checks the integrity of the chain of audit events.
+func (ah auditHandler) verify(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.auditHandler.verify")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	av, err := ah.svc.VerifyAuditEvents(ctx, v.TraceID, claims)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "verifying audit events")
+		}
+	}
+
+	return web.Respond(ctx, w, av, http.StatusOK)
+}
+
+// clientIP adds the IP address of the client to the context of every
+// request so the service can record it in audit events.
+func clientIP(handler web.Handler) web.Handler {
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		return handler(service.WithClientIP(ctx, ip), w, r)
+	}
+
+	return h
+}
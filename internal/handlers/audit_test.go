@@ -0,0 +1,183 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestAudit(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	key := []byte("audit key")
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{AuditKey: key})
+	us, _ = service.NewAuditingDecorator(ur, us, key, test.Log)
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+	superAdminToken := test.SuperAdminToken()
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Forbidden (user)", func(tt *testing.T) {
+		w := do(http.MethodGet, "/v1/audit/events", "", ut.userToken)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not let users read audit events.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		body := `{"name":"Jacob","last_name":"Walker","country":"Canada"}`
+		for _, country := range []string{"Canada", "Mexico"} {
+			body := strings.Replace(body, "Canada", country, 1)
+			if w := do(http.MethodPut, "/v1/users/"+tests.UserID, body, ut.adminToken); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+			}
+		}
+
+		w := do(http.MethodGet, "/v1/audit/events?actor="+tests.AdminID+"&target="+tests.UserID, "", ut.adminToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var ar service.AuditResult
+		if err := json.NewDecoder(w.Body).Decode(&ar); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if ar.Total != 2 || ar.Items[0].Action != service.AuditUserUpdate {
+			t.Fatalf("\t%s\tShould get the updates of the user : got %+v", tests.Failed, ar)
+		}
+		var changes map[string]service.AuditChange
+		if err := json.Unmarshal([]byte(ar.Items[0].Changes), &changes); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the changes : %v", tests.Failed, err)
+		}
+		if c := changes["country"]; c.Before != "Canada" || c.After != "Mexico" || len(changes) != 1 {
+			t.Fatalf("\t%s\tShould record the changes of the update : got %+v", tests.Failed, changes)
+		}
+		t.Logf("\t%s\tShould record the changes of the updates.", tests.Success)
+
+		if w := do(http.MethodGet, "/v1/audit/verify", "", ut.adminToken); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould only let super admins verify the whole chain.", tests.Success)
+
+		verify := func() service.AuditVerification {
+			w := do(http.MethodGet, "/v1/audit/verify", "", superAdminToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+			}
+			var av service.AuditVerification
+			if err := json.NewDecoder(w.Body).Decode(&av); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+			return av
+		}
+
+		if av := verify(); !av.Valid {
+			t.Fatalf("\t%s\tShould verify the chain : got %+v", tests.Failed, av)
+		}
+		t.Logf("\t%s\tShould verify the chain.", tests.Success)
+
+		// Pointing the head to an earlier event would hide the ones after it.
+		var head service.AuditHead
+		if err := test.DB.Get(&head, `SELECT seq, hash, signature FROM audit_chain_head`); err != nil {
+			t.Fatalf("\t%s\tShould be able to read the head : %v", tests.Failed, err)
+		}
+		if _, err := test.DB.Exec(`UPDATE audit_chain_head SET seq = $1, hash = $2`, ar.Items[1].Seq, ar.Items[1].Hash); err != nil {
+			t.Fatalf("\t%s\tShould be able to change the head : %v", tests.Failed, err)
+		}
+		if av := verify(); av.Valid {
+			t.Fatalf("\t%s\tShould detect the forged head : got %+v", tests.Failed, av)
+		}
+		if _, err := test.DB.Exec(`UPDATE audit_chain_head SET seq = $1, hash = $2`, head.Seq, head.Hash); err != nil {
+			t.Fatalf("\t%s\tShould be able to restore the head : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould detect the forged head.", tests.Success)
+
+		deleted := ar.Items[1]
+		if _, err := test.DB.Exec(`DELETE FROM audit_events WHERE seq = $1`, deleted.Seq); err != nil {
+			t.Fatalf("\t%s\tShould be able to delete the event : %v", tests.Failed, err)
+		}
+		if av := verify(); av.Valid || av.BrokenAt != ar.Items[0].Seq {
+			t.Fatalf("\t%s\tShould detect the deleted event : got %+v", tests.Failed, av)
+		}
+		t.Logf("\t%s\tShould detect the deleted event.", tests.Success)
+
+		if _, err := test.DB.Exec(`DELETE FROM audit_events WHERE seq >= $1`, head.Seq); err != nil {
+			t.Fatalf("\t%s\tShould be able to delete the last event : %v", tests.Failed, err)
+		}
+		if av := verify(); av.Valid || av.BrokenAt != head.Seq {
+			t.Fatalf("\t%s\tShould detect the deleted last event : got %+v", tests.Failed, av)
+		}
+		t.Logf("\t%s\tShould detect the deleted last event.", tests.Success)
+
+		if _, err := test.DB.Exec(`DELETE FROM audit_events`); err != nil {
+			t.Fatalf("\t%s\tShould be able to delete every event : %v", tests.Failed, err)
+		}
+		if av := verify(); av.Valid || av.Events != 0 || av.BrokenAt != head.Seq {
+			t.Fatalf("\t%s\tShould detect the deleted events : got %+v", tests.Failed, av)
+		}
+		t.Logf("\t%s\tShould detect the deleted events.", tests.Success)
+	})
+
+	t.Run("Failed code exchange", func(tt *testing.T) {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {uuid.New().String()},
+			"code":          {"replayed"},
+			"code_verifier": {"verifier"},
+		}
+		r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		if w.Code == http.StatusOK {
+			t.Fatalf("\t%s\tShould not exchange an unknown code.", tests.Failed)
+		}
+
+		w = do(http.MethodGet, "/v1/audit/events", "", superAdminToken)
+		var ar service.AuditResult
+		if err := json.NewDecoder(w.Body).Decode(&ar); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		var recorded bool
+		for _, e := range ar.Items {
+			if e.Action == service.AuditCodeExchange && e.Error != "" {
+				recorded = true
+			}
+		}
+		if !recorded {
+			t.Fatalf("\t%s\tShould record the failed code exchange : got %+v", tests.Failed, ar)
+		}
+		t.Logf("\t%s\tShould record failed code exchanges.", tests.Success)
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type clientHandler struct {
+	svc service.UserService
+}
+
+func (ch clientHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.clientHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	clients, err := ch.svc.QueryClients(ctx, v.TraceID, claims)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying clients")
+		}
+	}
+
+	return web.Respond(ctx, w, clients, http.StatusOK)
+}
+
+func (ch clientHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.clientHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var ncr service.NewClientRequest
+	if err := web.Decode(r, &ncr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	c, err := ch.svc.RegisterClient(ctx, v.TraceID, claims, ncr, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidRedirectURI, service.ErrInvalidScope:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Client: %+v", &ncr)
+		}
+	}
+
+	return web.Respond(ctx, w, c, http.StatusCreated)
+}
+
+func (ch clientHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.clientHandler.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := ch.svc.DeleteClient(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
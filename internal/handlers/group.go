@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type groupHandler struct {
+	svc service.UserService
+}
+
+func (gh groupHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	groups, err := gh.svc.QueryGroups(ctx, v.TraceID, claims)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying groups")
+		}
+	}
+
+	return web.Respond(ctx, w, groups, http.StatusOK)
+}
+
+func (gh groupHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var ngr service.NewGroupRequest
+	if err := web.Decode(r, &ngr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	g, err := gh.svc.CreateGroup(ctx, v.TraceID, claims, ngr, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrDuplicatedGroup:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Group: %+v", &ngr)
+		}
+	}
+
+	return web.Respond(ctx, w, g, http.StatusCreated)
+}
+
+func (gh groupHandler) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.update")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var ugr service.UpdateGroupRequest
+	if err := web.Decode(r, &ugr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := gh.svc.UpdateGroup(ctx, v.TraceID, claims, params["id"], ugr, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrDuplicatedGroup:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Group: %+v", params["id"], &ugr)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (gh groupHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := gh.svc.DeleteGroup(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// members returns a page of the members of a Group. The page and its size
+// are selected with the page and limit query string parameters.
+func (gh groupHandler) members(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.members")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	qs := r.URL.Query()
+
+	rowsPerPage, err := intParam(qs, "limit", service.DefaultRowsPerPage)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	page, err := intParam(qs, "page", 1)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	params := web.Params(r)
+	qr, err := gh.svc.QueryGroupMembers(ctx, v.TraceID, claims, params["id"], page, rowsPerPage)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID, service.ErrInvalidPagination:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, qr, http.StatusOK)
+}
+
+func (gh groupHandler) addMember(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.addMember")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var amr service.AddGroupMemberRequest
+	if err := web.Decode(r, &amr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := gh.svc.AddGroupMember(ctx, v.TraceID, claims, params["id"], amr.UserID, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  User: %s", params["id"], amr.UserID)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (gh groupHandler) removeMember(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.removeMember")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := gh.svc.RemoveGroupMember(ctx, v.TraceID, claims, params["id"], params["user_id"]); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  User: %s", params["id"], params["user_id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// userGroups returns the Groups a User is a member of.
+func (gh groupHandler) userGroups(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.groupHandler.userGroups")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	groups, err := gh.svc.QueryUserGroups(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, groups, http.StatusOK)
+}
@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestGroups(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Forbidden (user)", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/groups", `{"name":"Engineering"}`, ut.userToken)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/groups", `{"name":"Engineering"}`, ut.adminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var g service.Group
+		if err := json.NewDecoder(w.Body).Decode(&g); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if g.TenantID != service.DefaultTenantID {
+			t.Fatalf("\t%s\tShould create the group in the organization of the admin : got %q", tests.Failed, g.TenantID)
+		}
+		t.Logf("\t%s\tShould create the group.", tests.Success)
+
+		if w := do(http.MethodPost, "/v1/groups", `{"name":"Engineering"}`, ut.adminToken); w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tShould receive a status code of 409 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not create two groups with the same name.", tests.Success)
+
+		for _, id := range []string{tests.UserID, tests.AdminID, tests.UserID} {
+			if w := do(http.MethodPost, "/v1/groups/"+g.ID+"/members", `{"user_id":"`+id+`"}`, ut.adminToken); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+			}
+		}
+		t.Logf("\t%s\tShould add the members.", tests.Success)
+
+		w = do(http.MethodGet, "/v1/groups/"+g.ID+"/members?limit=1&page=2", "", ut.adminToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var qr struct {
+			Items []service.User `json:"items"`
+			Total int            `json:"total"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&qr); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if qr.Total != 2 || len(qr.Items) != 1 || qr.Items[0].ID != tests.AdminID {
+			t.Fatalf("\t%s\tShould get the second page of members : got %+v", tests.Failed, qr)
+		}
+		t.Logf("\t%s\tShould get the second page of members.", tests.Success)
+
+		w = do(http.MethodGet, "/v1/users/"+tests.UserID+"/groups", "", ut.userToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var groups []service.Group
+		if err := json.NewDecoder(w.Body).Decode(&groups); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if len(groups) != 1 || groups[0].ID != g.ID {
+			t.Fatalf("\t%s\tShould list the groups of the user : got %+v", tests.Failed, groups)
+		}
+		t.Logf("\t%s\tShould let users list their own groups.", tests.Success)
+
+		if w := do(http.MethodGet, "/v1/users/"+tests.AdminID+"/groups", "", ut.userToken); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not let users list the groups of others.", tests.Success)
+
+		if w := do(http.MethodPut, "/v1/groups/"+g.ID, `{"name":"Platform"}`, ut.adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould rename the group.", tests.Success)
+
+		if w := do(http.MethodDelete, "/v1/groups/"+g.ID+"/members/"+tests.UserID, "", ut.adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if w := do(http.MethodDelete, "/v1/groups/"+g.ID+"/members/"+tests.UserID, "", ut.adminToken); w.Code != http.StatusNotFound {
+			t.Fatalf("\t%s\tShould receive a status code of 404 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould remove the member.", tests.Success)
+
+		if w := do(http.MethodDelete, "/v1/groups/"+g.ID, "", ut.adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if w := do(http.MethodGet, "/v1/groups/"+g.ID+"/members", "", ut.adminToken); w.Code != http.StatusNotFound {
+			t.Fatalf("\t%s\tShould receive a status code of 404 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould delete the group.", tests.Success)
+	})
+}
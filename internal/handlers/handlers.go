@@ -13,6 +13,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/santiagoh1997/service-template/internal/auth"
 	"github.com/santiagoh1997/service-template/internal/mid"
+	"github.com/santiagoh1997/service-template/internal/pkg/cursor"
 	"github.com/santiagoh1997/service-template/internal/pkg/web"
 	"github.com/santiagoh1997/service-template/internal/service"
 )
@@ -26,7 +27,10 @@ func NewHTTPHandler(
 	errorCount metrics.Counter,
 	redMetrics metrics.Histogram,
 	a *auth.Auth,
+	denylist auth.Denylist,
 	db *sqlx.DB,
+	cursors *cursor.Signer,
+	issuer string,
 ) http.Handler {
 
 	// Setting up the common middleware based on the parameters passed in.
@@ -41,6 +45,7 @@ func NewHTTPHandler(
 		}
 		commonMiddleware = append(commonMiddleware, mid.Panics(log))
 	}
+	commonMiddleware = append(commonMiddleware, clientIP)
 
 	// The web.App holds all routes and all the common Middleware.
 	app := web.NewApp(shutdown, commonMiddleware...)
@@ -61,16 +66,127 @@ func NewHTTPHandler(
 	}
 	app.HandleDebug(http.MethodGet, "/metrics", prometheusHandler)
 
-	// Register main endpoints.
-	uh := userHandler{
+	// Register the keys tokens are verified with.
+	jh := jwksHandler{
+		auth: a,
+	}
+	app.Handle(http.MethodGet, "/.well-known/jwks.json", jh.keys)
+
+	// Register the OAuth 2.0 authorization server, so clients can obtain
+	// tokens without handling the credentials of Users.
+	oah := oauthHandler{
 		svc:  us,
 		auth: a,
 	}
+	app.Handle(http.MethodGet, "/oauth/authorize", oah.authorize)
+	app.Handle(http.MethodPost, "/oauth/authorize", oah.approve)
+	app.Handle(http.MethodPost, "/oauth/token", oah.token)
+
+	// Register main endpoints.
+	uh := userHandler{
+		svc:     us,
+		auth:    a,
+		cursors: cursors,
+	}
+
+	// Tokens are also checked against the denylist and the current state of
+	// their User so they can be invalidated before they expire.
+	authenticate := mid.Authenticate(a, denylist, us.ValidateClaims)
+
+	// can requires the permissions, resolved from the roles of the User.
+	// Routes acting on a single User are authorized by the service instead,
+	// since its policy can depend on the attributes of that User.
+	can := func(perms ...string) web.Middleware {
+		return mid.RequirePermission(us.Permissions, perms...)
+	}
+
+	app.Handle(http.MethodGet, "/v1/users/token", uh.token)
 	app.Handle(http.MethodGet, "/v1/users/token/:kid", uh.token)
-	app.Handle(http.MethodGet, "/v1/users/:id", uh.getByID, mid.Authenticate(a))
+	app.Handle(http.MethodPost, "/v1/users/token/refresh", uh.refresh)
+	app.Handle(http.MethodPost, "/v1/users/logout", uh.logout, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/password/forgot", uh.forgotPassword)
+	app.Handle(http.MethodPost, "/v1/users/password/reset", uh.resetPassword)
+	app.Handle(http.MethodPost, "/v1/users/verify-email", uh.verifyEmail)
+	app.Handle(http.MethodPost, "/v1/users/verify-email/resend", uh.resendVerification)
+	app.Handle(http.MethodPost, "/v1/users/email/confirm", uh.confirmEmailChange)
+	app.Handle(http.MethodGet, "/v1/users", uh.query, authenticate, can(auth.PermUsersRead))
+	app.Handle(http.MethodGet, "/v1/users/search", uh.search, authenticate, can(auth.PermUsersRead))
+	app.Handle(http.MethodGet, "/v1/users/:id", uh.getByID, authenticate)
 	app.Handle(http.MethodPost, "/v1/users", uh.create)
-	app.Handle(http.MethodPut, "/v1/users/:id", uh.update, mid.Authenticate(a))
-	app.Handle(http.MethodDelete, "/v1/users/:id", uh.delete, mid.Authenticate(a))
+	app.Handle(http.MethodPut, "/v1/users/:id", uh.update, authenticate)
+	app.Handle(http.MethodPatch, "/v1/users/:id", uh.patch, authenticate)
+	app.Handle(http.MethodPut, "/v1/users/:id/password", uh.changePassword, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/:id/email", uh.requestEmailChange, authenticate)
+	app.Handle(http.MethodPut, "/v1/users/:id/roles", uh.updateRoles, authenticate, can(auth.PermRolesManage))
+	app.Handle(http.MethodGet, "/v1/users/:id/roles/changes", uh.roleChanges, authenticate, can(auth.PermRolesManage))
+	app.Handle(http.MethodDelete, "/v1/users/:id", uh.delete, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/:id/restore", uh.restore, authenticate, can(auth.PermUsersDelete))
+	app.Handle(http.MethodPost, "/v1/users/:id/impersonate", uh.impersonate, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/:id/tokens/revoke", uh.revokeTokens, authenticate)
+
+	rh := roleHandler{
+		svc: us,
+	}
+	app.Handle(http.MethodGet, "/v1/roles", rh.query, authenticate, can(auth.PermRolesManage))
+	app.Handle(http.MethodPost, "/v1/roles", rh.create, authenticate, can(auth.PermRolesManage))
+	app.Handle(http.MethodPut, "/v1/roles/:name", rh.update, authenticate, can(auth.PermRolesManage))
+	app.Handle(http.MethodDelete, "/v1/roles/:name", rh.delete, authenticate, can(auth.PermRolesManage))
+
+	oh := organizationHandler{
+		svc: us,
+	}
+	app.Handle(http.MethodGet, "/v1/organizations", oh.query, authenticate, can(auth.PermOrganizationsManage))
+	app.Handle(http.MethodPost, "/v1/organizations", oh.create, authenticate, can(auth.PermOrganizationsManage))
+	app.Handle(http.MethodGet, "/v1/organizations/:id", oh.getByID, authenticate)
+	app.Handle(http.MethodPut, "/v1/organizations/:id", oh.update, authenticate, can(auth.PermOrganizationsManage))
+	app.Handle(http.MethodDelete, "/v1/organizations/:id", oh.delete, authenticate, can(auth.PermOrganizationsManage))
+	app.Handle(http.MethodPost, "/v1/organizations/:id/users", oh.createUser, authenticate, can(auth.PermUsersWrite))
+
+	gh := groupHandler{
+		svc: us,
+	}
+	app.Handle(http.MethodGet, "/v1/groups", gh.query, authenticate, can(auth.PermUsersRead))
+	app.Handle(http.MethodPost, "/v1/groups", gh.create, authenticate, can(auth.PermGroupsManage))
+	app.Handle(http.MethodPut, "/v1/groups/:id", gh.update, authenticate, can(auth.PermGroupsManage))
+	app.Handle(http.MethodDelete, "/v1/groups/:id", gh.delete, authenticate, can(auth.PermGroupsManage))
+	app.Handle(http.MethodGet, "/v1/groups/:id/members", gh.members, authenticate, can(auth.PermUsersRead))
+	app.Handle(http.MethodPost, "/v1/groups/:id/members", gh.addMember, authenticate, can(auth.PermGroupsManage))
+	app.Handle(http.MethodDelete, "/v1/groups/:id/members/:user_id", gh.removeMember, authenticate, can(auth.PermGroupsManage))
+	app.Handle(http.MethodGet, "/v1/users/:id/groups", gh.userGroups, authenticate)
+
+	clh := clientHandler{
+		svc: us,
+	}
+	app.Handle(http.MethodGet, "/v1/oauth/clients", clh.query, authenticate, can(auth.PermClientsManage))
+	app.Handle(http.MethodPost, "/v1/oauth/clients", clh.create, authenticate, can(auth.PermClientsManage))
+	app.Handle(http.MethodDelete, "/v1/oauth/clients/:id", clh.delete, authenticate, can(auth.PermClientsManage))
+
+	// Service accounts obtain their tokens through the client credentials
+	// grant of /oauth/token.
+	sah := serviceAccountHandler{
+		svc: us,
+	}
+	app.Handle(http.MethodGet, "/v1/service-accounts", sah.query, authenticate, can(auth.PermServiceAccountsManage))
+	app.Handle(http.MethodPost, "/v1/service-accounts", sah.create, authenticate, can(auth.PermServiceAccountsManage))
+	app.Handle(http.MethodPost, "/v1/service-accounts/:id/secret", sah.rotateSecret, authenticate, can(auth.PermServiceAccountsManage))
+	app.Handle(http.MethodDelete, "/v1/service-accounts/:id", sah.delete, authenticate, can(auth.PermServiceAccountsManage))
+
+	// Register the OpenID Connect endpoints, so clients can sign Users in
+	// with off-the-shelf libraries.
+	oih := oidcHandler{
+		svc:    us,
+		auth:   a,
+		issuer: issuer,
+	}
+	app.Handle(http.MethodGet, "/.well-known/openid-configuration", oih.configuration)
+	app.Handle(http.MethodGet, "/userinfo", oih.userinfo, authenticate)
+	app.Handle(http.MethodPost, "/userinfo", oih.userinfo, authenticate)
+
+	ah := auditHandler{
+		svc: us,
+	}
+	app.Handle(http.MethodGet, "/v1/audit/events", ah.query, authenticate, can(auth.PermAuditRead))
+	app.Handle(http.MethodGet, "/v1/audit/verify", ah.verify, authenticate, can(auth.PermAuditRead))
 
 	return app
 }
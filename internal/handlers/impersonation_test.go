@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestImpersonate(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+	superAdminToken := test.SuperAdminToken()
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Forbidden (user)", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/users/"+tests.AdminID+"/impersonate", "", ut.userToken)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not let users impersonate others.", tests.Success)
+	})
+
+	t.Run("Forbidden (more privileged user)", func(tt *testing.T) {
+		claims, err := test.Auth.ValidateToken(superAdminToken)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to validate the token : %v", tests.Failed, err)
+		}
+
+		w := do(http.MethodPost, "/v1/users/"+claims.Subject+"/impersonate", "", ut.adminToken)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not let admins impersonate users with more permissions.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/users/"+tests.UserID+"/impersonate", "", ut.adminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var tkn struct {
+			Token string `json:"token"`
+			Actor struct {
+				Subject string `json:"sub"`
+			} `json:"act"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if tkn.Actor.Subject != tests.AdminID {
+			t.Fatalf("\t%s\tShould identify the admin as the actor : got %q", tests.Failed, tkn.Actor.Subject)
+		}
+		t.Logf("\t%s\tShould issue a token for the impersonated session.", tests.Success)
+
+		claims, err := test.Auth.ValidateToken(tkn.Token)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to validate the token : %v", tests.Failed, err)
+		}
+		if claims.Subject != tests.UserID || !claims.Impersonated() || claims.Actor.Subject != tests.AdminID {
+			t.Fatalf("\t%s\tShould carry both the subject and the actor : got %+v", tests.Failed, claims)
+		}
+		t.Logf("\t%s\tShould carry both the subject and the actor.", tests.Success)
+
+		w = do(http.MethodGet, "/v1/users/"+tests.UserID, "", tkn.Token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if got := w.Header().Get("Impersonated-By"); got != tests.AdminID {
+			t.Fatalf("\t%s\tShould expose the actor in the response : got %q", tests.Failed, got)
+		}
+		t.Logf("\t%s\tShould expose the actor in the response.", tests.Success)
+
+		body := `{"current_password":"password","password":"new_password","password_confirm":"new_password"}`
+		if w := do(http.MethodPut, "/v1/users/"+tests.UserID+"/password", body, tkn.Token); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if w := do(http.MethodDelete, "/v1/users/"+tests.UserID, "", tkn.Token); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not allow destructive operations while impersonating.", tests.Success)
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// jwksMaxAge is how long, in seconds, clients can cache the key set. It must
+// be shorter than the time a new key is published before it signs tokens.
+const jwksMaxAge = 300
+
+type jwksHandler struct {
+	auth *auth.Auth
+}
+
+// keys returns the public keys tokens can be verified with as a JSON Web Key
+// Set, so services consuming them can follow key rotations.
+func (jh jwksHandler) keys(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.jwksHandler.keys")
+	defer span.End()
+
+	set := jh.auth.JWKS()
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return errors.Wrap(err, "encoding key set")
+	}
+	sum := sha256.Sum256(data)
+	tag := fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", jwksMaxAge))
+	w.Header().Set("ETag", tag)
+
+	if noneMatch(r, tag) {
+		return web.Respond(ctx, w, nil, http.StatusNotModified)
+	}
+
+	return web.Respond(ctx, w, set, http.StatusOK)
+}
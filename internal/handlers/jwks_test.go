@@ -0,0 +1,55 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestJWKS(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	app := handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer)
+
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+	}
+	if w.Header().Get("Cache-Control") == "" || w.Header().Get("ETag") == "" {
+		t.Fatalf("\t%s\tShould let clients cache the key set : got %v", tests.Failed, w.Header())
+	}
+	var set auth.JWKS
+	if err := json.NewDecoder(w.Body).Decode(&set); err != nil {
+		t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != test.KID {
+		t.Fatalf("\t%s\tShould publish the signing key : got %+v", tests.Failed, set.Keys)
+	}
+	t.Logf("\t%s\tShould publish the signing key.", tests.Success)
+
+	r = httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("\t%s\tShould receive a status code of 304 for the response. Received: %v", tests.Failed, w.Code)
+	}
+	t.Logf("\t%s\tShould not send the key set again while it didn't change.", tests.Success)
+}
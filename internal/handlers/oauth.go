@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// These are the values of the consent field of the form Users approve
+// authorization requests with.
+const (
+	consentAllow = "allow"
+	consentDeny  = "deny"
+)
+
+// csrfCookie is the cookie holding the token the consent form must be posted
+// with, so other sites can't post it on behalf of Users.
+const csrfCookie = "oauth_csrf"
+
+// consentPage is the page Users sign in and consent to authorization
+// requests with. The parameters of the request are posted back along with
+// the token protecting the form.
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Sign in to {{.ClientName}}</title>
+</head>
+<body>
+<h1>Sign in to {{.ClientName}}</h1>
+{{if .Scopes}}<p>{{.ClientName}} asks for access to:</p>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Error}}<p role="alert">{{.Error}}</p>
+{{end}}<form method="post" action="/oauth/authorize">
+{{range $name, $values := .Params}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<label>Email <input type="email" name="email" value="{{.Email}}" required></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit" name="consent" value="allow">Allow</button>
+<button type="submit" name="consent" value="deny" formnovalidate>Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// consent is the content of the consent page.
+type consent struct {
+	ClientName string
+	Scopes     []string
+	Params     url.Values
+	CSRFToken  string
+	Email      string
+	Error      string
+}
+
+// oauthError is an error response of the authorization server (RFC 6749
+// sections 4.1.2.1 and 5.2).
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// tokenResponse is a successful response of the token endpoint (RFC 6749
+// section 5.1). IDToken is only set for OpenID Connect requests.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type oauthHandler struct {
+	svc  service.UserService
+	auth *auth.Auth
+}
+
+// authorize validates an authorization request and serves the page Users
+// sign in and consent to it with.
+func (oh oauthHandler) authorize(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.oauthHandler.authorize")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	ar := authorizationRequest(r.URL.Query())
+	authz, err := oh.svc.CheckAuthorization(ctx, v.TraceID, ar)
+	if err != nil {
+		return authorizationError(ctx, w, r, authz, ar, err)
+	}
+
+	return renderConsent(ctx, w, authz, ar, "", nil, http.StatusOK)
+}
+
+// approve authenticates the User with the credentials posted by the consent
+// page and sends them back to the client with an authorization code, unless
+// they deny the request. Only the form of the consent page is accepted, so
+// the parameters of the request must be posted along with its token.
+func (oh oauthHandler) approve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.oauthHandler.approve")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	// Other sites can neither read the cookie nor have it sent along with
+	// the forms they post, so they can't match its token.
+	if !validCSRFToken(r) {
+		return web.NewRequestError(errors.New("invalid csrf token"), http.StatusForbidden)
+	}
+
+	ar := authorizationRequest(r.PostForm)
+	authz, err := oh.svc.CheckAuthorization(ctx, v.TraceID, ar)
+	if err != nil {
+		return authorizationError(ctx, w, r, authz, ar, err)
+	}
+
+	consent := r.PostForm.Get("consent")
+	if consent == consentDeny {
+		clearCSRFToken(w)
+		return redirect(ctx, w, r, authz.RedirectURI, url.Values{
+			"error": {"access_denied"},
+			"state": {ar.State},
+		})
+	}
+
+	lr := service.LoginRequest{
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+	if err := web.Validate(lr); err != nil {
+		return renderConsent(ctx, w, authz, ar, lr.Email, errors.New("email and password are required"), http.StatusBadRequest)
+	}
+
+	code, err := oh.svc.Authorize(ctx, v.TraceID, ar, lr, consent == consentAllow, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrAuthenticationFailure:
+			return renderConsent(ctx, w, authz, ar, lr.Email, err, http.StatusUnauthorized)
+		case service.ErrEmailNotVerified, service.ErrConsentRequired:
+			return renderConsent(ctx, w, authz, ar, lr.Email, err, http.StatusForbidden)
+		default:
+			return authorizationError(ctx, w, r, authz, ar, err)
+		}
+	}
+
+	clearCSRFToken(w)
+	return redirect(ctx, w, r, authz.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {ar.State},
+	})
+}
+
+// renderConsent serves the consent page of an authorization request, along
+// with a new token to post its form with. The error of a previous attempt is
+// shown, if any.
+func renderConsent(ctx context.Context, w http.ResponseWriter, authz service.Authorization, ar service.AuthorizationRequest, email string, failure error, statusCode int) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "generating csrf token")
+	}
+	page := consent{
+		ClientName: authz.ClientName,
+		Scopes:     authz.Scopes,
+		Params:     authorizationParams(ar),
+		CSRFToken:  base64.RawURLEncoding.EncodeToString(b),
+		Email:      email,
+	}
+	if failure != nil {
+		page.Error = failure.Error()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    page.CSRFToken,
+		Path:     "/oauth/authorize",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	// The page can't be framed, so Users can't be tricked into clicking on
+	// it from other sites.
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Content-Security-Policy", "frame-ancestors 'none'")
+
+	// Set the status code for the request logger middleware.
+	v.StatusCode = statusCode
+	w.WriteHeader(statusCode)
+
+	if err := consentPage.Execute(w, page); err != nil {
+		return errors.Wrap(err, "rendering consent page")
+	}
+	return nil
+}
+
+// validCSRFToken reports whether the form of a request was posted with the
+// token of its cookie.
+func validCSRFToken(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostForm.Get("csrf_token"))) == 1
+}
+
+// clearCSRFToken removes the cookie of the token once the form is answered,
+// so it can't be posted again.
+func clearCSRFToken(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Path:     "/oauth/authorize",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// authorizationError reports the errors of authorization requests. Requests
+// of unknown clients or redirect URIs are rejected right away, since they
+// can't be trusted to send Users back to the client. The rest are sent to the
+// client through the redirect URI.
+func authorizationError(ctx context.Context, w http.ResponseWriter, r *http.Request, authz service.Authorization, ar service.AuthorizationRequest, err error) error {
+	var code string
+	switch err {
+	case service.ErrInvalidClient, service.ErrInvalidRedirectURI:
+		return web.NewRequestError(err, http.StatusBadRequest)
+	case service.ErrUnsupportedResponseType:
+		code = "unsupported_response_type"
+	case service.ErrInvalidScope:
+		code = "invalid_scope"
+	case service.ErrInvalidCodeChallenge:
+		code = "invalid_request"
+	default:
+		return errors.Wrapf(err, "authorizing client %s", ar.ClientID)
+	}
+
+	return redirect(ctx, w, r, authz.RedirectURI, url.Values{
+		"error":             {code},
+		"error_description": {err.Error()},
+		"state":             {ar.State},
+	})
+}
+
+// token exchanges authorization codes and refresh tokens issued to clients
+// for access tokens, as well as the credentials of service accounts, which
+// can be sent either with HTTP Basic authentication or in the form.
+func (oh oauthHandler) token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.oauthHandler.token")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	// Responses carry tokens, so they must never be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		return web.Respond(ctx, w, oauthError{Error: "invalid_request", Description: err.Error()}, http.StatusBadRequest)
+	}
+
+	tr := service.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientID:     r.PostForm.Get("client_id"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+	}
+
+	var (
+		g   service.Grant
+		err error
+	)
+	switch tr.GrantType {
+	case "authorization_code":
+		if tr.ClientID == "" || tr.Code == "" || tr.CodeVerifier == "" {
+			return web.Respond(ctx, w, oauthError{Error: "invalid_request", Description: "client_id, code and code_verifier are required"}, http.StatusBadRequest)
+		}
+		g, err = oh.svc.ExchangeCode(ctx, v.TraceID, tr, v.Now)
+	case "refresh_token":
+		if tr.ClientID == "" || tr.RefreshToken == "" {
+			return web.Respond(ctx, w, oauthError{Error: "invalid_request", Description: "client_id and refresh_token are required"}, http.StatusBadRequest)
+		}
+		g, err = oh.svc.ExchangeRefreshToken(ctx, v.TraceID, tr, v.Now)
+	case "client_credentials":
+		basic := clientCredentials(r, &tr)
+		if tr.ClientID == "" || tr.ClientSecret == "" {
+			return web.Respond(ctx, w, oauthError{Error: "invalid_client", Description: "client credentials are required"}, http.StatusUnauthorized)
+		}
+		g, err = oh.svc.ExchangeClientCredentials(ctx, v.TraceID, tr, v.Now)
+		if err == service.ErrInvalidClient {
+			if basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			}
+			return web.Respond(ctx, w, oauthError{Error: "invalid_client", Description: err.Error()}, http.StatusUnauthorized)
+		}
+	default:
+		return web.Respond(ctx, w, oauthError{Error: "unsupported_grant_type"}, http.StatusBadRequest)
+	}
+	if err != nil {
+		switch err {
+		case service.ErrInvalidGrant, service.ErrInvalidRefreshToken, service.ErrRefreshTokenReused, service.ErrEmailNotVerified:
+			return web.Respond(ctx, w, oauthError{Error: "invalid_grant", Description: err.Error()}, http.StatusBadRequest)
+		case service.ErrInvalidScope:
+			return web.Respond(ctx, w, oauthError{Error: "invalid_scope", Description: err.Error()}, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "exchanging %s grant", tr.GrantType)
+		}
+	}
+
+	kid := oh.auth.ActiveKID()
+	resp := tokenResponse{
+		TokenType:    "Bearer",
+		ExpiresIn:    g.Claims.ExpiresAt - v.Now.Unix(),
+		RefreshToken: g.RefreshToken,
+		Scope:        g.Claims.Scope,
+	}
+	resp.AccessToken, err = oh.auth.GenerateToken(kid, g.Claims)
+	if err != nil {
+		return errors.Wrap(err, "generating token")
+	}
+	if g.IDClaims != nil {
+		resp.IDToken, err = oh.auth.GenerateIDToken(kid, *g.IDClaims)
+		if err != nil {
+			return errors.Wrap(err, "generating id token")
+		}
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// clientCredentials sets the credentials of the service account of a token
+// request, taken from its HTTP Basic authentication if any, which is
+// reported, or from its form otherwise. Basic credentials are form encoded
+// (RFC 6749 section 2.3.1).
+func clientCredentials(r *http.Request, tr *service.TokenRequest) bool {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		tr.ClientSecret = r.PostForm.Get("client_secret")
+		return false
+	}
+
+	tr.ClientID, tr.ClientSecret = "", ""
+	if id, err := url.QueryUnescape(id); err == nil {
+		tr.ClientID = id
+	}
+	if secret, err := url.QueryUnescape(secret); err == nil {
+		tr.ClientSecret = secret
+	}
+	return true
+}
+
+// authorizationRequest returns the authorization request of the parameters.
+func authorizationRequest(params url.Values) service.AuthorizationRequest {
+	return service.AuthorizationRequest{
+		ResponseType:        params.Get("response_type"),
+		ClientID:            params.Get("client_id"),
+		RedirectURI:         params.Get("redirect_uri"),
+		Scope:               params.Get("scope"),
+		State:               params.Get("state"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Nonce:               params.Get("nonce"),
+	}
+}
+
+// authorizationParams returns the parameters of the authorization request.
+// Empty parameters are left out.
+func authorizationParams(ar service.AuthorizationRequest) url.Values {
+	params := url.Values{}
+	for name, value := range map[string]string{
+		"response_type":         ar.ResponseType,
+		"client_id":             ar.ClientID,
+		"redirect_uri":          ar.RedirectURI,
+		"scope":                 ar.Scope,
+		"state":                 ar.State,
+		"code_challenge":        ar.CodeChallenge,
+		"code_challenge_method": ar.CodeChallengeMethod,
+		"nonce":                 ar.Nonce,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	return params
+}
+
+// redirect sends the User to a redirect URI with the parameters added to its
+// query. Empty parameters are left out.
+func redirect(ctx context.Context, w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) error {
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return errors.Wrapf(err, "parsing redirect uri %q", redirectURI)
+	}
+
+	q := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			q[key] = values
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	// Set the status code for the request logger middleware.
+	v.StatusCode = http.StatusFound
+	http.Redirect(w, r, u.String(), http.StatusFound)
+	return nil
+}
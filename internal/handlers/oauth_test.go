@@ -0,0 +1,302 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+// csrfToken matches the token of the form of the consent page.
+var csrfToken = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// signInThroughConsentPage gets the consent page of an authorization request
+// and posts its form with the fields, as the browser of the user would.
+func signInThroughConsentPage(t *testing.T, app http.Handler, params, fields url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	m := csrfToken.FindStringSubmatch(w.Body.String())
+	if w.Code != http.StatusOK || m == nil {
+		t.Fatalf("\t%s\tShould receive the consent page. Received: %v", tests.Failed, w.Code)
+	}
+
+	form := url.Values{"csrf_token": {m[1]}}
+	for k, v := range params {
+		form[k] = v
+	}
+	for k, v := range fields {
+		form[k] = v
+	}
+	r = httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+	return w
+}
+
+func TestOAuth(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	// Registering clients is reserved to superadmins, so the client is
+	// created right in the DB.
+	const redirectURI = "https://app.example.com/callback"
+	client := service.Client{
+		ID:           "7b0ad5b8-5c54-4b1c-9a45-7c0a8fd5f3a1",
+		Name:         "Example App",
+		RedirectURIs: []string{redirectURI},
+		Scopes:       []string{"users", "groups"},
+		DateCreated:  time.Now(),
+		DateUpdated:  time.Now(),
+	}
+	if err := ur.CreateClient(context.Background(), client); err != nil {
+		t.Fatalf("\t%s\tShould be able to create a client : %v", tests.Failed, err)
+	}
+
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	params := func(changes map[string]string) url.Values {
+		params := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {client.ID},
+			"redirect_uri":          {redirectURI},
+			"scope":                 {"users"},
+			"state":                 {"xyz"},
+			"code_challenge":        {challenge},
+			"code_challenge_method": {"S256"},
+		}
+		for k, v := range changes {
+			params.Set(k, v)
+		}
+		return params
+	}
+	post := func(target string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+	authorize := func(consent string) url.Values {
+		fields := url.Values{"email": {"user@example.com"}, "password": {"password"}, "consent": {consent}}
+		w := signInThroughConsentPage(t, ut.app, params(nil), fields)
+		if w.Code != http.StatusFound {
+			t.Fatalf("\t%s\tShould receive a status code of 302 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || !strings.HasPrefix(location.String(), redirectURI) {
+			t.Fatalf("\t%s\tShould be redirected to the client. Received: %v", tests.Failed, location)
+		}
+		return location.Query()
+	}
+	token := func(form url.Values) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := post("/oauth/token", form)
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		return w, resp
+	}
+	exchange := func(code, verifier string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		return token(url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {client.ID},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		})
+	}
+
+	t.Run("Forbidden (admin)", func(tt *testing.T) {
+		body := `{"name":"Other App","redirect_uris":["https://other.example.com/callback"]}`
+		r := httptest.NewRequest(http.MethodPost, "/v1/oauth/clients", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould only let superadmins register clients.", tests.Success)
+	})
+
+	t.Run("Invalid requests", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params(map[string]string{"redirect_uri": "https://evil.example.com/callback"}).Encode(), nil)
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for unregistered redirect URIs. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not redirect to unregistered redirect URIs.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params(map[string]string{"code_challenge_method": "plain"}).Encode(), nil)
+		w = httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		if w.Code != http.StatusFound || location.Query().Get("error") != "invalid_request" || location.Query().Get("state") != "xyz" {
+			t.Fatalf("\t%s\tShould redirect with invalid_request. Received: %v %v", tests.Failed, w.Code, location)
+		}
+		t.Logf("\t%s\tShould require S256 code challenges.", tests.Success)
+
+		fields := url.Values{"email": {"user@example.com"}, "password": {"password"}, "consent": {"allow"}}
+		form := params(nil)
+		for k, v := range fields {
+			form[k] = v
+		}
+		if w := post("/oauth/authorize", form); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 without the token of the consent page. Received: %v", tests.Failed, w.Code)
+		}
+		form.Set("csrf_token", "forged")
+		r = httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: "oauth_csrf", Value: "other"})
+		w = httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for a token not matching the cookie. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould only accept the form of the consent page.", tests.Success)
+
+		fields.Set("password", "wrong")
+		if w := signInThroughConsentPage(t, ut.app, params(nil), fields); w.Code != http.StatusUnauthorized || csrfToken.FindString(w.Body.String()) == "" {
+			t.Fatalf("\t%s\tShould serve the consent page again for wrong credentials. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould let users retry wrong credentials.", tests.Success)
+
+		fields = url.Values{"email": {"user@example.com"}, "password": {"password"}}
+		if w := signInThroughConsentPage(t, ut.app, params(nil), fields); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 without consent. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould require the consent of the user.", tests.Success)
+
+		if q := authorize("deny"); q.Get("error") != "access_denied" {
+			t.Fatalf("\t%s\tShould redirect with access_denied. Received: %v", tests.Failed, q)
+		}
+		t.Logf("\t%s\tShould let users deny the request.", tests.Success)
+	})
+
+	t.Run("Authorization code flow", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params(nil).Encode(), nil)
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), client.Name) {
+			t.Fatalf("\t%s\tShould serve the consent page. Received: %v %s", tests.Failed, w.Code, w.Body)
+		}
+		if w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Fatalf("\t%s\tShould not let the consent page be framed. Received: %v", tests.Failed, w.Header())
+		}
+		t.Logf("\t%s\tShould serve the consent page.", tests.Success)
+
+		q := authorize("allow")
+		if q.Get("code") == "" || q.Get("state") != "xyz" {
+			t.Fatalf("\t%s\tShould redirect with a code and the state. Received: %v", tests.Failed, q)
+		}
+		t.Logf("\t%s\tShould issue a code.", tests.Success)
+
+		w, resp := exchange(q.Get("code"), verifier)
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		claims, err := test.Auth.ValidateToken(resp["access_token"].(string))
+		if err != nil || claims.Subject != tests.UserID || claims.ClientID != client.ID || claims.Scope != "users" {
+			t.Fatalf("\t%s\tShould receive a token of the user for the client : %v %+v", tests.Failed, err, claims)
+		}
+		t.Logf("\t%s\tShould exchange the code for tokens.", tests.Success)
+
+		refreshToken := resp["refresh_token"].(string)
+		if w, resp := exchange(q.Get("code"), verifier); w.Code != http.StatusBadRequest || resp["error"] != "invalid_grant" {
+			t.Fatalf("\t%s\tShould reject a reused code. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		if w, _ := token(url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ID}, "refresh_token": {refreshToken}}); w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould revoke the tokens issued for a reused code. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould revoke the tokens issued for a reused code.", tests.Success)
+
+		// Consent was already given.
+		q = authorize("")
+		if w, resp := exchange(q.Get("code"), strings.Repeat("a", 43)); w.Code != http.StatusBadRequest || resp["error"] != "invalid_grant" {
+			t.Fatalf("\t%s\tShould reject a wrong code verifier. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		t.Logf("\t%s\tShould check the code verifier.", tests.Success)
+	})
+
+	t.Run("Scope of client tokens", func(tt *testing.T) {
+		fields := url.Values{"email": {"admin@example.com"}, "password": {"password"}, "consent": {"allow"}}
+		w := signInThroughConsentPage(t, ut.app, params(nil), fields)
+		location, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || w.Code != http.StatusFound {
+			t.Fatalf("\t%s\tShould receive a status code of 302 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		_, resp := exchange(location.Query().Get("code"), verifier)
+		accessToken, _ := resp["access_token"].(string)
+
+		for _, target := range []string{"/v1/users?limit=10", "/v1/users/" + tests.AdminID} {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.Header.Set("Authorization", "Bearer "+accessToken)
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tShould receive a status code of 403 for %s. Received: %v", tests.Failed, target, w.Code)
+			}
+		}
+		t.Logf("\t%s\tShould limit client tokens to the scopes the user consented to.", tests.Success)
+	})
+
+	t.Run("Refresh token grant", func(tt *testing.T) {
+		q := authorize("")
+		_, resp := exchange(q.Get("code"), verifier)
+
+		form := url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ID}, "refresh_token": {resp["refresh_token"].(string)}}
+		w, resp := token(form)
+		if w.Code != http.StatusOK || resp["scope"] != "users" {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		t.Logf("\t%s\tShould refresh the tokens of the client.", tests.Success)
+
+		stolen := resp["refresh_token"].(string)
+		form.Set("refresh_token", stolen)
+		form.Set("client_id", "00000000-0000-0000-0000-000000000000")
+		if w, resp := token(form); w.Code != http.StatusBadRequest || resp["error"] != "invalid_grant" {
+			t.Fatalf("\t%s\tShould reject other clients. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		t.Logf("\t%s\tShould only refresh the tokens for the client they were issued to.", tests.Success)
+
+		const family = `SELECT count(*) FROM refresh_tokens WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`
+		sum := sha256.Sum256([]byte(stolen))
+		var active int
+		if err := test.DB.Get(&active, family, hex.EncodeToString(sum[:])); err != nil || active != 0 {
+			t.Fatalf("\t%s\tShould revoke the family of refused tokens : %d, %v", tests.Failed, active, err)
+		}
+		t.Logf("\t%s\tShould revoke the family of refused tokens.", tests.Success)
+	})
+}
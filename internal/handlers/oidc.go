@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// providerMetadata describes the service as an OpenID Connect provider
+// (OpenID Connect Discovery section 3).
+type providerMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type oidcHandler struct {
+	svc    service.UserService
+	auth   *auth.Auth
+	issuer string
+}
+
+// configuration returns the metadata OpenID Connect clients discover the
+// endpoints and capabilities of the service with.
+func (oh oidcHandler) configuration(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.oidcHandler.configuration")
+	defer span.End()
+
+	base := strings.TrimSuffix(oh.issuer, "/")
+	md := providerMetadata{
+		Issuer:                            oh.issuer,
+		AuthorizationEndpoint:             base + "/oauth/authorize",
+		TokenEndpoint:                     base + "/oauth/token",
+		UserInfoEndpoint:                  base + "/userinfo",
+		JWKSURI:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail, auth.ScopeAddress},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{oh.auth.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "given_name", "family_name", "updated_at", "email", "email_verified", "address",
+		},
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	return web.Respond(ctx, w, md, http.StatusOK)
+}
+
+// userinfo returns the claims about the User of an access token released by
+// the scopes granted to its client, which must include openid.
+func (oh oidcHandler) userinfo(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.oidcHandler.userinfo")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !containsScope(scopes, auth.ScopeOpenID) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		return web.NewRequestError(service.ErrForbidden, http.StatusForbidden)
+	}
+
+	u, err := oh.svc.GetByID(ctx, v.TraceID, claims, claims.Subject)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID, service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", claims.Subject)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	return web.Respond(ctx, w, service.UserInfo(u, scopes), http.StatusOK)
+}
+
+// containsScope reports whether scope is one of scopes.
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
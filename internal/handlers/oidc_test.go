@@ -0,0 +1,166 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestOIDC(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	const issuer = "https://auth.example.com"
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{Issuer: issuer})
+	app := handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, issuer)
+
+	const redirectURI = "https://app.example.com/callback"
+	client := service.Client{
+		ID:           "2f4c6f1e-8f0e-4a55-9d3b-5d8a1c0e7b42",
+		Name:         "Example App",
+		RedirectURIs: []string{redirectURI},
+		Scopes:       []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail, "users"},
+		DateCreated:  time.Now(),
+		DateUpdated:  time.Now(),
+	}
+	if err := ur.CreateClient(context.Background(), client); err != nil {
+		t.Fatalf("\t%s\tShould be able to create a client : %v", tests.Failed, err)
+	}
+
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	// signIn goes through the authorization code flow for the scopes and
+	// returns the response of the token endpoint.
+	signIn := func(scope string) map[string]interface{} {
+		params := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {client.ID},
+			"redirect_uri":          {redirectURI},
+			"scope":                 {scope},
+			"nonce":                 {"n-0S6_WzA2Mj"},
+			"code_challenge":        {challenge},
+			"code_challenge_method": {"S256"},
+		}
+		fields := url.Values{"email": {"user@example.com"}, "password": {"password"}, "consent": {"allow"}}
+		w := signInThroughConsentPage(t, app, params, fields)
+		location, err := url.Parse(w.Header().Get("Location"))
+		if w.Code != http.StatusFound || err != nil || location.Query().Get("code") == "" {
+			t.Fatalf("\t%s\tShould be redirected with a code. Received: %v %v", tests.Failed, w.Code, location)
+		}
+
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {client.ID},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}
+		r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould exchange the code for tokens. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		return resp
+	}
+	userinfo := func(token string) (*httptest.ResponseRecorder, auth.UserInfo) {
+		r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		var info auth.UserInfo
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+		}
+		return w, info
+	}
+
+	t.Run("Discovery", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		var md map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&md); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if md["issuer"] != issuer || md["userinfo_endpoint"] != issuer+"/userinfo" || md["jwks_uri"] != issuer+"/.well-known/jwks.json" {
+			t.Fatalf("\t%s\tShould describe the endpoints of the issuer. Received: %v", tests.Failed, md)
+		}
+		t.Logf("\t%s\tShould describe the provider.", tests.Success)
+	})
+
+	t.Run("ID token and UserInfo", func(tt *testing.T) {
+		resp := signIn("openid profile email")
+
+		idToken, _ := resp["id_token"].(string)
+		if _, err := test.Auth.ValidateToken(idToken); err != nil {
+			t.Fatalf("\t%s\tShould receive a signed ID token : %v", tests.Failed, err)
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(idToken, ".")[1])
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to decode the ID token : %v", tests.Failed, err)
+		}
+		var claims auth.IDClaims
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the ID token : %v", tests.Failed, err)
+		}
+		if claims.Issuer != issuer || claims.Subject != tests.UserID || claims.Audience != client.ID || claims.Nonce != "n-0S6_WzA2Mj" || claims.AuthTime == 0 {
+			t.Fatalf("\t%s\tShould identify the user for the client. Received: %+v", tests.Failed, claims)
+		}
+		if claims.Email != "user@example.com" || claims.EmailVerified == nil || claims.GivenName == "" {
+			t.Fatalf("\t%s\tShould carry the claims of the scopes. Received: %+v", tests.Failed, claims.Profile)
+		}
+		t.Logf("\t%s\tShould issue an ID token.", tests.Success)
+
+		w, info := userinfo(resp["access_token"].(string))
+		if w.Code != http.StatusOK || info.Subject != tests.UserID || info.Email != "user@example.com" || info.Name == "" {
+			t.Fatalf("\t%s\tShould describe the user. Received: %v %+v", tests.Failed, w.Code, info)
+		}
+		t.Logf("\t%s\tShould describe the user.", tests.Success)
+	})
+
+	t.Run("Scopes", func(tt *testing.T) {
+		resp := signIn("openid email")
+
+		w, info := userinfo(resp["access_token"].(string))
+		if w.Code != http.StatusOK || info.Email == "" || info.Name != "" {
+			t.Fatalf("\t%s\tShould only release the claims of the scopes. Received: %v %+v", tests.Failed, w.Code, info)
+		}
+		t.Logf("\t%s\tShould only release the claims of the scopes.", tests.Success)
+
+		resp = signIn("users")
+		if _, ok := resp["id_token"]; ok {
+			t.Fatalf("\t%s\tShould not issue an ID token without the openid scope.", tests.Failed)
+		}
+		if w, _ := userinfo(resp["access_token"].(string)); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 without the openid scope. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould require the openid scope.", tests.Success)
+	})
+}
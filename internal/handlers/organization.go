@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type organizationHandler struct {
+	svc service.UserService
+}
+
+func (oh organizationHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.organizationHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	orgs, err := oh.svc.QueryOrganizations(ctx, v.TraceID, claims)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying organizations")
+		}
+	}
+
+	return web.Respond(ctx, w, orgs, http.StatusOK)
+}
+
+func (oh organizationHandler) getByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.organizationHandler.getByID")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	org, err := oh.svc.GetOrganization(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, org, http.StatusOK)
+}
+
+func (oh organizationHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.organizationHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nor service.NewOrganizationRequest
+	if err := web.Decode(r, &nor); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	org, err := oh.svc.CreateOrganization(ctx, v.TraceID, claims, nor, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrDuplicatedOrganization:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Organization: %+v", &nor)
+		}
+	}
+
+	return web.Respond(ctx, w, org, http.StatusCreated)
+}
+
+func (oh organizationHandler) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.organizationHandler.update")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var uor service.UpdateOrganizationRequest
+	if err := web.Decode(r, &uor); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := oh.svc.UpdateOrganization(ctx, v.TraceID, claims, params["id"], uor, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrDuplicatedOrganization:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s  Organization: %+v", params["id"], &uor)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (oh organizationHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.organizationHandler.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := oh.svc.DeleteOrganization(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrOrganizationInUse:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (oh organizationHandler) createUser(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.organizationHandler.createUser")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nur service.NewUserRequest
+	if err := web.Decode(r, &nur); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	usr, err := oh.svc.CreateInOrganization(ctx, v.TraceID, claims, params["id"], nur, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID, service.ErrDuplicatedEmail:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, usr, http.StatusCreated)
+}
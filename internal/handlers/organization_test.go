@@ -0,0 +1,114 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestOrganizations(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+	superAdminToken := test.SuperAdminToken()
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Forbidden (tenant admin)", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/organizations", `{"name":"Acme"}`, ut.adminToken)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Own organization", func(tt *testing.T) {
+		w := do(http.MethodGet, "/v1/organizations/"+service.DefaultTenantID, "", ut.userToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould allow users to retrieve their own organization.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/organizations", `{"name":"Acme"}`, superAdminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var org service.Organization
+		if err := json.NewDecoder(w.Body).Decode(&org); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould create the organization.", tests.Success)
+
+		if w := do(http.MethodPost, "/v1/organizations", `{"name":"Acme"}`, superAdminToken); w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tShould receive a status code of 409 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not create two organizations with the same name.", tests.Success)
+
+		body := `{"name":"Wile","email":"wile@acme.com","last_name":"Coyote","country":"United States",` +
+			`"password":"password","password_confirm":"password"}`
+		if w := do(http.MethodPost, "/v1/organizations/"+org.ID+"/users", body, ut.adminToken); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not allow admins to add users to other organizations.", tests.Success)
+
+		w = do(http.MethodPost, "/v1/organizations/"+org.ID+"/users", body, superAdminToken)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var u service.User
+		if err := json.NewDecoder(w.Body).Decode(&u); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if u.TenantID != org.ID {
+			t.Fatalf("\t%s\tShould add the user to the organization : got tenant %s", tests.Failed, u.TenantID)
+		}
+		t.Logf("\t%s\tShould add the user to the organization.", tests.Success)
+
+		if w := do(http.MethodGet, "/v1/users/"+u.ID, "", ut.adminToken); w.Code != http.StatusNotFound {
+			t.Fatalf("\t%s\tShould receive a status code of 404 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not let admins see users of other organizations.", tests.Success)
+
+		if w := do(http.MethodGet, "/v1/users/"+u.ID, "", superAdminToken); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould let super admins see users of every organization.", tests.Success)
+
+		if w := do(http.MethodDelete, "/v1/organizations/"+org.ID, "", superAdminToken); w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tShould receive a status code of 409 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not delete organizations with users.", tests.Success)
+	})
+}
@@ -0,0 +1,84 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestRefreshToken(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app: handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid: test.KID,
+	}
+
+	type tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	do := func(method, target, body string) (*httptest.ResponseRecorder, tokens) {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+
+		var tkn tokens
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+			}
+		}
+		return w, tkn
+	}
+	refresh := func(token string) (*httptest.ResponseRecorder, tokens) {
+		return do(http.MethodPost, "/v1/users/token/refresh", `{"refresh_token":"`+token+`","kid":"`+ut.kid+`"}`)
+	}
+
+	t.Run("Unauthorized (unknown token)", func(tt *testing.T) {
+		if w, _ := refresh("unknown"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould reject unknown refresh tokens.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		w, login := do(http.MethodGet, "/v1/users/token/"+ut.kid, `{"email":"admin@example.com","password":"password"}`)
+		if w.Code != http.StatusOK || login.RefreshToken == "" {
+			t.Fatalf("\t%s\tShould receive a refresh token along with the token. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a refresh token along with the token.", tests.Success)
+
+		w, rotated := refresh(login.RefreshToken)
+		if w.Code != http.StatusOK || rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+			t.Fatalf("\t%s\tShould rotate the refresh token. Received: %v", tests.Failed, w.Code)
+		}
+		claims, err := test.Auth.ValidateToken(rotated.Token)
+		if err != nil || claims.Subject != tests.AdminID {
+			t.Fatalf("\t%s\tShould receive a token of the user : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould rotate the refresh token.", tests.Success)
+
+		if w, _ := refresh(login.RefreshToken); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for a reused token. Received: %v", tests.Failed, w.Code)
+		}
+		if w, _ := refresh(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for the rest of the family. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould revoke the family of a reused token.", tests.Success)
+	})
+}
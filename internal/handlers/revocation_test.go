@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestRevocation(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	denylist := auth.NewMemoryDenylist()
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{Denylist: denylist})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, denylist, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest(method, target, nil)
+		} else {
+			r = httptest.NewRequest(method, target, strings.NewReader(body))
+		}
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+token)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+	login := func(email string) (token, refreshToken string) {
+		w := do(http.MethodGet, "/v1/users/token/"+ut.kid, `{"email":"`+email+`","password":"password"}`, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		var tkn struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		return tkn.Token, tkn.RefreshToken
+	}
+	refresh := func(refreshToken string) int {
+		return do(http.MethodPost, "/v1/users/token/refresh", `{"refresh_token":"`+refreshToken+`","kid":"`+ut.kid+`"}`, "").Code
+	}
+
+	t.Run("Logout", func(tt *testing.T) {
+		token, refreshToken := login("admin@example.com")
+
+		if w := do(http.MethodPost, "/v1/users/logout", `{"refresh_token":"`+refreshToken+`"}`, token); w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if w := do(http.MethodGet, "/v1/users/"+tests.AdminID, "", token); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for the revoked token. Received: %v", tests.Failed, w.Code)
+		}
+		if code := refresh(refreshToken); code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for the revoked refresh token. Received: %v", tests.Failed, code)
+		}
+		if w := do(http.MethodGet, "/v1/users/"+tests.AdminID, "", ut.adminToken); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for other tokens. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould only revoke the tokens of the session.", tests.Success)
+	})
+
+	t.Run("Forbidden (user)", func(tt *testing.T) {
+		w := do(http.MethodPost, "/v1/users/"+tests.AdminID+"/tokens/revoke", "", ut.userToken)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not let users revoke the tokens of others.", tests.Success)
+	})
+
+	t.Run("Revoke all tokens", func(tt *testing.T) {
+		_, refreshToken := login("user@example.com")
+
+		if w := do(http.MethodPost, "/v1/users/"+tests.UserID+"/tokens/revoke", "", ut.adminToken); w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if w := do(http.MethodGet, "/v1/users/"+tests.UserID, "", ut.userToken); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for the revoked token. Received: %v", tests.Failed, w.Code)
+		}
+		if code := refresh(refreshToken); code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for the revoked refresh token. Received: %v", tests.Failed, code)
+		}
+		t.Logf("\t%s\tShould revoke every token of the user.", tests.Success)
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type roleHandler struct {
+	svc service.UserService
+}
+
+func (rh roleHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.roleHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	roles, err := rh.svc.QueryRoles(ctx, v.TraceID, claims)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying roles")
+		}
+	}
+
+	return web.Respond(ctx, w, roles, http.StatusOK)
+}
+
+func (rh roleHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.roleHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nr service.NewRoleRequest
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	role, err := rh.svc.CreateRole(ctx, v.TraceID, claims, nr, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrUnknownPermission:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrDuplicatedRole:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Role: %+v", &nr)
+		}
+	}
+
+	return web.Respond(ctx, w, role, http.StatusCreated)
+}
+
+func (rh roleHandler) update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.roleHandler.update")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var urr service.UpdateRoleRequest
+	if err := web.Decode(r, &urr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := rh.svc.UpdateRole(ctx, v.TraceID, claims, params["name"], urr); err != nil {
+		switch err {
+		case service.ErrUnknownPermission:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Name: %s  Permissions: %v", params["name"], urr.Permissions)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (rh roleHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.roleHandler.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := rh.svc.DeleteRole(ctx, v.TraceID, claims, params["name"]); err != nil {
+		switch err {
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrRoleInUse:
+			return web.NewRequestError(err, http.StatusConflict)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "Name: %s", params["name"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestRoles(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+	superAdminToken := test.SuperAdminToken()
+
+	t.Run("Forbidden (user)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/roles", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Forbidden (other user)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/"+tests.AdminID, nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Bad request (unknown permission)", func(tt *testing.T) {
+		body := `{"name":"SUPPORT","permissions":["users:admin"]}`
+		r := httptest.NewRequest(http.MethodPost, "/v1/roles", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+superAdminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		body := `{"name":"SUPPORT","permissions":["users:read"]}`
+		r := httptest.NewRequest(http.MethodPost, "/v1/roles", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+superAdminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 201 for the response.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodPut, "/v1/users/"+tests.UserID+"/roles", strings.NewReader(`{"roles":["SUPPORT"]}`))
+		w = httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould assign the new role.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodDelete, "/v1/roles/SUPPORT", nil)
+		w = httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+superAdminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tShould receive a status code of 409 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould not delete roles assigned to users.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodGet, "/v1/roles", nil)
+		w = httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		var roles []service.Role
+		if err := json.NewDecoder(w.Body).Decode(&roles); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		var found bool
+		for _, role := range roles {
+			if role.Name == "SUPPORT" && len(role.Permissions) == 1 && role.Permissions[0] == auth.PermUsersRead {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("\t%s\tShould list the new role : got %+v", tests.Failed, roles)
+		}
+		t.Logf("\t%s\tShould list the new role.", tests.Success)
+	})
+}
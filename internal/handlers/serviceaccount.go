@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/web"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// credentials are the client credentials of a service account, returned
+// when its secret is generated. Secrets are only ever shown once.
+type credentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+type serviceAccountHandler struct {
+	svc service.UserService
+}
+
+func (sh serviceAccountHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.serviceAccountHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	accounts, err := sh.svc.QueryServiceAccounts(ctx, v.TraceID, claims)
+	if err != nil {
+		switch err {
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying service accounts")
+		}
+	}
+
+	return web.Respond(ctx, w, accounts, http.StatusOK)
+}
+
+func (sh serviceAccountHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.serviceAccountHandler.create")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nsr service.NewServiceAccountRequest
+	if err := web.Decode(r, &nsr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	sa, secret, err := sh.svc.CreateServiceAccount(ctx, v.TraceID, claims, nsr, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrUnknownPermission, service.ErrServiceAccountPermission:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ServiceAccount: %+v", &nsr)
+		}
+	}
+
+	resp := struct {
+		service.ServiceAccount
+		credentials
+	}{sa, credentials{ClientID: sa.ID, ClientSecret: secret}}
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+func (sh serviceAccountHandler) rotateSecret(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.serviceAccountHandler.rotateSecret")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	secret, err := sh.svc.RotateServiceAccountSecret(ctx, v.TraceID, claims, params["id"], v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	resp := credentials{
+		ClientID:     params["id"],
+		ClientSecret: secret,
+	}
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+func (sh serviceAccountHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.serviceAccountHandler.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := sh.svc.DeleteServiceAccount(ctx, v.TraceID, claims, params["id"]); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/santiagoh1997/service-template/internal/handlers"
+	"github.com/santiagoh1997/service-template/internal/repository"
+	"github.com/santiagoh1997/service-template/internal/service"
+	"github.com/santiagoh1997/service-template/internal/tests"
+)
+
+func TestServiceAccounts(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		adminToken: test.Token("admin@example.com", "password"),
+		userToken:  test.Token("user@example.com", "password"),
+	}
+
+	request := func(method, target, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+	token := func(form url.Values, id, secret string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if id != "" {
+			r.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+		}
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		return w, resp
+	}
+
+	var creds struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+
+	t.Run("Create", func(tt *testing.T) {
+		body := `{"name":"Nightly export","permissions":["users:read"]}`
+		if w := request(http.MethodPost, "/v1/service-accounts", ut.userToken, body); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for users. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould only let admins create service accounts.", tests.Success)
+
+		if w := request(http.MethodPost, "/v1/service-accounts", ut.adminToken, `{"name":"Export","permissions":["organizations:manage"]}`); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for permissions the admin lacks. Received: %v", tests.Failed, w.Code)
+		}
+		if w := request(http.MethodPost, "/v1/service-accounts", ut.adminToken, `{"name":"Export","permissions":["users:impersonate"]}`); w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for permissions of users. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould only grant the permissions service accounts can hold.", tests.Success)
+
+		w := request(http.MethodPost, "/v1/service-accounts", ut.adminToken, body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould receive a status code of 201 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if err := json.NewDecoder(w.Body).Decode(&creds); err != nil || creds.ClientID == "" || creds.ClientSecret == "" {
+			t.Fatalf("\t%s\tShould receive the credentials of the service account : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould create a service account.", tests.Success)
+	})
+
+	t.Run("Client credentials grant", func(tt *testing.T) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if w, resp := token(form, creds.ClientID, "wrong"); w.Code != http.StatusUnauthorized || resp["error"] != "invalid_client" || w.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("\t%s\tShould receive a status code of 401 for a wrong secret. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		t.Logf("\t%s\tShould check the secret.", tests.Success)
+
+		form.Set("scope", "audit:read")
+		if w, resp := token(form, creds.ClientID, creds.ClientSecret); w.Code != http.StatusBadRequest || resp["error"] != "invalid_scope" {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for permissions the account lacks. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		t.Logf("\t%s\tShould only grant the permissions of the account.", tests.Success)
+
+		form = url.Values{"grant_type": {"client_credentials"}, "client_id": {creds.ClientID}, "client_secret": {creds.ClientSecret}}
+		w, resp := token(form, "", "")
+		if w.Code != http.StatusOK || resp["scope"] != "users:read" || resp["refresh_token"] != nil {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		accessToken := resp["access_token"].(string)
+		claims, err := test.Auth.ValidateToken(accessToken)
+		if err != nil || !claims.Machine || claims.Subject != creds.ClientID {
+			t.Fatalf("\t%s\tShould receive a machine token : %v %+v", tests.Failed, err, claims)
+		}
+		t.Logf("\t%s\tShould issue machine tokens.", tests.Success)
+
+		if w := request(http.MethodGet, "/v1/users?limit=10", accessToken, ""); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for granted permissions. Received: %v", tests.Failed, w.Code)
+		}
+		if w := request(http.MethodGet, "/v1/roles", accessToken, ""); w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for other permissions. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould authorize machine tokens by their scope.", tests.Success)
+
+		w = request(http.MethodPost, "/v1/service-accounts/"+creds.ClientID+"/secret", ut.adminToken, "")
+		var rotated struct {
+			ClientSecret string `json:"client_secret"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&rotated); err != nil || w.Code != http.StatusOK || rotated.ClientSecret == creds.ClientSecret {
+			t.Fatalf("\t%s\tShould rotate the secret. Received: %v", tests.Failed, w.Code)
+		}
+		if w, _ := token(url.Values{"grant_type": {"client_credentials"}}, creds.ClientID, creds.ClientSecret); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould reject the previous secret. Received: %v", tests.Failed, w.Code)
+		}
+		if w := request(http.MethodGet, "/v1/users?limit=10", accessToken, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould revoke the tokens issued with the previous secret. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould rotate the secret.", tests.Success)
+
+		w, resp = token(url.Values{"grant_type": {"client_credentials"}}, creds.ClientID, rotated.ClientSecret)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the new secret. Received: %v %v", tests.Failed, w.Code, resp)
+		}
+		accessToken = resp["access_token"].(string)
+
+		if w := request(http.MethodDelete, "/v1/service-accounts/"+creds.ClientID, ut.adminToken, ""); w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		if w := request(http.MethodGet, "/v1/users?limit=10", accessToken, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould revoke the tokens of deleted accounts. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould revoke the tokens of deleted accounts.", tests.Success)
+	})
+}
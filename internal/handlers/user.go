@@ -1,11 +1,22 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/cursor"
+	"github.com/santiagoh1997/service-template/internal/pkg/patch"
 	"github.com/santiagoh1997/service-template/internal/pkg/web"
 	"github.com/santiagoh1997/service-template/internal/service"
 	"go.opentelemetry.io/otel/trace"
@@ -18,9 +29,13 @@ var (
 	ErrWebValuesMissing = web.NewShutdownError("web value missing from context")
 )
 
+// maxPatchSize is the largest body accepted when patching a User.
+const maxPatchSize = 1 << 16
+
 type userHandler struct {
-	svc  service.UserService
-	auth *auth.Auth
+	svc     service.UserService
+	auth    *auth.Auth
+	cursors *cursor.Signer
 }
 
 func (uh userHandler) getByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -52,9 +67,153 @@ func (uh userHandler) getByID(ctx context.Context, w http.ResponseWriter, r *htt
 		}
 	}
 
+	tag := etag(usr.Version)
+	w.Header().Set("ETag", tag)
+
+	if noneMatch(r, tag) {
+		return web.Respond(ctx, w, nil, http.StatusNotModified)
+	}
+
 	return web.Respond(ctx, w, usr, http.StatusOK)
 }
 
+func (uh userHandler) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.query")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	qs := r.URL.Query()
+
+	filter := service.QueryFilter{
+		Country:     qs.Get("country"),
+		Role:        qs.Get("role"),
+		EmailPrefix: qs.Get("email"),
+	}
+
+	var err error
+	if s := qs.Get("created_after"); s != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, s); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "invalid created_after"), http.StatusBadRequest)
+		}
+	}
+	if s := qs.Get("created_before"); s != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, s); err != nil {
+			return web.NewRequestError(errors.Wrap(err, "invalid created_before"), http.StatusBadRequest)
+		}
+	}
+
+	rowsPerPage, err := intParam(qs, "limit", service.DefaultRowsPerPage)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	// When a cursor is provided the page following it is seeked, otherwise
+	// the page is retrieved by its number. Cursors are only valid for the
+	// filter they were issued for and carry their own ordering.
+	var qr service.QueryResult
+	query := filterQuery(qs)
+	if token := qs.Get("cursor"); token != "" {
+		var after service.Cursor
+		if err := uh.cursors.DecodeFor(token, query, &after); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		if qs.Get("page") != "" {
+			return web.NewRequestError(errors.New("page can't be used with a cursor"), http.StatusBadRequest)
+		}
+		if s := qs.Get("order_by"); s != "" {
+			orderBy, err := service.ParseOrderBy(s)
+			if err != nil {
+				return web.NewRequestError(err, http.StatusBadRequest)
+			}
+			if orderBy.Field != service.OrderByDateCreated || orderBy.Direction != after.Direction {
+				return web.NewRequestError(cursor.ErrQueryMismatch, http.StatusBadRequest)
+			}
+		}
+		qr, err = uh.svc.QueryAfter(ctx, v.TraceID, claims, filter, after, rowsPerPage)
+	} else {
+		var orderBy service.OrderBy
+		if orderBy, err = service.ParseOrderBy(qs.Get("order_by")); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		var page int
+		if page, err = intParam(qs, "page", 1); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		qr, err = uh.svc.Query(ctx, v.TraceID, claims, filter, orderBy, page, rowsPerPage)
+	}
+	if err != nil {
+		switch err {
+		case service.ErrInvalidOrderBy, service.ErrInvalidPagination, service.ErrInvalidCursor:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "querying users")
+		}
+	}
+
+	resp := struct {
+		service.QueryResult
+		NextCursor string `json:"next_cursor,omitempty"`
+	}{
+		QueryResult: qr,
+	}
+
+	if qr.Next != nil {
+		if resp.NextCursor, err = uh.cursors.EncodeFor(query, qr.Next); err != nil {
+			return errors.Wrap(err, "encoding cursor")
+		}
+		w.Header().Set("Link", nextLink(r, resp.NextCursor))
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+func (uh userHandler) search(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.search")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	qs := r.URL.Query()
+
+	limit, err := intParam(qs, "limit", service.DefaultRowsPerPage)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	users, err := uh.svc.Search(ctx, v.TraceID, claims, qs.Get("q"), limit)
+	if err != nil {
+		switch err {
+		case service.ErrEmptySearch, service.ErrInvalidPagination:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "searching %q", qs.Get("q"))
+		}
+	}
+
+	return web.Respond(ctx, w, users, http.StatusOK)
+}
+
 func (uh userHandler) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.create")
 	defer span.End()
@@ -102,7 +261,13 @@ func (uh userHandler) update(ctx context.Context, w http.ResponseWriter, r *http
 	}
 
 	params := web.Params(r)
-	if err := uh.svc.Update(ctx, v.TraceID, claims, params["id"], uur, v.Now); err != nil {
+	version, err := uh.matchedVersion(ctx, v.TraceID, claims, r, params["id"])
+	if err != nil {
+		return err
+	}
+
+	updated, err := uh.svc.Update(ctx, v.TraceID, claims, params["id"], uur, version, v.Now)
+	if err != nil {
 		switch err {
 		case service.ErrInvalidID:
 			return web.NewRequestError(err, http.StatusBadRequest)
@@ -110,16 +275,19 @@ func (uh userHandler) update(ctx context.Context, w http.ResponseWriter, r *http
 			return web.NewRequestError(err, http.StatusNotFound)
 		case service.ErrForbidden:
 			return web.NewRequestError(err, http.StatusForbidden)
+		case service.ErrConflict:
+			return web.NewRequestError(err, conflictStatus(r))
 		default:
 			return errors.Wrapf(err, "ID: %s  User: %+v", params["id"], &uur)
 		}
 	}
 
+	w.Header().Set("ETag", etag(updated))
 	return web.Respond(ctx, w, nil, http.StatusOK)
 }
 
-func (uh userHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.delete")
+func (uh userHandler) patch(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.patch")
 	defer span.End()
 
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
@@ -132,14 +300,128 @@ func (uh userHandler) delete(ctx context.Context, w http.ResponseWriter, r *http
 		return errors.New("claims missing from context")
 	}
 
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType) {
+		err := errors.Errorf("content type must be %s or %s", patch.MergePatchType, patch.JSONPatchType)
+		return web.NewRequestError(err, http.StatusUnsupportedMediaType)
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		if len(body) == maxPatchSize {
+			err := errors.Errorf("payload must not exceed %d bytes", maxPatchSize)
+			return web.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		}
+		return errors.Wrap(err, "reading payload")
+	}
+
+	versions, err := ifMatch(r)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusPreconditionFailed)
+	}
+
 	params := web.Params(r)
-	err := uh.svc.Delete(ctx, v.TraceID, claims, params["id"])
+	usr, err := uh.svc.GetByID(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	if !matchesVersion(versions, usr.Version) {
+		return web.NewRequestError(service.ErrConflict, http.StatusPreconditionFailed)
+	}
+
+	// The patch is applied to the updatable fields of the User and the
+	// result is validated as if it was sent in a PUT request.
+	doc, err := json.Marshal(service.UpdateUserRequest{
+		Name:     usr.Name,
+		LastName: usr.LastName,
+		Country:  usr.Country,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshaling user")
+	}
+
+	patched, err := patch.Apply(mediaType, doc, body)
+	if err != nil {
+		switch errors.Cause(err) {
+		case patch.ErrInvalidPatch:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case patch.ErrTestFailed:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "applying patch to %s", params["id"])
+		}
+	}
+
+	var uur service.UpdateUserRequest
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&uur); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	if err := web.Validate(&uur); err != nil {
+		return err
+	}
+
+	// The User is only patched if it didn't change since it was read, so
+	// concurrent patches don't overwrite each other.
+	version, err := uh.svc.Patch(ctx, v.TraceID, claims, params["id"], service.NewPatchUserRequest(usr, uur), usr.Version, v.Now)
 	if err != nil {
 		switch err {
 		case service.ErrInvalidID:
 			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
 		case service.ErrForbidden:
 			return web.NewRequestError(err, http.StatusForbidden)
+		case service.ErrConflict:
+			return web.NewRequestError(err, conflictStatus(r))
+		default:
+			return errors.Wrapf(err, "ID: %s  User: %+v", params["id"], &uur)
+		}
+	}
+
+	w.Header().Set("ETag", etag(version))
+	return web.Respond(ctx, w, nil, http.StatusOK)
+}
+
+func (uh userHandler) changePassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.changePassword")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var cpr service.ChangePasswordRequest
+	if err := web.Decode(r, &cpr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := uh.svc.ChangePassword(ctx, v.TraceID, claims, params["id"], cpr, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID, service.ErrIncorrectPassword:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
 		default:
 			return errors.Wrapf(err, "ID: %s", params["id"])
 		}
@@ -148,8 +430,8 @@ func (uh userHandler) delete(ctx context.Context, w http.ResponseWriter, r *http
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
-func (uh userHandler) token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.token")
+func (uh userHandler) forgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.forgotPassword")
 	defer span.End()
 
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
@@ -157,30 +439,644 @@ func (uh userHandler) token(ctx context.Context, w http.ResponseWriter, r *http.
 		return ErrWebValuesMissing
 	}
 
-	var lr service.LoginRequest
-	if err := web.Decode(r, &lr); err != nil {
+	var fpr service.ForgotPasswordRequest
+	if err := web.Decode(r, &fpr); err != nil {
 		return errors.Wrapf(err, "unable to decode payload")
 	}
 
-	claims, err := uh.svc.Authenticate(ctx, v.TraceID, v.Now, lr.Email, lr.Password)
-	if err != nil {
+	// The response is the same whether the email belongs to a User or not.
+	if err := uh.svc.ForgotPassword(ctx, v.TraceID, fpr.Email, v.Now); err != nil {
+		return errors.Wrap(err, "requesting password reset")
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+func (uh userHandler) resetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.resetPassword")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	var rpr service.ResetPasswordRequest
+	if err := web.Decode(r, &rpr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	if err := uh.svc.ResetPassword(ctx, v.TraceID, rpr, v.Now); err != nil {
 		switch err {
-		case service.ErrAuthenticationFailure:
-			return web.NewRequestError(err, http.StatusUnauthorized)
+		case service.ErrInvalidResetToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
 		default:
-			return errors.Wrap(err, "authenticating")
+			return errors.Wrap(err, "resetting password")
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh userHandler) verifyEmail(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.verifyEmail")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	var ver service.VerifyEmailRequest
+	if err := web.Decode(r, &ver); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	if err := uh.svc.VerifyEmail(ctx, v.TraceID, ver.Token, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidVerificationToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrap(err, "verifying email")
 		}
 	}
 
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh userHandler) resendVerification(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.resendVerification")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	var rvr service.ResendVerificationRequest
+	if err := web.Decode(r, &rvr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	// The response is the same whether the email belongs to a User or not.
+	if err := uh.svc.ResendVerification(ctx, v.TraceID, rvr.Email, v.Now); err != nil {
+		return errors.Wrap(err, "resending verification")
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+func (uh userHandler) requestEmailChange(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.requestEmailChange")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var cer service.ChangeEmailRequest
+	if err := web.Decode(r, &cer); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
 	params := web.Params(r)
+	if err := uh.svc.RequestEmailChange(ctx, v.TraceID, claims, params["id"], cer, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID, service.ErrIncorrectPassword, service.ErrDuplicatedEmail:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
 
-	var tkn struct {
-		Token string `json:"token"`
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+func (uh userHandler) confirmEmailChange(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.confirmEmailChange")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
 	}
-	tkn.Token, err = uh.auth.GenerateToken(params["kid"], claims)
-	if err != nil {
-		return errors.Wrap(err, "generating token")
+
+	var cecr service.ConfirmEmailChangeRequest
+	if err := web.Decode(r, &cecr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
 	}
 
-	return web.Respond(ctx, w, tkn, http.StatusOK)
+	if err := uh.svc.ConfirmEmailChange(ctx, v.TraceID, cecr.Token, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidEmailChangeToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrDuplicatedEmail:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrap(err, "confirming email change")
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh userHandler) updateRoles(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.updateRoles")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var urr service.UpdateRolesRequest
+	if err := web.Decode(r, &urr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	params := web.Params(r)
+	if err := uh.svc.UpdateRoles(ctx, v.TraceID, claims, params["id"], urr, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID, service.ErrUnknownRole:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		case service.ErrConflict:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s  Roles: %v", params["id"], urr.Roles)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh userHandler) roleChanges(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.roleChanges")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	changes, err := uh.svc.QueryRoleChanges(ctx, v.TraceID, claims, params["id"])
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrForbidden:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, changes, http.StatusOK)
+}
+
+func (uh userHandler) delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.delete")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	version, err := uh.matchedVersion(ctx, v.TraceID, claims, r, params["id"])
+	if err != nil {
+		return err
+	}
+
+	if err := uh.svc.Delete(ctx, v.TraceID, claims, params["id"], version, v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		case service.ErrConflict:
+			return web.NewRequestError(err, http.StatusPreconditionFailed)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (uh userHandler) restore(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.restore")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := uh.svc.Restore(ctx, v.TraceID, claims, params["id"], v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		case service.ErrDuplicatedEmail:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// token authenticates a User and issues a token signed with the key of the
+// kid parameter, or the active key when there's none, along with a refresh
+// token.
+func (uh userHandler) token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.token")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	var lr service.LoginRequest
+	if err := web.Decode(r, &lr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	claims, err := uh.svc.Authenticate(ctx, v.TraceID, v.Now, lr.Email, lr.Password)
+	if err != nil {
+		switch err {
+		case service.ErrAuthenticationFailure:
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		case service.ErrEmailNotVerified:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "authenticating")
+		}
+	}
+
+	kid := web.Params(r)["kid"]
+	if kid == "" {
+		kid = uh.auth.ActiveKID()
+	}
+
+	var tkn struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	tkn.Token, err = uh.auth.GenerateToken(kid, claims)
+	if err != nil {
+		return errors.Wrap(err, "generating token")
+	}
+	tkn.RefreshToken, err = uh.svc.IssueRefreshToken(ctx, v.TraceID, claims, v.Now)
+	if err != nil {
+		return errors.Wrap(err, "issuing refresh token")
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// refresh exchanges a refresh token for a new access token and the refresh
+// token to use next time. Refresh tokens can only be used once. The access
+// token is signed with the active key unless the request asks for another.
+func (uh userHandler) refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.refresh")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	var rr service.RefreshRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return errors.Wrapf(err, "unable to decode payload")
+	}
+
+	claims, next, err := uh.svc.Refresh(ctx, v.TraceID, rr.RefreshToken, v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidRefreshToken, service.ErrRefreshTokenReused:
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		case service.ErrEmailNotVerified:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "refreshing token")
+		}
+	}
+
+	var tkn struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	kid := rr.KID
+	if kid == "" {
+		kid = uh.auth.ActiveKID()
+	}
+	tkn.Token, err = uh.auth.GenerateToken(kid, claims)
+	if err != nil {
+		return errors.Wrap(err, "generating token")
+	}
+	tkn.RefreshToken = next
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// logout revokes the token of the request. The refresh token issued along
+// with it can be sent in the body to revoke it as well.
+func (uh userHandler) logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.logout")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var lr service.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := web.Decode(r, &lr); err != nil {
+			return errors.Wrapf(err, "unable to decode payload")
+		}
+	}
+
+	if err := uh.svc.Logout(ctx, v.TraceID, claims, lr, v.Now); err != nil {
+		return errors.Wrap(err, "logging out")
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// revokeTokens revokes every token issued to a User so far.
+func (uh userHandler) revokeTokens(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.revokeTokens")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	if err := uh.svc.RevokeTokens(ctx, v.TraceID, claims, params["id"], v.Now); err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// impersonate issues a token for an impersonated session of a User. It's
+// signed with the active key.
+func (uh userHandler) impersonate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "handlers.userHandler.impersonate")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return ErrWebValuesMissing
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	params := web.Params(r)
+	ic, err := uh.svc.Impersonate(ctx, v.TraceID, claims, params["id"], v.Now)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden, service.ErrImpersonating:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "ID: %s", params["id"])
+		}
+	}
+
+	var tkn struct {
+		Token     string     `json:"token"`
+		Actor     auth.Actor `json:"act"`
+		ExpiresAt int64      `json:"expires_at"`
+	}
+	tkn.Token, err = uh.auth.GenerateToken(uh.auth.ActiveKID(), ic)
+	if err != nil {
+		return errors.Wrap(err, "generating token")
+	}
+	tkn.Actor = *ic.Actor
+	tkn.ExpiresAt = ic.ExpiresAt
+
+	return web.Respond(ctx, w, tkn, http.StatusCreated)
+}
+
+// etag returns the entity tag representing a version of a User.
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatch returns the versions of the User a request is conditioned on by its
+// If-Match header. No versions mean the request is unconditional.
+func ifMatch(r *http.Request) ([]int, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return nil, nil
+	}
+
+	// If-Match requires strong comparison, so weak tags never match.
+	var versions []int
+	for _, t := range strings.Split(h, ",") {
+		t = strings.TrimSpace(t)
+		version, err := strconv.Atoi(strings.Trim(t, `"`))
+		if err != nil || version < 1 || t != etag(version) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, errors.Errorf("precondition failed: unknown entity tags %s", h)
+	}
+
+	return versions, nil
+}
+
+// matchedVersion returns the version of the User a request that's checked
+// against it by the service is conditioned on. Zero means the request is
+// unconditional. When the If-Match header lists several entity tags the User
+// is read, and the request is conditioned on the version it was read at.
+func (uh userHandler) matchedVersion(ctx context.Context, traceID string, claims auth.Claims, r *http.Request, userID string) (int, error) {
+	versions, err := ifMatch(r)
+	if err != nil {
+		return 0, web.NewRequestError(err, http.StatusPreconditionFailed)
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, nil
+	case 1:
+		return versions[0], nil
+	}
+
+	usr, err := uh.svc.GetByID(ctx, traceID, claims, userID)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidID:
+			return 0, web.NewRequestError(err, http.StatusBadRequest)
+		case service.ErrNotFound:
+			return 0, web.NewRequestError(err, http.StatusNotFound)
+		case service.ErrForbidden:
+			return 0, web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return 0, errors.Wrapf(err, "ID: %s", userID)
+		}
+	}
+	if !matchesVersion(versions, usr.Version) {
+		return 0, web.NewRequestError(service.ErrConflict, http.StatusPreconditionFailed)
+	}
+
+	return usr.Version, nil
+}
+
+// matchesVersion reports whether a version of a User is one of the versions
+// a request is conditioned on.
+func matchesVersion(versions []int, version int) bool {
+	if len(versions) == 0 {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// noneMatch reports whether the If-None-Match header of a request matches
+// the entity tag, using weak comparison.
+func noneMatch(r *http.Request, tag string) bool {
+	h := r.Header.Get("If-None-Match")
+	if h == "" {
+		return false
+	}
+
+	for _, t := range strings.Split(h, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// conflictStatus returns the status code used when a User was modified
+// concurrently. When the client provided a precondition it's reported as failed.
+func conflictStatus(r *http.Request) int {
+	if r.Header.Get("If-Match") != "" {
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusConflict
+}
+
+// nextLink builds the value of a Link header pointing to the page that
+// follows the current request, keeping the rest of its parameters.
+func nextLink(r *http.Request, token string) string {
+	qs := r.URL.Query()
+	qs.Del("page")
+	qs.Del("order_by")
+	qs.Set("cursor", token)
+
+	u := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+	return fmt.Sprintf(`<%s>; rel="next"`, u.String())
+}
+
+// filterQuery returns the filter parameters of a query in a canonical form,
+// which cursors are bound to.
+func filterQuery(qs url.Values) string {
+	filter := url.Values{}
+	for _, key := range []string{"country", "role", "email", "created_after", "created_before"} {
+		if v := qs.Get(key); v != "" {
+			filter.Set(key, v)
+		}
+	}
+	return filter.Encode()
+}
+
+// intParam parses an integer query string parameter, falling back to def
+// when the parameter isn't present.
+func intParam(qs url.Values, key string, def int) (int, error) {
+	s := qs.Get(key)
+	if s == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid %s: %q", key, s)
+	}
+
+	return n, nil
 }
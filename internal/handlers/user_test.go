@@ -38,9 +38,9 @@ func TestGetUser(t *testing.T) {
 	shutdown := make(chan os.Signal, 1)
 
 	ur, _ := repository.NewRepository(test.DB)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
 	ut := UserTests{
-		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, test.DB),
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
 		kid:        test.KID,
 		userToken:  test.Token("user@example.com", "password"),
 		adminToken: test.Token("admin@example.com", "password"),
@@ -180,9 +180,9 @@ func TestPostUser(t *testing.T) {
 	shutdown := make(chan os.Signal, 1)
 
 	ur, _ := repository.NewRepository(test.DB)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
 	ut := UserTests{
-		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, test.DB),
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
 		kid:        test.KID,
 		userToken:  test.Token("user@example.com", "password"),
 		adminToken: test.Token("admin@example.com", "password"),
@@ -239,7 +239,6 @@ func TestPostUser(t *testing.T) {
 			Email:           "email@email.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -285,7 +284,6 @@ func TestPostUser(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -314,7 +312,8 @@ func TestPostUser(t *testing.T) {
 		want.Name = nur.Name
 		want.LastName = nur.LastName
 		want.Email = nur.Email
-		want.Roles = nur.Roles
+		want.TenantID = service.DefaultTenantID
+		want.Roles = []string{auth.DefaultRole}
 
 		if diff := cmp.Diff(got, want); diff != "" {
 			t.Fatalf("\t%s\tShould get the expected result. Diff:\n%s", tests.Failed, diff)
@@ -330,9 +329,9 @@ func TestPutUser(t *testing.T) {
 	shutdown := make(chan os.Signal, 1)
 
 	ur, _ := repository.NewRepository(test.DB)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
 	ut := UserTests{
-		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, test.DB),
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
 		kid:        test.KID,
 		userToken:  test.Token("user@example.com", "password"),
 		adminToken: test.Token("admin@example.com", "password"),
@@ -497,9 +496,9 @@ func TestDeleteUser(t *testing.T) {
 	shutdown := make(chan os.Signal, 1)
 
 	ur, _ := repository.NewRepository(test.DB)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
 	ut := UserTests{
-		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, test.DB),
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
 		kid:        test.KID,
 		userToken:  test.Token("user@example.com", "password"),
 		adminToken: test.Token("admin@example.com", "password"),
@@ -512,10 +511,10 @@ func TestDeleteUser(t *testing.T) {
 		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
 		ut.app.ServeHTTP(w, r)
 
-		if w.Code != http.StatusNoContent {
-			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Status code received: %v", tests.Failed, w.Code)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("\t%s\tShould receive a status code of 404 for the response. Status code received: %v", tests.Failed, w.Code)
 		}
-		t.Logf("\t%s\tShould receive a status code of 204 for the response.", tests.Success)
+		t.Logf("\t%s\tShould receive a status code of 404 for the response.", tests.Success)
 	})
 
 	t.Run("Bad request", func(tt *testing.T) {
@@ -563,7 +562,6 @@ func TestDeleteUser(t *testing.T) {
 			Email:           "delete@this.com",
 			LastName:        "This",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -601,6 +599,43 @@ func TestDeleteUser(t *testing.T) {
 		t.Logf("\t%s\tShould receive a status code of 204 for the response.", tests.Success)
 	})
 
+	t.Run("Conflict (restoring a user whose email was taken)", func(tt *testing.T) {
+		body := `{"name":"Taken","email":"taken@this.com","last_name":"Email","country":"Argentina","password":"password","password_confirm":"password"}`
+		create := func() service.User {
+			r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			var u service.User
+			if err := json.NewDecoder(w.Body).Decode(&u); err != nil || w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tShould create the user. Received: %v %v", tests.Failed, w.Code, err)
+			}
+			return u
+		}
+
+		// The email of a deleted User is taken by another one...
+		u := create()
+		r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+u.ID, nil)
+		w := httptest.NewRecorder()
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Status code received: %v", tests.Failed, w.Code)
+		}
+		create()
+
+		// ...so it can't be restored.
+		r = httptest.NewRequest(http.MethodPost, "/v1/users/"+u.ID+"/restore", nil)
+		w = httptest.NewRecorder()
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tShould receive a status code of 409 for the response. Status code received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 409 for the response.", tests.Success)
+	})
+
 	t.Run("Success case (user deleting themself)", func(tt *testing.T) {
 		r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+tests.UserID, nil)
 		w := httptest.NewRecorder()
@@ -622,9 +657,9 @@ func TestGetToken(t *testing.T) {
 	shutdown := make(chan os.Signal, 1)
 
 	ur, _ := repository.NewRepository(test.DB)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
 	ut := UserTests{
-		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, test.DB),
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
 		kid:        test.KID,
 		userToken:  test.Token("user@example.com", "password"),
 		adminToken: test.Token("admin@example.com", "password"),
@@ -680,3 +715,495 @@ func TestGetToken(t *testing.T) {
 		t.Logf("\t%s\tShould be able to unmarshal the response.", tests.Success)
 	})
 }
+
+func TestQueryUsers(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	t.Run("Forbidden", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Bad request (invalid order)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users?order_by=password_hash", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users?role=ADMIN&order_by=email,asc&page=1&limit=10", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		var got service.QueryResult
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould be able to unmarshal the response.", tests.Success)
+
+		if got.Total != 1 || len(got.Items) != 1 || got.Items[0].ID != tests.AdminID {
+			t.Fatalf("\t%s\tShould only get the admin user : got %+v", tests.Failed, got)
+		}
+		t.Logf("\t%s\tShould only get the admin user.", tests.Success)
+	})
+
+	t.Run("Success case (cursor pagination)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users?limit=1", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		var first struct {
+			Items      []service.User `json:"items"`
+			NextCursor string         `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&first); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if first.NextCursor == "" || !strings.Contains(w.Header().Get("Link"), `rel="next"`) {
+			t.Fatalf("\t%s\tShould get a cursor to the next page.", tests.Failed)
+		}
+		t.Logf("\t%s\tShould get a cursor to the next page.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodGet, "/v1/users?limit=1&cursor="+first.NextCursor, nil)
+		w = httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the next page. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the next page.", tests.Success)
+
+		var second struct {
+			Items      []service.User `json:"items"`
+			NextCursor string         `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&second); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if len(second.Items) != 1 || second.Items[0].ID == first.Items[0].ID || second.NextCursor != "" {
+			t.Fatalf("\t%s\tShould get the last user without a following cursor : got %+v", tests.Failed, second)
+		}
+		t.Logf("\t%s\tShould get the last user without a following cursor.", tests.Success)
+
+		for _, target := range []string{
+			"/v1/users?limit=1&country=Canada&cursor=" + first.NextCursor,
+			"/v1/users?limit=1&page=2&cursor=" + first.NextCursor,
+			"/v1/users?limit=1&order_by=email&cursor=" + first.NextCursor,
+		} {
+			r = httptest.NewRequest(http.MethodGet, target, nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("\t%s\tShould receive a status code of 400 for %s. Received: %v", tests.Failed, target, w.Code)
+			}
+		}
+		t.Logf("\t%s\tShould only accept cursors for the query they were issued for.", tests.Success)
+	})
+
+	t.Run("Bad request (tampered cursor)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users?cursor=e30.c2lnbmF0dXJl", nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	t.Run("Unsupported media type", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.UserID, strings.NewReader(`{"country":"Peru"}`))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("Content-Type", "application/json")
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("\t%s\tShould receive a status code of 415 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 415 for the response.", tests.Success)
+	})
+
+	t.Run("Payload too large", func(tt *testing.T) {
+		body := `{"name":"` + strings.Repeat("a", 1<<16) + `"}`
+		r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.UserID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("\t%s\tShould receive a status code of 413 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 413 for the response.", tests.Success)
+	})
+
+	t.Run("Bad request (patched user fails validation)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.UserID, strings.NewReader(`{"country":null}`))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+	})
+
+	t.Run("Conflict (failed test operation)", func(tt *testing.T) {
+		body := `[{"op":"test","path":"/country","value":"Narnia"},{"op":"replace","path":"/country","value":"Peru"}]`
+		r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.UserID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("Content-Type", "application/json-patch+json")
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("\t%s\tShould receive a status code of 409 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 409 for the response.", tests.Success)
+	})
+
+	t.Run("Forbidden", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.AdminID, strings.NewReader(`{"country":"Peru"}`))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		patches := []struct {
+			contentType string
+			body        string
+		}{
+			{"application/merge-patch+json", `{"country":"Peru"}`},
+			{"application/json-patch+json", `[{"op":"test","path":"/country","value":"Peru"},{"op":"replace","path":"/last_name","value":"Patched"}]`},
+		}
+
+		for _, p := range patches {
+			r := httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.UserID, strings.NewReader(p.body))
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ut.userToken)
+			r.Header.Set("Content-Type", p.contentType)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tShould receive a status code of 200 for a %s. Received: %v", tests.Failed, p.contentType, w.Code)
+			}
+			t.Logf("\t%s\tShould receive a status code of 200 for a %s.", tests.Success, p.contentType)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/"+tests.UserID, nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.app.ServeHTTP(w, r)
+
+		var u service.User
+		if err := json.NewDecoder(w.Body).Decode(&u); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+
+		if u.Name != "Example" || u.LastName != "Patched" || u.Country != "Peru" {
+			t.Fatalf("\t%s\tShould only see the patched fields updated : got %+v", tests.Failed, u)
+		}
+		t.Logf("\t%s\tShould only see the patched fields updated.", tests.Success)
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/"+tests.UserID, nil)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+ut.userToken)
+	ut.app.ServeHTTP(w, r)
+
+	tag := w.Header().Get("ETag")
+	if tag == "" {
+		t.Fatalf("\t%s\tShould receive an ETag for the user.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould receive an ETag for the user.", tests.Success)
+
+	t.Run("Not modified", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/"+tests.UserID, nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("If-None-Match", tag)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("\t%s\tShould receive a status code of 304 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 304 for the response.", tests.Success)
+	})
+
+	t.Run("Success case (matching update)", func(tt *testing.T) {
+		body := `{"name":"Conditional","last_name":"User","country":"Algeria"}`
+		r := httptest.NewRequest(http.MethodPut, "/v1/users/"+tests.UserID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("If-Match", tag)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 200 for the response.", tests.Success)
+
+		updated := w.Header().Get("ETag")
+		if updated == "" || updated == tag {
+			t.Fatalf("\t%s\tShould receive the ETag of the updated user. Received: %q", tests.Failed, updated)
+		}
+		t.Logf("\t%s\tShould receive the ETag of the updated user.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodPatch, "/v1/users/"+tests.UserID, strings.NewReader(`{"name":"Listed"}`))
+		w = httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+		r.Header.Set("If-Match", tag+", W/"+updated+", "+updated)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould receive a status code of 200 for a list of entity tags. Received: %v", tests.Failed, w.Code)
+		}
+		if patched := w.Header().Get("ETag"); patched == "" || patched == updated {
+			t.Fatalf("\t%s\tShould receive the ETag of the patched user. Received: %q", tests.Failed, patched)
+		}
+		t.Logf("\t%s\tShould match any of the listed entity tags.", tests.Success)
+	})
+
+	t.Run("Precondition failed (stale update)", func(tt *testing.T) {
+		body := `{"name":"Stale","last_name":"User","country":"Algeria"}`
+		r := httptest.NewRequest(http.MethodPut, "/v1/users/"+tests.UserID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("If-Match", tag)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("\t%s\tShould receive a status code of 412 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 412 for the response.", tests.Success)
+	})
+
+	t.Run("Precondition failed (stale delete)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodDelete, "/v1/users/"+tests.UserID, nil)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		r.Header.Set("If-Match", tag+`, "999"`)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("\t%s\tShould receive a status code of 412 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 412 for the response.", tests.Success)
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	app := handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer)
+
+	var bodies []string
+	for _, email := range []string{"user@example.com", "unknown@example.com"} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/users/password/forgot", strings.NewReader(`{"email":"`+email+`"}`))
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("\t%s\tShould receive a status code of 202 for %s. Received: %v", tests.Failed, email, w.Code)
+		}
+		bodies = append(bodies, w.Body.String())
+	}
+	if bodies[0] != bodies[1] {
+		t.Fatalf("\t%s\tShould receive the same response for known and unknown emails : got %q and %q", tests.Failed, bodies[0], bodies[1])
+	}
+	t.Logf("\t%s\tShould receive the same response for known and unknown emails.", tests.Success)
+
+	if _, ok := test.Mailer.Await("user@example.com", 0); !ok {
+		t.Fatalf("\t%s\tShould send the reset token to known emails.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould send the reset token to known emails.", tests.Success)
+
+	body := `{"token":"invalid","password":"new password","password_confirm":"new password"}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/password/reset", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("\t%s\tShould receive a status code of 400 for an invalid token. Received: %v", tests.Failed, w.Code)
+	}
+	t.Logf("\t%s\tShould receive a status code of 400 for an invalid token.", tests.Success)
+}
+
+func TestUpdateRoles(t *testing.T) {
+	test := tests.NewIntegration(t)
+	t.Cleanup(test.Teardown)
+
+	shutdown := make(chan os.Signal, 1)
+
+	ur, _ := repository.NewRepository(test.DB)
+	us, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
+	ut := UserTests{
+		app:        handlers.NewHTTPHandler("test", shutdown, us, test.Log, nil, nil, test.Auth, nil, test.DB, test.Cursors, service.DefaultIssuer),
+		kid:        test.KID,
+		userToken:  test.Token("user@example.com", "password"),
+		adminToken: test.Token("admin@example.com", "password"),
+	}
+
+	t.Run("Bad request (self-assigned roles on signup)", func(tt *testing.T) {
+		body := `{"name":"Santiago","email":"roles@example.com","last_name":"Hernández","country":"Argentina",` +
+			`"roles":["ADMIN"],"password":"password","password_confirm":"password"}`
+		r := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould receive a status code of 400 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 400 for the response.", tests.Success)
+	})
+
+	t.Run("Forbidden (user)", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "/v1/users/"+tests.UserID+"/roles", strings.NewReader(`{"roles":["ADMIN"]}`))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.userToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould receive a status code of 403 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 403 for the response.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "/v1/users/"+tests.UserID+"/roles", strings.NewReader(`{"roles":["USER","ADMIN"]}`))
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("\t%s\tShould receive a status code of 204 for the response. Received: %v", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould receive a status code of 204 for the response.", tests.Success)
+
+		r = httptest.NewRequest(http.MethodGet, "/v1/users/"+tests.UserID+"/roles/changes", nil)
+		w = httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+		ut.app.ServeHTTP(w, r)
+
+		var changes []service.RoleChange
+		if err := json.NewDecoder(w.Body).Decode(&changes); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal the response : %v", tests.Failed, err)
+		}
+		if len(changes) != 1 || changes[0].ActorID != tests.AdminID {
+			t.Fatalf("\t%s\tShould get the role change made by the admin : got %+v", tests.Failed, changes)
+		}
+		t.Logf("\t%s\tShould get the role change made by the admin.", tests.Success)
+	})
+}
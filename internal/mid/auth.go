@@ -12,8 +12,11 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(a *auth.Auth) web.Middleware {
+// Authenticate validates a JWT from the `Authorization` header and rejects
+// it if it's in the denylist, when one is given. The claims of the token are
+// then checked by each of the validators. Tokens of service accounts are
+// accepted as well, handlers can tell them apart by Claims.Machine.
+func Authenticate(a *auth.Auth, denylist auth.Denylist, validators ...auth.ClaimsValidator) web.Middleware {
 
 	m := func(handler web.Handler) web.Handler {
 
@@ -36,6 +39,33 @@ func Authenticate(a *auth.Auth) web.Middleware {
 				return web.NewRequestError(err, http.StatusUnauthorized)
 			}
 
+			if denylist != nil {
+				revoked, err := denylist.Revoked(ctx, claims)
+				if err != nil {
+					return errors.Wrap(err, "checking denylist")
+				}
+				if revoked {
+					return web.NewRequestError(auth.ErrTokenRevoked, http.StatusUnauthorized)
+				}
+			}
+
+			for _, validate := range validators {
+				if err := validate(ctx, claims); err != nil {
+					if errors.Cause(err) == auth.ErrTokenRevoked {
+						return web.NewRequestError(err, http.StatusUnauthorized)
+					}
+					return errors.Wrap(err, "validating claims")
+				}
+			}
+
+			// Impersonated sessions are made visible to both the client and the logs.
+			if claims.Impersonated() {
+				w.Header().Set("Impersonated-By", claims.Actor.Subject)
+				if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+					v.Actor = claims.Actor.Subject
+				}
+			}
+
 			// Add claims to the context so they can be retrieved later.
 			ctx = context.WithValue(ctx, auth.Key, claims)
 
@@ -79,3 +109,44 @@ func Authorize(roles ...string) web.Middleware {
 
 	return m
 }
+
+// RequirePermission validates that an authenticated user has all the specified
+// permissions. Permissions restricted to the user's own resources are
+// satisfied when the id parameter of the route is the user's subject.
+func RequirePermission(resolve auth.PermissionsFunc, perms ...string) web.Middleware {
+
+	m := func(handler web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.mid.requirePermission")
+			defer span.End()
+
+			// If the context is missing this value return failure.
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context")
+			}
+
+			granted, err := resolve(ctx, claims)
+			if err != nil {
+				return errors.Wrap(err, "resolving permissions")
+			}
+
+			ownerID := web.Params(r)["id"]
+			for _, perm := range perms {
+				if !granted.Allows(perm, claims.Subject, ownerID) {
+					return web.NewRequestError(
+						fmt.Errorf("you are not authorized for that action: roles: %v missing: %v", claims.Roles, perm),
+						http.StatusForbidden,
+					)
+				}
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
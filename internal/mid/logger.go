@@ -11,7 +11,8 @@ import (
 )
 
 // Logger writes some information about the request to the logs in the
-// format: TraceID : (200) GET /foo -> IP ADDR (latency)
+// format: TraceID : (200) GET /foo -> IP ADDR (latency). Requests made by
+// impersonated sessions are followed by the actor.
 func Logger(log *log.Logger) web.Middleware {
 
 	m := func(handler web.Handler) web.Handler {
@@ -34,10 +35,15 @@ func Logger(log *log.Logger) web.Middleware {
 
 			err := handler(ctx, w, r)
 
-			log.Printf("%s: completed: %s %s -> %s (%d) (%s)",
+			var actor string
+			if v.Actor != "" {
+				actor = " (impersonated by " + v.Actor + ")"
+			}
+
+			log.Printf("%s: completed: %s %s -> %s (%d) (%s)%s",
 				v.TraceID,
 				r.Method, r.URL.Path, r.RemoteAddr,
-				v.StatusCode, time.Since(v.Now),
+				v.StatusCode, time.Since(v.Now), actor,
 			)
 
 			// Return the error so it can be handled further up the chain.
@@ -0,0 +1,113 @@
+// Package cursor provides support for opaque, signed pagination cursors.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor is malformed or its
+	// signature doesn't match its content.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrQueryMismatch is returned when a cursor is used with a query other
+	// than the one it was encoded for.
+	ErrQueryMismatch = errors.New("cursor doesn't match the query")
+)
+
+// Signer encodes values into opaque tokens signed with HMAC-SHA256 so
+// clients can't forge or tamper with them.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a Signer using the provided key.
+func NewSigner(key []byte) (*Signer, error) {
+	if len(key) < sha256.Size {
+		return nil, errors.Errorf("cursor key must be at least %d bytes long", sha256.Size)
+	}
+	return &Signer{key: key}, nil
+}
+
+// Encode marshals v and returns a signed token representing it.
+func (s *Signer) Encode(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "marshaling cursor")
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.sign(payload)), nil
+}
+
+// Decode verifies the signature of a token and unmarshals its content into v.
+func (s *Signer) Decode(token string, v interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return ErrInvalidCursor
+	}
+
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	if !hmac.Equal(sig, s.sign(payload)) {
+		return ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidCursor
+	}
+
+	return nil
+}
+
+// bound is the content of the cursors encoded for a query.
+type bound struct {
+	Query string          `json:"q"`
+	Value json.RawMessage `json:"v"`
+}
+
+// EncodeFor returns a signed token representing v that can only be decoded
+// along with the same query.
+func (s *Signer) EncodeFor(query string, v interface{}) (string, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "marshaling cursor")
+	}
+	return s.Encode(bound{Query: query, Value: value})
+}
+
+// DecodeFor verifies the signature of a token encoded by EncodeFor, checks
+// that it was encoded for the query and unmarshals its content into v.
+func (s *Signer) DecodeFor(token, query string, v interface{}) error {
+	var b bound
+	if err := s.Decode(token, &b); err != nil {
+		return err
+	}
+	if b.Query != query {
+		return ErrQueryMismatch
+	}
+	if err := json.Unmarshal(b.Value, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
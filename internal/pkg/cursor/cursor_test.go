@@ -0,0 +1,96 @@
+package cursor_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santiagoh1997/service-template/internal/pkg/cursor"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type position struct {
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+func TestSigner(t *testing.T) {
+	s, err := cursor.NewSigner(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a signer: %v", failed, err)
+	}
+	t.Logf("\t%s\tShould be able to create a signer.", success)
+
+	want := position{
+		Time: time.Date(2019, time.March, 24, 0, 0, 0, 0, time.UTC),
+		ID:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+	}
+
+	token, err := s.Encode(want)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to encode a cursor: %v", failed, err)
+	}
+	t.Logf("\t%s\tShould be able to encode a cursor.", success)
+
+	var got position
+	if err := s.Decode(token, &got); err != nil {
+		t.Fatalf("\t%s\tShould be able to decode a cursor: %v", failed, err)
+	}
+	if !got.Time.Equal(want.Time) || got.ID != want.ID {
+		t.Fatalf("\t%s\tShould decode the same cursor : got %+v want %+v", failed, got, want)
+	}
+	t.Logf("\t%s\tShould decode the same cursor.", success)
+
+	// Replace the payload with "{}" while keeping the original signature.
+	tampered := "e30" + token[strings.Index(token, "."):]
+	if err := s.Decode(tampered, &got); err != cursor.ErrInvalidCursor {
+		t.Fatalf("\t%s\tShould reject a tampered cursor : got %v", failed, err)
+	}
+	t.Logf("\t%s\tShould reject a tampered cursor.", success)
+
+	other, _ := cursor.NewSigner(bytes.Repeat([]byte("o"), 32))
+	if err := other.Decode(token, &got); err != cursor.ErrInvalidCursor {
+		t.Fatalf("\t%s\tShould reject a cursor signed with another key : got %v", failed, err)
+	}
+	t.Logf("\t%s\tShould reject a cursor signed with another key.", success)
+}
+
+func TestSignerQuery(t *testing.T) {
+	s, err := cursor.NewSigner(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a signer: %v", failed, err)
+	}
+
+	want := position{
+		Time: time.Date(2019, time.March, 24, 0, 0, 0, 0, time.UTC),
+		ID:   "45b5fbd3-755f-4379-8f07-a58d4a30fa2f",
+	}
+
+	token, err := s.EncodeFor("country=Argentina", want)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to encode a cursor: %v", failed, err)
+	}
+
+	var got position
+	if err := s.DecodeFor(token, "country=Argentina", &got); err != nil || got.ID != want.ID {
+		t.Fatalf("\t%s\tShould decode the cursor for its query : got %+v, %v", failed, got, err)
+	}
+	t.Logf("\t%s\tShould decode the cursor for its query.", success)
+
+	if err := s.DecodeFor(token, "country=Canada", &got); err != cursor.ErrQueryMismatch {
+		t.Fatalf("\t%s\tShould reject the cursor for another query : got %v", failed, err)
+	}
+	t.Logf("\t%s\tShould reject the cursor for another query.", success)
+
+	plain, _ := s.Encode(want)
+	if err := s.DecodeFor(plain, "", &got); err != cursor.ErrInvalidCursor {
+		t.Fatalf("\t%s\tShould reject cursors encoded without a query : got %v", failed, err)
+	}
+	t.Logf("\t%s\tShould reject cursors encoded without a query.", success)
+}
@@ -0,0 +1,163 @@
+// Package mail provides support for sending emails to users.
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Message represents a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is the interface implemented by the mechanisms used to deliver emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer delivers emails through an SMTP server.
+type SMTPMailer struct {
+	host string
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer constructs an SMTPMailer. Authentication is only used when a
+// username is provided.
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, errors.New("smtp host can't be empty")
+	}
+	if from == "" {
+		return nil, errors.New("from address can't be empty")
+	}
+
+	m := SMTPMailer{
+		host: host,
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &m, nil
+}
+
+// Send delivers the message to the SMTP server. The conversation with the
+// server is abandoned once the context is done.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("invalid message headers")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	if err := m.send(ctx, msg.To, []byte(b.String())); err != nil {
+		return errors.Wrapf(err, "sending email to %s", msg.To)
+	}
+
+	return nil
+}
+
+// send works like smtp.SendMail, but dials the server and bounds the
+// conversation with it by the context.
+func (m *SMTPMailer) send(ctx context.Context, to string, body []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks any pending read or write.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp server doesn't support AUTH")
+		}
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// LogMailer writes emails to a logger instead of delivering them. It's meant
+// for local development and testing.
+type LogMailer struct {
+	log *log.Logger
+}
+
+// NewLogMailer constructs a LogMailer writing to the provided logger.
+func NewLogMailer(log *log.Logger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+// Send writes the message to the logger.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.log.Printf("mail : To[%s] Subject[%s]\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
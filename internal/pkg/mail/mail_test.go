@@ -0,0 +1,91 @@
+package mail_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santiagoh1997/service-template/internal/pkg/mail"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := mail.NewLogMailer(log.New(&buf, "", 0))
+
+	msg := mail.Message{
+		To:      "user@example.com",
+		Subject: "Hello",
+		Body:    "Some content",
+	}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("\t%s\tSend() err = %v, want %v", failed, err, nil)
+	}
+
+	for _, want := range []string{msg.To, msg.Subject, msg.Body} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("\t%s\tShould log %q : got %q", failed, want, buf.String())
+		}
+	}
+	t.Logf("\t%s\tShould log the message.", success)
+}
+
+func TestNewSMTPMailer(t *testing.T) {
+	if _, err := mail.NewSMTPMailer("", 25, "", "", "noreply@example.com"); err == nil {
+		t.Fatalf("\t%s\tShould require a host.", failed)
+	}
+	if _, err := mail.NewSMTPMailer("localhost", 25, "", "", ""); err == nil {
+		t.Fatalf("\t%s\tShould require a from address.", failed)
+	}
+	if _, err := mail.NewSMTPMailer("localhost", 25, "user", "pass", "noreply@example.com"); err != nil {
+		t.Fatalf("\t%s\tNewSMTPMailer() err = %v, want %v", failed, err, nil)
+	}
+	t.Logf("\t%s\tShould validate its configuration.", success)
+}
+
+func TestSMTPMailerContext(t *testing.T) {
+	// The server accepts connections but never greets the client.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	m, err := mail.NewSMTPMailer(host, p, "", "", "noreply@example.com")
+	if err != nil {
+		t.Fatalf("\t%s\tNewSMTPMailer() err = %v, want %v", failed, err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := m.Send(ctx, mail.Message{To: "user@example.com", Subject: "Hello", Body: "Some content"}); err == nil {
+		t.Fatalf("\t%s\tShould fail when the server doesn't answer.", failed)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("\t%s\tShould give up once the context is done : took %v", failed, elapsed)
+	}
+	t.Logf("\t%s\tShould give up once the context is done.", success)
+}
@@ -0,0 +1,352 @@
+// Package patch provides support for applying JSON Merge Patch (RFC 7396)
+// and JSON Patch (RFC 6902) documents.
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Media types of the supported patch documents.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch is returned when a patch document is malformed or one
+	// of its operations can't be applied to the target document.
+	ErrInvalidPatch = errors.New("invalid patch")
+
+	// ErrTestFailed is returned when a JSON Patch "test" operation doesn't match
+	// the target document.
+	ErrTestFailed = errors.New("patch test operation failed")
+)
+
+// Apply applies a patch document of the given media type to doc.
+func Apply(mediaType string, doc, patch []byte) ([]byte, error) {
+	switch mediaType {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return JSONPatch(doc, patch)
+	default:
+		return nil, errors.Errorf("unsupported patch media type %q", mediaType)
+	}
+}
+
+// MergePatch applies a JSON Merge Patch document to doc as described in RFC 7396.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, errors.Wrap(err, "decoding document")
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+
+	return t
+}
+
+// operation represents a single JSON Patch operation.
+type operation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// JSONPatch applies a JSON Patch document to doc as described in RFC 6902.
+// Operations are applied in order and the whole patch fails if any of them does.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, errors.Wrap(err, "decoding document")
+	}
+
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+
+	for i, op := range ops {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, errors.Wrapf(err, "operation %d (%s)", i, op.Op)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func (op operation) apply(doc interface{}) (interface{}, error) {
+	if op.Path == nil {
+		return nil, errors.Wrap(ErrInvalidPatch, "missing path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.Wrap(ErrInvalidPatch, "missing value")
+		}
+		var val interface{}
+		if err := json.Unmarshal(*op.Value, &val); err != nil {
+			return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, val)
+		case "replace":
+			if doc, _, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+			return add(doc, path, val)
+		default:
+			got, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(got, val) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, errors.Wrap(ErrInvalidPatch, "missing from")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var val interface{}
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, errors.Wrap(ErrInvalidPatch, "can't move a value into one of its children")
+			}
+			if doc, val, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if val, err = get(doc, from); err != nil {
+				return nil, err
+			}
+			if val, err = deepCopy(val); err != nil {
+				return nil, err
+			}
+		}
+		return add(doc, path, val)
+
+	default:
+		return nil, errors.Wrapf(ErrInvalidPatch, "unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, errors.Wrapf(ErrInvalidPatch, "invalid pointer %q", p)
+	}
+
+	r := strings.NewReplacer("~1", "/", "~0", "~")
+	tokens := strings.Split(p[1:], "/")
+	for i := range tokens {
+		tokens[i] = r.Replace(tokens[i])
+	}
+
+	return tokens, nil
+}
+
+// index parses an array index token, which must be lower than max.
+func index(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= max || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Wrapf(ErrInvalidPatch, "invalid array index %q", token)
+	}
+	return i, nil
+}
+
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, errors.Wrapf(ErrInvalidPatch, "path %q not found", token)
+			}
+			node = v
+		case []interface{}:
+			i, err := index(token, len(n))
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, errors.Wrapf(ErrInvalidPatch, "path %q not found", token)
+		}
+	}
+	return node, nil
+}
+
+// add sets val at path, returning the resulting node. Values inserted in
+// arrays shift the following elements.
+func add(node interface{}, path []string, val interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+	token, last := path[0], len(path) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if last {
+			n[token] = val
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidPatch, "path %q not found", token)
+		}
+		child, err := add(child, path[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = child
+		return n, nil
+
+	case []interface{}:
+		if last {
+			if token == "-" {
+				return append(n, val), nil
+			}
+			i, err := index(token, len(n)+1)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = val
+			return n, nil
+		}
+		i, err := index(token, len(n))
+		if err != nil {
+			return nil, err
+		}
+		if n[i], err = add(n[i], path[1:], val); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	default:
+		return nil, errors.Wrapf(ErrInvalidPatch, "path %q not found", token)
+	}
+}
+
+// remove deletes the value at path, returning the resulting node and the
+// removed value.
+func remove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, node, nil
+	}
+	token, last := path[0], len(path) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, errors.Wrapf(ErrInvalidPatch, "path %q not found", token)
+		}
+		if last {
+			delete(n, token)
+			return n, child, nil
+		}
+		child, removed, err := remove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = child
+		return n, removed, nil
+
+	case []interface{}:
+		i, err := index(token, len(n))
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(n[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[i] = child
+		return n, removed, nil
+
+	default:
+		return nil, nil, errors.Wrapf(ErrInvalidPatch, "path %q not found", token)
+	}
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func deepCopy(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c interface{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
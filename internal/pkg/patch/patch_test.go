@@ -0,0 +1,99 @@
+package patch_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/pkg/patch"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMergePatch(t *testing.T) {
+	tt := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"Replace value", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"Add value", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"Remove value", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"Replace array", `{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{"Nested object", `{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":"g"}}`, `{"a":{"b":"c","f":"g"}}`},
+		{"Non object patch", `{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := patch.MergePatch([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("\t%s\tMergePatch() err = %v", failed, err)
+			}
+			assertJSONEqual(t, got, tc.want)
+			t.Logf("\t%s\tShould get the patched document.", success)
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tt := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{"Add member", `{"a":"b"}`, `[{"op":"add","path":"/c","value":"d"}]`, `{"a":"b","c":"d"}`, nil},
+		{"Add to array", `{"a":["b","d"]}`, `[{"op":"add","path":"/a/1","value":"c"}]`, `{"a":["b","c","d"]}`, nil},
+		{"Append to array", `{"a":["b"]}`, `[{"op":"add","path":"/a/-","value":"c"}]`, `{"a":["b","c"]}`, nil},
+		{"Remove member", `{"a":"b","c":"d"}`, `[{"op":"remove","path":"/c"}]`, `{"a":"b"}`, nil},
+		{"Replace member", `{"a":"b"}`, `[{"op":"replace","path":"/a","value":"c"}]`, `{"a":"c"}`, nil},
+		{"Move member", `{"a":{"b":"c"}}`, `[{"op":"move","from":"/a/b","path":"/d"}]`, `{"a":{},"d":"c"}`, nil},
+		{"Copy member", `{"a":{"b":"c"}}`, `[{"op":"copy","from":"/a","path":"/d"}]`, `{"a":{"b":"c"},"d":{"b":"c"}}`, nil},
+		{"Escaped pointer", `{"a/b":"c","d~e":"f"}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/d~0e"}]`, `{}`, nil},
+		{"Test success", `{"a":"b"}`, `[{"op":"test","path":"/a","value":"b"},{"op":"replace","path":"/a","value":"c"}]`, `{"a":"c"}`, nil},
+		{"Test failure", `{"a":"b"}`, `[{"op":"test","path":"/a","value":"c"}]`, "", patch.ErrTestFailed},
+		{"Replace missing member", `{"a":"b"}`, `[{"op":"replace","path":"/c","value":"d"}]`, "", patch.ErrInvalidPatch},
+		{"Unknown operation", `{"a":"b"}`, `[{"op":"upsert","path":"/a","value":"d"}]`, "", patch.ErrInvalidPatch},
+		{"Move into child", `{"a":{"b":"c"}}`, `[{"op":"move","from":"/a","path":"/a/d"}]`, "", patch.ErrInvalidPatch},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := patch.JSONPatch([]byte(tc.doc), []byte(tc.patch))
+			if errors.Cause(err) != tc.wantErr {
+				t.Fatalf("\t%s\tJSONPatch() err = %v, want %v", failed, err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				t.Logf("\t%s\tShould get the expected error.", success)
+				return
+			}
+			assertJSONEqual(t, got, tc.want)
+			t.Logf("\t%s\tShould get the patched document.", success)
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("\t%s\tShould get valid JSON: %v", failed, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("\t%s\tShould have valid expected JSON: %v", failed, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Logf("\t\tGot : %s", got)
+		t.Logf("\t\tWant: %s", want)
+		t.Fatalf("\t%s\tShould get the expected document.", failed)
+	}
+}
@@ -50,6 +50,11 @@ func Decode(r *http.Request, val interface{}) error {
 		return NewRequestError(err, http.StatusBadRequest)
 	}
 
+	return Validate(val)
+}
+
+// Validate checks the validation tags of the provided struct value.
+func Validate(val interface{}) error {
 	if err := validate.Struct(val); err != nil {
 
 		verrors, ok := err.(validator.ValidationErrors)
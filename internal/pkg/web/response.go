@@ -24,7 +24,7 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 	v.StatusCode = statusCode
 
 	// If there is nothing to marshal then set status code and return.
-	if statusCode == http.StatusNoContent {
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
 		w.WriteHeader(statusCode)
 		return nil
 	}
@@ -24,6 +24,7 @@ type Values struct {
 	TraceID    string
 	Now        time.Time
 	StatusCode int
+	Actor      string
 }
 
 // A Handler is a type that handles an http request and returns an error.
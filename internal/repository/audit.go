@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// CreateAuditEvent appends an event to the chain of audit events, linking it
+// to the last one, and makes it the head of the chain signed with the key.
+// Appends are serialized on the head so the chain doesn't fork.
+func (ur *UserRepository) CreateAuditEvent(ctx context.Context, e service.AuditEvent, key []byte) (service.AuditEvent, error) {
+	tx, err := ur.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return service.AuditEvent{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Only the row of the head is locked, so reads aren't blocked.
+	var prev string
+	if err := tx.GetContext(ctx, &prev, `SELECT hash FROM audit_chain_head FOR UPDATE`); err != nil {
+		return service.AuditEvent{}, errors.Wrap(err, "locking audit chain head")
+	}
+	e.DateCreated = e.DateCreated.UTC()
+	e.PrevHash = prev
+	e.Hash = e.ComputeHash(prev)
+
+	const q = `
+	INSERT INTO audit_events
+		(event_id, actor_id, impersonator_id, tenant_id, action, target_id, trace_id, client_ip, changes, error, date_created, prev_hash, hash)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING seq`
+
+	if err := tx.GetContext(ctx, &e.Seq, q, e.ID, e.ActorID, e.ImpersonatorID, e.TenantID, e.Action, e.TargetID,
+		e.TraceID, e.ClientIP, e.Changes, e.Error, e.DateCreated, e.PrevHash, e.Hash); err != nil {
+		return service.AuditEvent{}, errors.Wrap(err, "inserting audit event")
+	}
+
+	head := service.AuditHead{Seq: e.Seq, Hash: e.Hash}.Sign(key)
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_chain_head SET seq = $1, hash = $2, signature = $3`, head.Seq, head.Hash, head.Signature); err != nil {
+		return service.AuditEvent{}, errors.Wrap(err, "updating audit chain head")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return service.AuditEvent{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return e, nil
+}
+
+// QueryAuditEvents retrieves a page of the audit events of the tenant
+// matching the filter, most recent first, along with the total number of
+// matching events. Events that don't belong to any tenant are only reached
+// with AnyTenant.
+func (ur *UserRepository) QueryAuditEvents(ctx context.Context, tenantID string, filter service.AuditFilter, page, rowsPerPage int) ([]service.AuditEvent, int, error) {
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if tenant != nil {
+		add("tenant_id = $%d", tenant)
+	}
+	if filter.ActorID != "" {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		add("date_created >= $%d", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add("date_created < $%d", filter.To.UTC())
+	}
+
+	var where string
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := ur.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM audit_events`+where, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "counting audit events")
+	}
+
+	q := fmt.Sprintf(`SELECT * FROM audit_events%s ORDER BY seq DESC LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+	args = append(args, rowsPerPage, (page-1)*rowsPerPage)
+
+	events := []service.AuditEvent{}
+	if err := ur.db.SelectContext(ctx, &events, q, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "selecting audit events")
+	}
+
+	return events, total, nil
+}
+
+// GetAuditHead retrieves the head of the chain of audit events.
+func (ur *UserRepository) GetAuditHead(ctx context.Context) (service.AuditHead, error) {
+	var head service.AuditHead
+	if err := ur.db.GetContext(ctx, &head, `SELECT seq, hash, signature FROM audit_chain_head`); err != nil {
+		return service.AuditHead{}, errors.Wrap(err, "selecting audit chain head")
+	}
+
+	return head, nil
+}
+
+// QueryAuditChain retrieves up to limit audit events of every tenant that
+// come after afterSeq up to toSeq, in the order they were appended.
+func (ur *UserRepository) QueryAuditChain(ctx context.Context, afterSeq, toSeq int64, limit int) ([]service.AuditEvent, error) {
+	const q = `SELECT * FROM audit_events WHERE seq > $1 AND seq <= $2 ORDER BY seq LIMIT $3`
+
+	events := []service.AuditEvent{}
+	if err := ur.db.SelectContext(ctx, &events, q, afterSeq, toSeq, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting audit events")
+	}
+
+	return events, nil
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// QueryGroups retrieves the Groups of the tenant, ordered by name.
+func (ur *UserRepository) QueryGroups(ctx context.Context, tenantID string) ([]service.Group, error) {
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `SELECT * FROM groups WHERE ($1::uuid IS NULL OR tenant_id = $1) ORDER BY name, group_id`
+
+	groups := []service.Group{}
+	if err := ur.db.SelectContext(ctx, &groups, q, tenant); err != nil {
+		return nil, errors.Wrap(err, "selecting groups")
+	}
+
+	return groups, nil
+}
+
+// GetGroup retrieves a Group of the tenant by its ID.
+func (ur *UserRepository) GetGroup(ctx context.Context, tenantID, groupID string) (service.Group, error) {
+	if _, err := uuid.Parse(groupID); err != nil {
+		return service.Group{}, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return service.Group{}, err
+	}
+
+	const q = `SELECT * FROM groups WHERE group_id = $1 AND ($2::uuid IS NULL OR tenant_id = $2)`
+
+	var g service.Group
+	if err := ur.db.GetContext(ctx, &g, q, groupID, tenant); err != nil {
+		if err == sql.ErrNoRows {
+			return service.Group{}, service.ErrNotFound
+		}
+		return service.Group{}, errors.Wrapf(err, "selecting group %q", groupID)
+	}
+
+	return g, nil
+}
+
+// CreateGroup saves a Group in the DB.
+func (ur *UserRepository) CreateGroup(ctx context.Context, g service.Group) error {
+	const q = `
+	INSERT INTO groups
+		(group_id, tenant_id, name, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5)`
+
+	if _, err := ur.db.ExecContext(ctx, q, g.ID, g.TenantID, g.Name, g.DateCreated.UTC(), g.DateUpdated.UTC()); err != nil {
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedGroup
+		}
+		return errors.Wrap(err, "inserting group")
+	}
+
+	return nil
+}
+
+// UpdateGroup renames a Group of the tenant.
+func (ur *UserRepository) UpdateGroup(ctx context.Context, tenantID, groupID, name string, now time.Time) error {
+	if _, err := uuid.Parse(groupID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE groups SET "name" = $1, "date_updated" = $2 WHERE group_id = $3 AND ($4::uuid IS NULL OR tenant_id = $4)`
+
+	res, err := ur.db.ExecContext(ctx, q, name, now.UTC(), groupID, tenant)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedGroup
+		}
+		return errors.Wrapf(err, "updating group %s", groupID)
+	}
+
+	return checkGroupAffected(res, groupID)
+}
+
+// DeleteGroup removes a Group of the tenant along with its memberships.
+func (ur *UserRepository) DeleteGroup(ctx context.Context, tenantID, groupID string) error {
+	if _, err := uuid.Parse(groupID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `DELETE FROM groups WHERE group_id = $1 AND ($2::uuid IS NULL OR tenant_id = $2)`
+
+	res, err := ur.db.ExecContext(ctx, q, groupID, tenant)
+	if err != nil {
+		return errors.Wrapf(err, "deleting group %s", groupID)
+	}
+
+	return checkGroupAffected(res, groupID)
+}
+
+// AddGroupMember adds a User to a Group of the tenant. Users can only be
+// members of the Groups of their own tenant. Adding a member twice has no
+// effect, so missing Users or Groups have to be checked beforehand.
+func (ur *UserRepository) AddGroupMember(ctx context.Context, tenantID, groupID, userID string, now time.Time) error {
+	if _, err := uuid.Parse(groupID); err != nil {
+		return service.ErrInvalidID
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	INSERT INTO group_members
+		(group_id, user_id, date_created)
+	SELECT g.group_id, u.user_id, $3
+	FROM groups g
+	JOIN users u ON u.tenant_id = g.tenant_id
+	WHERE g.group_id = $1 AND u.user_id = $2 AND u.deleted_at IS NULL AND ($4::uuid IS NULL OR g.tenant_id = $4)
+	ON CONFLICT DO NOTHING`
+
+	if _, err := ur.db.ExecContext(ctx, q, groupID, userID, now.UTC(), tenant); err != nil {
+		return errors.Wrapf(err, "adding user %s to group %s", userID, groupID)
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes a User from a Group of the tenant.
+func (ur *UserRepository) RemoveGroupMember(ctx context.Context, tenantID, groupID, userID string) error {
+	if _, err := uuid.Parse(groupID); err != nil {
+		return service.ErrInvalidID
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	DELETE FROM group_members m
+	USING groups g
+	WHERE m.group_id = g.group_id AND m.group_id = $1 AND m.user_id = $2 AND ($3::uuid IS NULL OR g.tenant_id = $3)`
+
+	res, err := ur.db.ExecContext(ctx, q, groupID, userID, tenant)
+	if err != nil {
+		return errors.Wrapf(err, "removing user %s from group %s", userID, groupID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "removing user %s from group %s", userID, groupID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+// QueryGroupMembers retrieves a page of the Users that are members of a Group
+// of the tenant, ordered by the date they joined it, along with the total
+// number of members.
+func (ur *UserRepository) QueryGroupMembers(ctx context.Context, tenantID, groupID string, page, rowsPerPage int) ([]service.User, int, error) {
+	if _, err := uuid.Parse(groupID); err != nil {
+		return nil, 0, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	const from = `
+	FROM users u
+	JOIN group_members m ON m.user_id = u.user_id
+	WHERE m.group_id = $1 AND u.deleted_at IS NULL AND ($2::uuid IS NULL OR u.tenant_id = $2)`
+
+	var total int
+	if err := ur.db.GetContext(ctx, &total, `SELECT COUNT(*)`+from, groupID, tenant); err != nil {
+		return nil, 0, errors.Wrapf(err, "counting members of group %s", groupID)
+	}
+
+	// The user_id is used as a tie breaker so pages are stable.
+	q := `SELECT u.*` + from + ` ORDER BY m.date_created, u.user_id LIMIT $3 OFFSET $4`
+
+	users := []service.User{}
+	if err := ur.db.SelectContext(ctx, &users, q, groupID, tenant, rowsPerPage, (page-1)*rowsPerPage); err != nil {
+		return nil, 0, errors.Wrapf(err, "selecting members of group %s", groupID)
+	}
+
+	return users, total, nil
+}
+
+// QueryUserGroups retrieves the Groups a User of the tenant is a member of,
+// ordered by name.
+func (ur *UserRepository) QueryUserGroups(ctx context.Context, tenantID, userID string) ([]service.Group, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT g.* FROM groups g
+	JOIN group_members m ON m.group_id = g.group_id
+	WHERE m.user_id = $1 AND ($2::uuid IS NULL OR g.tenant_id = $2)
+	ORDER BY g.name, g.group_id`
+
+	groups := []service.Group{}
+	if err := ur.db.SelectContext(ctx, &groups, q, userID, tenant); err != nil {
+		return nil, errors.Wrapf(err, "selecting groups of user %s", userID)
+	}
+
+	return groups, nil
+}
+
+// checkGroupAffected reports a missing Group when a statement didn't affect any row.
+func checkGroupAffected(res sql.Result, groupID string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "updating group %s", groupID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// QueryClients retrieves every OAuth Client, ordered by name.
+func (ur *UserRepository) QueryClients(ctx context.Context) ([]service.Client, error) {
+	const q = `SELECT * FROM oauth_clients ORDER BY name, client_id`
+
+	clients := []service.Client{}
+	if err := ur.db.SelectContext(ctx, &clients, q); err != nil {
+		return nil, errors.Wrap(err, "selecting clients")
+	}
+
+	return clients, nil
+}
+
+// GetClient retrieves an OAuth Client by its ID.
+func (ur *UserRepository) GetClient(ctx context.Context, clientID string) (service.Client, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return service.Client{}, service.ErrInvalidID
+	}
+
+	const q = `SELECT * FROM oauth_clients WHERE client_id = $1`
+
+	var c service.Client
+	if err := ur.db.GetContext(ctx, &c, q, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return service.Client{}, service.ErrNotFound
+		}
+		return service.Client{}, errors.Wrapf(err, "selecting client %q", clientID)
+	}
+
+	return c, nil
+}
+
+// CreateClient saves an OAuth Client in the DB.
+func (ur *UserRepository) CreateClient(ctx context.Context, c service.Client) error {
+	const q = `
+	INSERT INTO oauth_clients
+		(client_id, name, redirect_uris, scopes, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6)`
+
+	if _, err := ur.db.ExecContext(ctx, q, c.ID, c.Name, c.RedirectURIs, c.Scopes, c.DateCreated.UTC(), c.DateUpdated.UTC()); err != nil {
+		return errors.Wrap(err, "inserting client")
+	}
+
+	return nil
+}
+
+// DeleteClient removes an OAuth Client along with the consents, codes and
+// refresh tokens issued to it.
+func (ur *UserRepository) DeleteClient(ctx context.Context, clientID string) error {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return service.ErrInvalidID
+	}
+
+	const q = `DELETE FROM oauth_clients WHERE client_id = $1`
+
+	res, err := ur.db.ExecContext(ctx, q, clientID)
+	if err != nil {
+		return errors.Wrapf(err, "deleting client %s", clientID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "deleting client %s", clientID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetConsent retrieves the scopes a User granted to an OAuth Client.
+func (ur *UserRepository) GetConsent(ctx context.Context, userID, clientID string) (service.Consent, error) {
+	const q = `SELECT * FROM oauth_consents WHERE user_id = $1 AND client_id = $2`
+
+	var c service.Consent
+	if err := ur.db.GetContext(ctx, &c, q, userID, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return service.Consent{}, service.ErrNotFound
+		}
+		return service.Consent{}, errors.Wrapf(err, "selecting consent of user %q", userID)
+	}
+
+	return c, nil
+}
+
+// SaveConsent saves the scopes a User granted to an OAuth Client, replacing
+// the ones they granted before.
+func (ur *UserRepository) SaveConsent(ctx context.Context, c service.Consent) error {
+	const q = `
+	INSERT INTO oauth_consents
+		(user_id, client_id, scopes, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id, client_id) DO UPDATE SET
+		"scopes" = EXCLUDED.scopes,
+		"date_updated" = EXCLUDED.date_updated`
+
+	if _, err := ur.db.ExecContext(ctx, q, c.UserID, c.ClientID, c.Scopes, c.DateCreated.UTC(), c.DateUpdated.UTC()); err != nil {
+		return errors.Wrap(err, "saving consent")
+	}
+
+	return nil
+}
+
+// CreateAuthorizationCode stores the hash of an authorization code. Expired
+// codes are removed along the way, since they can't be exchanged anymore.
+func (ur *UserRepository) CreateAuthorizationCode(ctx context.Context, ac service.AuthorizationCode) error {
+	if _, err := ur.db.ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at <= $1`, ac.DateCreated.UTC()); err != nil {
+		return errors.Wrap(err, "removing expired authorization codes")
+	}
+
+	const q = `
+	INSERT INTO oauth_codes
+		(code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, auth_time, expires_at, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	if _, err := ur.db.ExecContext(ctx, q, ac.CodeHash, ac.ClientID, ac.UserID, ac.RedirectURI, ac.Scopes, ac.CodeChallenge, ac.Nonce, ac.AuthTime.UTC(), ac.ExpiresAt.UTC(), ac.DateCreated.UTC()); err != nil {
+		return errors.Wrap(err, "inserting authorization code")
+	}
+
+	return nil
+}
+
+// UseAuthorizationCode consumes an authorization code, which is returned,
+// recording the family of the refresh tokens issued in exchange for it.
+// Consuming a code that was already used revokes that family instead.
+func (ur *UserRepository) UseAuthorizationCode(ctx context.Context, codeHash, familyID string, now time.Time) (service.AuthorizationCode, error) {
+	tx, err := ur.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return service.AuthorizationCode{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Concurrent exchanges of the same code are serialized so only one of
+	// them succeeds.
+	var ac service.AuthorizationCode
+	if err := tx.GetContext(ctx, &ac, `SELECT * FROM oauth_codes WHERE code_hash = $1 FOR UPDATE`, codeHash); err != nil {
+		if err == sql.ErrNoRows {
+			return service.AuthorizationCode{}, service.ErrInvalidGrant
+		}
+		return service.AuthorizationCode{}, errors.Wrap(err, "selecting authorization code")
+	}
+
+	switch {
+	case ac.UsedAt != nil:
+		if ac.FamilyID != nil {
+			const q = `UPDATE refresh_tokens SET "revoked_at" = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+			if _, err := tx.ExecContext(ctx, q, now.UTC(), *ac.FamilyID); err != nil {
+				return service.AuthorizationCode{}, errors.Wrap(err, "revoking refresh tokens")
+			}
+			if err := tx.Commit(); err != nil {
+				return service.AuthorizationCode{}, errors.Wrap(err, "committing transaction")
+			}
+		}
+		return service.AuthorizationCode{}, service.ErrInvalidGrant
+	case !ac.ExpiresAt.After(now.UTC()):
+		return service.AuthorizationCode{}, service.ErrInvalidGrant
+	}
+
+	const q = `UPDATE oauth_codes SET "used_at" = $1, "family_id" = $2 WHERE code_hash = $3`
+	if _, err := tx.ExecContext(ctx, q, now.UTC(), familyID, codeHash); err != nil {
+		return service.AuthorizationCode{}, errors.Wrap(err, "consuming authorization code")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return service.AuthorizationCode{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return ac, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// QueryOrganizations retrieves every Organization, ordered by name.
+func (ur *UserRepository) QueryOrganizations(ctx context.Context) ([]service.Organization, error) {
+	const q = `SELECT * FROM organizations ORDER BY name`
+
+	orgs := []service.Organization{}
+	if err := ur.db.SelectContext(ctx, &orgs, q); err != nil {
+		return nil, errors.Wrap(err, "selecting organizations")
+	}
+
+	return orgs, nil
+}
+
+// GetOrganization retrieves an Organization by its ID.
+func (ur *UserRepository) GetOrganization(ctx context.Context, orgID string) (service.Organization, error) {
+	if _, err := uuid.Parse(orgID); err != nil {
+		return service.Organization{}, service.ErrInvalidID
+	}
+
+	const q = `SELECT * FROM organizations WHERE organization_id = $1`
+
+	var o service.Organization
+	if err := ur.db.GetContext(ctx, &o, q, orgID); err != nil {
+		if err == sql.ErrNoRows {
+			return service.Organization{}, service.ErrNotFound
+		}
+		return service.Organization{}, errors.Wrapf(err, "selecting organization %q", orgID)
+	}
+
+	return o, nil
+}
+
+// CreateOrganization saves an Organization in the DB.
+func (ur *UserRepository) CreateOrganization(ctx context.Context, o service.Organization) error {
+	const q = `
+	INSERT INTO organizations
+		(organization_id, name, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4)`
+
+	if _, err := ur.db.ExecContext(ctx, q, o.ID, o.Name, o.DateCreated.UTC(), o.DateUpdated.UTC()); err != nil {
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedOrganization
+		}
+		return errors.Wrap(err, "inserting organization")
+	}
+
+	return nil
+}
+
+// UpdateOrganization renames an Organization.
+func (ur *UserRepository) UpdateOrganization(ctx context.Context, orgID, name string, now time.Time) error {
+	if _, err := uuid.Parse(orgID); err != nil {
+		return service.ErrInvalidID
+	}
+
+	const q = `UPDATE organizations SET "name" = $1, "date_updated" = $2 WHERE organization_id = $3`
+
+	res, err := ur.db.ExecContext(ctx, q, name, now.UTC(), orgID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedOrganization
+		}
+		return errors.Wrapf(err, "updating organization %s", orgID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "updating organization %s", orgID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteOrganization removes an Organization without Users. Deleted Users
+// that weren't purged yet still belong to it.
+func (ur *UserRepository) DeleteOrganization(ctx context.Context, orgID string) error {
+	if _, err := uuid.Parse(orgID); err != nil {
+		return service.ErrInvalidID
+	}
+
+	const q = `DELETE FROM organizations WHERE organization_id = $1`
+
+	res, err := ur.db.ExecContext(ctx, q, orgID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return service.ErrOrganizationInUse
+		}
+		return errors.Wrapf(err, "deleting organization %s", orgID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "deleting organization %s", orgID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// CreateRefreshToken stores the hash of the first refresh token of a family.
+func (ur *UserRepository) CreateRefreshToken(ctx context.Context, rt service.RefreshToken) error {
+	return insertRefreshToken(ctx, ur.db, rt)
+}
+
+// RotateRefreshToken consumes a refresh token and stores the next one of its
+// family, which is returned. Consuming a token that was already used revokes
+// every token of its family instead.
+func (ur *UserRepository) RotateRefreshToken(ctx context.Context, tokenHash string, next service.RefreshToken, now time.Time) (service.RefreshToken, error) {
+	tx, err := ur.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return service.RefreshToken{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Concurrent rotations of the same token are serialized so only one of
+	// them succeeds.
+	var rt service.RefreshToken
+	if err := tx.GetContext(ctx, &rt, `SELECT * FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, tokenHash); err != nil {
+		if err == sql.ErrNoRows {
+			return service.RefreshToken{}, service.ErrInvalidRefreshToken
+		}
+		return service.RefreshToken{}, errors.Wrap(err, "selecting refresh token")
+	}
+
+	switch {
+	case rt.RevokedAt != nil:
+		return service.RefreshToken{}, service.ErrInvalidRefreshToken
+	case rt.UsedAt != nil:
+		const q = `UPDATE refresh_tokens SET "revoked_at" = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+		if _, err := tx.ExecContext(ctx, q, now.UTC(), rt.FamilyID); err != nil {
+			return service.RefreshToken{}, errors.Wrap(err, "revoking refresh tokens")
+		}
+		if err := tx.Commit(); err != nil {
+			return service.RefreshToken{}, errors.Wrap(err, "committing transaction")
+		}
+		return service.RefreshToken{}, service.ErrRefreshTokenReused
+	case !rt.ExpiresAt.After(now.UTC()):
+		return service.RefreshToken{}, service.ErrInvalidRefreshToken
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET "used_at" = $1 WHERE token_hash = $2`, now.UTC(), tokenHash); err != nil {
+		return service.RefreshToken{}, errors.Wrap(err, "consuming refresh token")
+	}
+
+	next.FamilyID = rt.FamilyID
+	next.UserID = rt.UserID
+	next.ClientID = rt.ClientID
+	next.Scopes = rt.Scopes
+	next.AuthTime = rt.AuthTime
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return service.RefreshToken{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return service.RefreshToken{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return next, nil
+}
+
+// RevokeRefreshTokens revokes every refresh token of a User.
+func (ur *UserRepository) RevokeRefreshTokens(ctx context.Context, userID string, now time.Time) error {
+	const q = `UPDATE refresh_tokens SET "revoked_at" = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+
+	if _, err := ur.db.ExecContext(ctx, q, now.UTC(), userID); err != nil {
+		return errors.Wrap(err, "revoking refresh tokens")
+	}
+
+	return nil
+}
+
+// RevokeRefreshTokenFamily revokes the family of a refresh token of a User.
+// Tokens of other Users are left untouched.
+func (ur *UserRepository) RevokeRefreshTokenFamily(ctx context.Context, userID, tokenHash string, now time.Time) error {
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"revoked_at" = $1
+	WHERE
+		revoked_at IS NULL AND family_id = (
+			SELECT family_id FROM refresh_tokens WHERE token_hash = $2 AND user_id = $3
+		)`
+
+	if _, err := ur.db.ExecContext(ctx, q, now.UTC(), tokenHash, userID); err != nil {
+		return errors.Wrap(err, "revoking refresh tokens")
+	}
+
+	return nil
+}
+
+func insertRefreshToken(ctx context.Context, db sqlx.ExecerContext, rt service.RefreshToken) error {
+	const q = `
+	INSERT INTO refresh_tokens
+		(token_hash, family_id, user_id, client_id, scopes, auth_time, expires_at, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	if _, err := db.ExecContext(ctx, q, rt.TokenHash, rt.FamilyID, rt.UserID, rt.ClientID, rt.Scopes, rt.AuthTime.UTC(), rt.ExpiresAt.UTC(), rt.DateCreated.UTC()); err != nil {
+		return errors.Wrap(err, "inserting refresh token")
+	}
+
+	return nil
+}
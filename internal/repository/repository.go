@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/santiagoh1997/service-template/internal/service"
 )
@@ -29,21 +32,32 @@ func NewRepository(db *sqlx.DB) (*UserRepository, error) {
 func (ur *UserRepository) Create(ctx context.Context, u service.User, now time.Time) (service.User, error) {
 	u.DateCreated = now.UTC()
 	u.DateUpdated = now.UTC()
+	u.Version = 1
+	u.DeletedAt = nil
 
 	const q = `INSERT INTO users
-	(user_id, email, password_hash, roles, name, last_name, country, date_created, date_updated)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	(user_id, tenant_id, email, password_hash, roles, name, last_name, country, date_created, date_updated, version)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 `
-	if _, err := ur.db.ExecContext(ctx, q, u.ID, u.Email, u.PasswordHash, u.Roles, u.Name, u.LastName, u.Country, u.DateCreated, u.DateUpdated); err != nil {
+	if _, err := ur.db.ExecContext(ctx, q, u.ID, u.TenantID, u.Email, u.PasswordHash, u.Roles, u.Name, u.LastName, u.Country, u.DateCreated, u.DateUpdated, u.Version); err != nil {
+		if isUniqueViolation(err) {
+			return service.User{}, service.ErrDuplicatedEmail
+		}
 		return service.User{}, errors.Wrap(err, "inserting user")
 	}
 	return u, nil
 }
 
-// Update changes certain fields of a saved User.
-func (ur *UserRepository) Update(ctx context.Context, userID, name, lastName, country string, now time.Time) error {
+// Update changes certain fields of a saved User of the tenant. If version
+// isn't zero, the User is only updated if it's still at that version. The
+// version the User is at after the change is returned.
+func (ur *UserRepository) Update(ctx context.Context, tenantID, userID, name, lastName, country string, version int, now time.Time) (int, error) {
 	if _, err := uuid.Parse(userID); err != nil {
-		return service.ErrInvalidID
+		return 0, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return 0, err
 	}
 
 	const q = `
@@ -53,46 +67,500 @@ func (ur *UserRepository) Update(ctx context.Context, userID, name, lastName, co
 		"name" = $1,
 		"last_name" = $2,
 		"country" = $3,
-		"date_updated" = $4
+		"date_updated" = $4,
+		"version" = version + 1
+	WHERE
+		user_id = $5 AND deleted_at IS NULL AND ($6 = 0 OR version = $6)
+		AND ($7::uuid IS NULL OR tenant_id = $7)
+	RETURNING
+		version`
+
+	var updated int
+	if err := ur.db.GetContext(ctx, &updated, q, name, lastName, country, now.UTC(), userID, version, tenant); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ur.checkMissing(ctx, tenant, userID)
+		}
+		return 0, errors.Wrap(err, "updating user")
+	}
+
+	return updated, nil
+}
+
+// Patch changes only the provided fields of a saved User of the tenant. If
+// version isn't zero, the User is only updated if it's still at that version.
+// The version the User is at after the change is returned.
+func (ur *UserRepository) Patch(ctx context.Context, tenantID, userID string, pur service.PatchUserRequest, version int, now time.Time) (int, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return 0, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	var sets []string
+	var args []interface{}
+
+	set := func(column string, arg interface{}) {
+		args = append(args, arg)
+		sets = append(sets, fmt.Sprintf("%q = $%d", column, len(args)))
+	}
+
+	if pur.Name != nil {
+		set("name", *pur.Name)
+	}
+	if pur.LastName != nil {
+		set("last_name", *pur.LastName)
+	}
+	if pur.Country != nil {
+		set("country", *pur.Country)
+	}
+	set("date_updated", now.UTC())
+	sets = append(sets, `"version" = version + 1`)
+
+	args = append(args, userID, version, tenant)
+	q := fmt.Sprintf(`UPDATE users SET %s WHERE user_id = $%d AND deleted_at IS NULL AND ($%d = 0 OR version = $%d) AND ($%d::uuid IS NULL OR tenant_id = $%d) RETURNING version`,
+		strings.Join(sets, ", "), len(args)-2, len(args)-1, len(args)-1, len(args), len(args))
+
+	var patched int
+	if err := ur.db.GetContext(ctx, &patched, q, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ur.checkMissing(ctx, tenant, userID)
+		}
+		return 0, errors.Wrap(err, "patching user")
+	}
+
+	return patched, nil
+}
+
+// UpdatePassword replaces the password hash of a User of the tenant and
+// records when it changed.
+func (ur *UserRepository) UpdatePassword(ctx context.Context, tenantID, userID string, passwordHash []byte, now time.Time) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"password_hash" = $1,
+		"password_changed_at" = $2,
+		"date_updated" = $2,
+		"version" = version + 1
 	WHERE
-		user_id=$5`
+		user_id = $3 AND deleted_at IS NULL AND ($4::uuid IS NULL OR tenant_id = $4)`
+
+	res, err := ur.db.ExecContext(ctx, q, passwordHash, now.UTC(), userID, tenant)
+	if err != nil {
+		return errors.Wrap(err, "updating password")
+	}
+
+	return ur.checkAffected(ctx, res, tenant, userID)
+}
 
-	if _, err := ur.db.ExecContext(ctx, q, name, lastName, country, now.UTC(), userID); err != nil {
-		return errors.Wrap(err, "updating user")
+// CreateResetToken stores the hash of a password reset token for a User.
+func (ur *UserRepository) CreateResetToken(ctx context.Context, userID, tokenHash string, expiresAt, now time.Time) error {
+	const q = `
+	INSERT INTO password_reset_tokens
+		(token_hash, user_id, expires_at, date_created)
+	VALUES
+		($1, $2, $3, $4)`
+
+	if _, err := ur.db.ExecContext(ctx, q, tokenHash, userID, expiresAt.UTC(), now.UTC()); err != nil {
+		return errors.Wrap(err, "inserting reset token")
 	}
 
 	return nil
 }
 
-// Delete deletes a User from the DB.
-func (ur *UserRepository) Delete(ctx context.Context, userID string) error {
+// ResetPassword consumes a password reset token and replaces the password
+// of its User in a single statement. The rest of the pending tokens of the
+// User are consumed as well.
+func (ur *UserRepository) ResetPassword(ctx context.Context, tokenHash string, passwordHash []byte, now time.Time) error {
+	const q = `
+	WITH token AS (
+		UPDATE
+			password_reset_tokens
+		SET
+			"used_at" = $1
+		WHERE
+			token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	), pending AS (
+		UPDATE
+			password_reset_tokens
+		SET
+			"used_at" = $1
+		WHERE
+			user_id IN (SELECT user_id FROM token) AND token_hash <> $2 AND used_at IS NULL
+	)
+	UPDATE
+		users
+	SET
+		"password_hash" = $3,
+		"password_changed_at" = $1,
+		"date_updated" = $1,
+		"version" = users.version + 1
+	FROM
+		token
+	WHERE
+		users.user_id = token.user_id AND users.deleted_at IS NULL`
+
+	res, err := ur.db.ExecContext(ctx, q, now.UTC(), tokenHash, passwordHash)
+	if err != nil {
+		return errors.Wrap(err, "resetting password")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "resetting password")
+	}
+	if n == 0 {
+		return service.ErrInvalidResetToken
+	}
+
+	return nil
+}
+
+// CreateVerificationToken stores the hash of a token used to verify the
+// given email of a User.
+func (ur *UserRepository) CreateVerificationToken(ctx context.Context, userID, email, tokenHash string, expiresAt, now time.Time) error {
+	const q = `
+	INSERT INTO email_verification_tokens
+		(token_hash, user_id, email, expires_at, date_created)
+	VALUES
+		($1, $2, $3, $4, $5)`
+
+	if _, err := ur.db.ExecContext(ctx, q, tokenHash, userID, email, expiresAt.UTC(), now.UTC()); err != nil {
+		return errors.Wrap(err, "inserting verification token")
+	}
+
+	return nil
+}
+
+// VerifyEmail consumes an email verification token and marks the email of
+// its User as verified, as long as the User still has the email the token
+// was issued for.
+func (ur *UserRepository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	const q = `
+	WITH token AS (
+		UPDATE
+			email_verification_tokens
+		SET
+			"used_at" = $1
+		WHERE
+			token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id, email
+	)
+	UPDATE
+		users
+	SET
+		"email_verified_at" = COALESCE(users.email_verified_at, $1)
+	FROM
+		token
+	WHERE
+		users.user_id = token.user_id AND users.email = token.email AND users.deleted_at IS NULL`
+
+	res, err := ur.db.ExecContext(ctx, q, now.UTC(), tokenHash)
+	if err != nil {
+		return errors.Wrap(err, "verifying email")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "verifying email")
+	}
+	if n == 0 {
+		return service.ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// CreateEmailChangeToken stores the hash of a token used to change the email
+// of a User. Previous pending changes of the User are discarded.
+func (ur *UserRepository) CreateEmailChangeToken(ctx context.Context, userID, oldEmail, newEmail, tokenHash string, expiresAt, now time.Time) error {
+	const q = `
+	WITH pending AS (
+		UPDATE
+			email_change_tokens
+		SET
+			"used_at" = $6
+		WHERE
+			user_id = $2 AND used_at IS NULL
+	)
+	INSERT INTO email_change_tokens
+		(token_hash, user_id, old_email, new_email, expires_at, date_created)
+	VALUES
+		($1, $2, $3, $4, $5, $6)`
+
+	if _, err := ur.db.ExecContext(ctx, q, tokenHash, userID, oldEmail, newEmail, expiresAt.UTC(), now.UTC()); err != nil {
+		return errors.Wrap(err, "inserting email change token")
+	}
+
+	return nil
+}
+
+// ChangeEmail consumes an email change token and replaces the email of its
+// User in a single statement. The unique index on active emails makes the
+// change fail if the new email was taken since the token was issued.
+func (ur *UserRepository) ChangeEmail(ctx context.Context, tokenHash string, now time.Time) error {
+	const q = `
+	WITH token AS (
+		UPDATE
+			email_change_tokens
+		SET
+			"used_at" = $1
+		WHERE
+			token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id, old_email, new_email
+	)
+	UPDATE
+		users
+	SET
+		"email" = token.new_email,
+		"email_verified_at" = $1,
+		"date_updated" = $1,
+		"version" = users.version + 1
+	FROM
+		token
+	WHERE
+		users.user_id = token.user_id AND users.email = token.old_email AND users.deleted_at IS NULL`
+
+	res, err := ur.db.ExecContext(ctx, q, now.UTC(), tokenHash)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedEmail
+		}
+		return errors.Wrap(err, "changing email")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "changing email")
+	}
+	if n == 0 {
+		return service.ErrInvalidEmailChangeToken
+	}
+
+	return nil
+}
+
+// UpdateRoles replaces the roles of a User of the tenant and records the
+// change in the same statement. The User is only updated if it's still at
+// the given version.
+func (ur *UserRepository) UpdateRoles(ctx context.Context, tenantID, userID string, roles []string, version int, rc service.RoleChange) error {
 	if _, err := uuid.Parse(userID); err != nil {
 		return service.ErrInvalidID
 	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
 
 	const q = `
-	DELETE FROM
+	WITH updated AS (
+		UPDATE
+			users
+		SET
+			"roles" = $1,
+			"date_updated" = $2,
+			"version" = version + 1
+		WHERE
+			user_id = $3 AND deleted_at IS NULL AND version = $4
+			AND ($9::uuid IS NULL OR tenant_id = $9)
+		RETURNING user_id
+	)
+	INSERT INTO role_changes
+		(change_id, user_id, actor_id, granted, revoked, date_created)
+	SELECT
+		$5, user_id, $6, $7, $8, $2
+	FROM
+		updated`
+
+	res, err := ur.db.ExecContext(ctx, q, pq.Array(roles), rc.DateCreated.UTC(), userID, version,
+		rc.ID, rc.ActorID, rc.Granted, rc.Revoked, tenant)
+	if err != nil {
+		return errors.Wrap(err, "updating roles")
+	}
+
+	return ur.checkAffected(ctx, res, tenant, userID)
+}
+
+// QueryRoleChanges retrieves the role changes of a User of the tenant, most
+// recent first.
+func (ur *UserRepository) QueryRoleChanges(ctx context.Context, tenantID, userID string) ([]service.RoleChange, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+	SELECT
+		rc.*
+	FROM
+		role_changes AS rc
+		JOIN users AS u ON u.user_id = rc.user_id
+	WHERE
+		rc.user_id = $1 AND ($2::uuid IS NULL OR u.tenant_id = $2)
+	ORDER BY
+		rc.date_created DESC, rc.change_id`
+
+	changes := []service.RoleChange{}
+	if err := ur.db.SelectContext(ctx, &changes, q, userID, tenant); err != nil {
+		return nil, errors.Wrap(err, "selecting role changes")
+	}
+
+	return changes, nil
+}
+
+// Delete marks a User of the tenant as deleted. The User is kept in the DB
+// until it's purged. If version isn't zero, the User is only deleted if it's
+// still at that version.
+func (ur *UserRepository) Delete(ctx context.Context, tenantID, userID string, version int, now time.Time) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	UPDATE
 		users
+	SET
+		"deleted_at" = $1,
+		"date_updated" = $1,
+		"version" = version + 1
 	WHERE
-		user_id = $1`
+		user_id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)
+		AND ($4::uuid IS NULL OR tenant_id = $4)`
 
-	if _, err := ur.db.ExecContext(ctx, q, userID); err != nil {
+	res, err := ur.db.ExecContext(ctx, q, now.UTC(), userID, version, tenant)
+	if err != nil {
 		return errors.Wrapf(err, "deleting user %s", userID)
 	}
 
+	return ur.checkAffected(ctx, res, tenant, userID)
+}
+
+// Restore undoes the deletion of a User of the tenant that hasn't been
+// purged yet.
+func (ur *UserRepository) Restore(ctx context.Context, tenantID, userID string, now time.Time) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		"deleted_at" = NULL,
+		"date_updated" = $1,
+		"version" = version + 1
+	WHERE
+		user_id = $2 AND deleted_at IS NOT NULL AND ($3::uuid IS NULL OR tenant_id = $3)`
+
+	res, err := ur.db.ExecContext(ctx, q, now.UTC(), userID, tenant)
+	if err != nil {
+		// Another User may have taken the email in the meantime.
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedEmail
+		}
+		return errors.Wrapf(err, "restoring user %s", userID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "restoring user %s", userID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
 	return nil
 }
 
-// GetByID retrieves a User from the DB by its ID.
-func (ur *UserRepository) GetByID(ctx context.Context, userID string) (service.User, error) {
+// Purge permanently removes the Users deleted before the given time.
+// It returns the amount of removed Users.
+func (ur *UserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	const q = `DELETE FROM users WHERE deleted_at < $1`
+
+	res, err := ur.db.ExecContext(ctx, q, deletedBefore.UTC())
+	if err != nil {
+		return 0, errors.Wrap(err, "purging users")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "purging users")
+	}
+
+	return int(n), nil
+}
+
+// checkAffected finds out why a statement targeting a User of the tenant
+// didn't affect any row: either the User doesn't exist or its version didn't
+// match. Users of other tenants don't exist.
+func (ur *UserRepository) checkAffected(ctx context.Context, res sql.Result, tenant interface{}, userID string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "checking affected rows")
+	}
+	if n != 0 {
+		return nil
+	}
+
+	return ur.checkMissing(ctx, tenant, userID)
+}
+
+// checkMissing finds out why a statement conditioned on the version of a User
+// of the tenant didn't match it: either the User doesn't exist or it's at
+// another version.
+func (ur *UserRepository) checkMissing(ctx context.Context, tenant interface{}, userID string) error {
+	const q = `
+	SELECT EXISTS (
+		SELECT 1 FROM users WHERE user_id = $1 AND deleted_at IS NULL AND ($2::uuid IS NULL OR tenant_id = $2)
+	)`
+
+	var exists bool
+	if err := ur.db.GetContext(ctx, &exists, q, userID, tenant); err != nil {
+		return errors.Wrapf(err, "checking user %q", userID)
+	}
+	if exists {
+		return service.ErrConflict
+	}
+
+	return service.ErrNotFound
+}
+
+// GetByID retrieves a User of the tenant from the DB by its ID.
+func (ur *UserRepository) GetByID(ctx context.Context, tenantID, userID string) (service.User, error) {
 	if _, err := uuid.Parse(userID); err != nil {
 		return service.User{}, service.ErrInvalidID
 	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return service.User{}, err
+	}
 
-	const q = `SELECT * FROM users WHERE user_id = $1`
+	const q = `SELECT * FROM users WHERE user_id = $1 AND deleted_at IS NULL AND ($2::uuid IS NULL OR tenant_id = $2)`
 
 	var u service.User
-	if err := ur.db.GetContext(ctx, &u, q, userID); err != nil {
+	if err := ur.db.GetContext(ctx, &u, q, userID, tenant); err != nil {
 		if err == sql.ErrNoRows {
 			return service.User{}, service.ErrNotFound
 		}
@@ -105,7 +573,7 @@ func (ur *UserRepository) GetByID(ctx context.Context, userID string) (service.U
 // CheckEmailInUse returns true if a given email is already being used.
 func (ur UserRepository) CheckEmailInUse(ctx context.Context, email string) (bool, error) {
 	// TODO: improve...
-	const q1 = `SELECT COUNT(*) FROM users AS numUsers WHERE email=$1`
+	const q1 = `SELECT COUNT(*) FROM users AS numUsers WHERE email=$1 AND deleted_at IS NULL`
 
 	var numUsers int
 	if err := ur.db.QueryRowContext(ctx, q1, email).Scan(&numUsers); err != nil {
@@ -121,7 +589,7 @@ func (ur UserRepository) CheckEmailInUse(ctx context.Context, email string) (boo
 
 // GetByEmail retrieves a User by its email.
 func (ur UserRepository) GetByEmail(ctx context.Context, email string) (service.User, error) {
-	const q = `SELECT * FROM users WHERE email = $1`
+	const q = `SELECT * FROM users WHERE email = $1 AND deleted_at IS NULL`
 
 	var u service.User
 	if err := ur.db.GetContext(ctx, &u, q, email); err != nil {
@@ -133,3 +601,183 @@ func (ur UserRepository) GetByEmail(ctx context.Context, email string) (service.
 
 	return u, nil
 }
+
+// Query retrieves a page of the Users of the tenant matching the filter
+// along with the total amount of Users that match it.
+func (ur UserRepository) Query(ctx context.Context, tenantID string, filter service.QueryFilter, orderBy service.OrderBy, page, rowsPerPage int) ([]service.User, int, error) {
+	column, ok := orderByColumns[orderBy.Field]
+	if !ok {
+		return nil, 0, service.ErrInvalidOrderBy
+	}
+	direction := "ASC"
+	if orderBy.Direction == service.DESC {
+		direction = "DESC"
+	}
+
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+	where, args := filterClause(tenant, filter)
+
+	q := `SELECT COUNT(*) FROM users` + where
+
+	var total int
+	if err := ur.db.GetContext(ctx, &total, q, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "counting users")
+	}
+
+	// The user_id is used as a tie breaker so pages are stable.
+	q = fmt.Sprintf(`SELECT * FROM users%s ORDER BY %s %s, user_id %s LIMIT $%d OFFSET $%d`,
+		where, column, direction, direction, len(args)+1, len(args)+2)
+	args = append(args, rowsPerPage, (page-1)*rowsPerPage)
+
+	users := []service.User{}
+	if err := ur.db.SelectContext(ctx, &users, q, args...); err != nil {
+		return nil, 0, errors.Wrap(err, "selecting users")
+	}
+
+	return users, total, nil
+}
+
+// QueryAfter retrieves up to limit Users of the tenant matching the filter
+// that come after the Cursor when ordered by (date_created, user_id). It
+// seeks through the users_date_created_user_id_idx index, or the one
+// including the tenant, instead of using an offset.
+func (ur UserRepository) QueryAfter(ctx context.Context, tenantID string, filter service.QueryFilter, after service.Cursor, limit int) ([]service.User, error) {
+	direction, cmp := "ASC", ">"
+	if after.Direction == service.DESC {
+		direction, cmp = "DESC", "<"
+	}
+
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	where, args := filterClause(tenant, filter)
+	where += fmt.Sprintf(" AND (date_created, user_id) %s ($%d, $%d)", cmp, len(args)+1, len(args)+2)
+	args = append(args, after.DateCreated.UTC(), after.ID)
+
+	q := fmt.Sprintf(`SELECT * FROM users%s ORDER BY date_created %s, user_id %s LIMIT $%d`,
+		where, direction, direction, len(args)+1)
+	args = append(args, limit)
+
+	users := []service.User{}
+	if err := ur.db.SelectContext(ctx, &users, q, args...); err != nil {
+		return nil, errors.Wrap(err, "selecting users")
+	}
+
+	return users, nil
+}
+
+// searchDocument is the expression the search indexes are built on. It must
+// match the one used in the migrations so the indexes can be used.
+const searchDocument = `(coalesce(name, '') || ' ' || coalesce(last_name, '') || ' ' || coalesce(email, ''))`
+
+// Search retrieves up to limit Users of the tenant whose name, last name or
+// email resemble the search terms, ordered by relevance. Trigram similarity
+// tolerates typos and partial words while the full-text match favors whole words.
+func (ur UserRepository) Search(ctx context.Context, tenantID, terms string, limit int) ([]service.User, error) {
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		users
+	WHERE
+		deleted_at IS NULL
+		AND ($3::uuid IS NULL OR tenant_id = $3)
+		AND ($1 <% ` + searchDocument + `
+		OR to_tsvector('simple', ` + searchDocument + `) @@ plainto_tsquery('simple', $1))
+	ORDER BY
+		word_similarity($1, ` + searchDocument + `)
+		+ ts_rank(to_tsvector('simple', ` + searchDocument + `), plainto_tsquery('simple', $1)) DESC,
+		user_id
+	LIMIT $2`
+
+	users := []service.User{}
+	if err := ur.db.SelectContext(ctx, &users, q, terms, limit, tenant); err != nil {
+		return nil, errors.Wrapf(err, "searching users %q", terms)
+	}
+
+	return users, nil
+}
+
+// orderByColumns maps the fields a query can be ordered by to their columns.
+var orderByColumns = map[string]string{
+	service.OrderByID:          "user_id",
+	service.OrderByName:        "name",
+	service.OrderByLastName:    "last_name",
+	service.OrderByEmail:       "email",
+	service.OrderByCountry:     "country",
+	service.OrderByDateCreated: "date_created",
+	service.OrderByDateUpdated: "date_updated",
+}
+
+// filterClause builds a WHERE clause and its arguments from a QueryFilter.
+// Deleted Users and the ones of other tenants are always filtered out.
+func filterClause(tenant interface{}, filter service.QueryFilter) (string, []interface{}) {
+	conds := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if tenant != nil {
+		add("tenant_id = $%d", tenant)
+	}
+	if filter.Country != "" {
+		add("country = $%d", filter.Country)
+	}
+	if filter.Role != "" {
+		add("$%d = ANY(roles)", filter.Role)
+	}
+	if filter.EmailPrefix != "" {
+		add(`email LIKE $%d ESCAPE '\'`, escapeLike(filter.EmailPrefix)+"%")
+	}
+	if !filter.CreatedAfter.IsZero() {
+		add("date_created >= $%d", filter.CreatedAfter.UTC())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		add("date_created < $%d", filter.CreatedBefore.UTC())
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// tenantArg returns the argument limiting a statement to the Users of a
+// tenant. Statements are written so a NULL argument, used for
+// service.AnyTenant, lifts the limit.
+func tenantArg(tenantID string) (interface{}, error) {
+	if tenantID == service.AnyTenant {
+		return nil, nil
+	}
+	if _, err := uuid.Parse(tenantID); err != nil {
+		return nil, service.ErrInvalidID
+	}
+	return tenantID, nil
+}
+
+// isUniqueViolation reports whether err was caused by a unique constraint.
+func isUniqueViolation(err error) bool {
+	pqErr, ok := errors.Cause(err).(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err was caused by a foreign key constraint.
+func isForeignKeyViolation(err error) bool {
+	pqErr, ok := errors.Cause(err).(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern.
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
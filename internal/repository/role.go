@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// QueryRoles retrieves every Role with the permissions it grants, ordered by name.
+func (ur *UserRepository) QueryRoles(ctx context.Context) ([]service.Role, error) {
+	const q = `
+	SELECT
+		r.name,
+		r.date_created,
+		COALESCE(array_agg(p.permission ORDER BY p.permission) FILTER (WHERE p.permission IS NOT NULL), '{}') AS permissions
+	FROM
+		roles AS r
+	LEFT JOIN
+		role_permissions AS p ON p.role = r.name
+	GROUP BY
+		r.name, r.date_created
+	ORDER BY
+		r.name`
+
+	roles := []service.Role{}
+	if err := ur.db.SelectContext(ctx, &roles, q); err != nil {
+		return nil, errors.Wrap(err, "selecting roles")
+	}
+
+	return roles, nil
+}
+
+// CreateRole inserts a Role together with its permissions.
+func (ur *UserRepository) CreateRole(ctx context.Context, r service.Role) error {
+	const q = `
+	WITH role AS (
+		INSERT INTO roles
+			(name, date_created)
+		VALUES
+			($1, $2)
+		RETURNING name
+	)
+	INSERT INTO role_permissions
+		(role, permission)
+	SELECT
+		role.name, permission
+	FROM
+		role, unnest($3::TEXT[]) AS permission`
+
+	if _, err := ur.db.ExecContext(ctx, q, r.Name, r.DateCreated.UTC(), pq.Array(r.Permissions)); err != nil {
+		if isUniqueViolation(err) {
+			return service.ErrDuplicatedRole
+		}
+		return errors.Wrap(err, "inserting role")
+	}
+
+	return nil
+}
+
+// UpdateRole replaces the permissions granted by a Role.
+func (ur *UserRepository) UpdateRole(ctx context.Context, name string, permissions []string) error {
+	tx, err := ur.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// Lock the role so concurrent updates don't mix their permissions.
+	var locked string
+	if err := tx.GetContext(ctx, &locked, `SELECT name FROM roles WHERE name = $1 FOR UPDATE`, name); err != nil {
+		if err == sql.ErrNoRows {
+			return service.ErrNotFound
+		}
+		return errors.Wrapf(err, "locking role %q", name)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role = $1`, name); err != nil {
+		return errors.Wrap(err, "deleting permissions")
+	}
+
+	const q = `
+	INSERT INTO role_permissions
+		(role, permission)
+	SELECT
+		$1, permission
+	FROM
+		unnest($2::TEXT[]) AS permission`
+
+	if _, err := tx.ExecContext(ctx, q, name, pq.Array(permissions)); err != nil {
+		return errors.Wrap(err, "inserting permissions")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+
+	return nil
+}
+
+// DeleteRole removes a Role as long as it isn't assigned to any User,
+// including deleted Users that could be restored.
+func (ur *UserRepository) DeleteRole(ctx context.Context, name string) error {
+	const q = `
+	DELETE FROM
+		roles
+	WHERE
+		name = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE $1 = ANY(roles))`
+
+	res, err := ur.db.ExecContext(ctx, q, name)
+	if err != nil {
+		return errors.Wrap(err, "deleting role")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "deleting role")
+	}
+	if n != 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := ur.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, name); err != nil {
+		return errors.Wrapf(err, "checking role %q", name)
+	}
+	if exists {
+		return service.ErrRoleInUse
+	}
+
+	return service.ErrNotFound
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/service"
+)
+
+// QueryServiceAccounts retrieves the ServiceAccounts of the tenant, ordered
+// by name.
+func (ur *UserRepository) QueryServiceAccounts(ctx context.Context, tenantID string) ([]service.ServiceAccount, error) {
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `SELECT * FROM service_accounts WHERE ($1::uuid IS NULL OR tenant_id = $1) ORDER BY name, service_account_id`
+
+	accounts := []service.ServiceAccount{}
+	if err := ur.db.SelectContext(ctx, &accounts, q, tenant); err != nil {
+		return nil, errors.Wrap(err, "selecting service accounts")
+	}
+
+	return accounts, nil
+}
+
+// GetServiceAccount retrieves a ServiceAccount of the tenant by its ID.
+func (ur *UserRepository) GetServiceAccount(ctx context.Context, tenantID, accountID string) (service.ServiceAccount, error) {
+	if _, err := uuid.Parse(accountID); err != nil {
+		return service.ServiceAccount{}, service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return service.ServiceAccount{}, err
+	}
+
+	const q = `SELECT * FROM service_accounts WHERE service_account_id = $1 AND ($2::uuid IS NULL OR tenant_id = $2)`
+
+	var sa service.ServiceAccount
+	if err := ur.db.GetContext(ctx, &sa, q, accountID, tenant); err != nil {
+		if err == sql.ErrNoRows {
+			return service.ServiceAccount{}, service.ErrNotFound
+		}
+		return service.ServiceAccount{}, errors.Wrapf(err, "selecting service account %q", accountID)
+	}
+
+	return sa, nil
+}
+
+// CreateServiceAccount saves a ServiceAccount in the DB.
+func (ur *UserRepository) CreateServiceAccount(ctx context.Context, sa service.ServiceAccount) error {
+	const q = `
+	INSERT INTO service_accounts
+		(service_account_id, tenant_id, name, secret_hash, permissions, date_created, date_updated)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7)`
+
+	if _, err := ur.db.ExecContext(ctx, q, sa.ID, sa.TenantID, sa.Name, sa.SecretHash, sa.Permissions, sa.DateCreated.UTC(), sa.DateUpdated.UTC()); err != nil {
+		return errors.Wrap(err, "inserting service account")
+	}
+
+	return nil
+}
+
+// UpdateServiceAccountSecret replaces the hash of the secret of a
+// ServiceAccount of the tenant.
+func (ur *UserRepository) UpdateServiceAccountSecret(ctx context.Context, tenantID, accountID, secretHash string, now time.Time) error {
+	if _, err := uuid.Parse(accountID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE service_accounts SET "secret_hash" = $1, "date_updated" = $2 WHERE service_account_id = $3 AND ($4::uuid IS NULL OR tenant_id = $4)`
+
+	res, err := ur.db.ExecContext(ctx, q, secretHash, now.UTC(), accountID, tenant)
+	if err != nil {
+		return errors.Wrapf(err, "updating service account %s", accountID)
+	}
+
+	return checkServiceAccountAffected(res, accountID)
+}
+
+// DeleteServiceAccount removes a ServiceAccount of the tenant.
+func (ur *UserRepository) DeleteServiceAccount(ctx context.Context, tenantID, accountID string) error {
+	if _, err := uuid.Parse(accountID); err != nil {
+		return service.ErrInvalidID
+	}
+	tenant, err := tenantArg(tenantID)
+	if err != nil {
+		return err
+	}
+
+	const q = `DELETE FROM service_accounts WHERE service_account_id = $1 AND ($2::uuid IS NULL OR tenant_id = $2)`
+
+	res, err := ur.db.ExecContext(ctx, q, accountID, tenant)
+	if err != nil {
+		return errors.Wrapf(err, "deleting service account %s", accountID)
+	}
+
+	return checkServiceAccountAffected(res, accountID)
+}
+
+func checkServiceAccountAffected(res sql.Result, accountID string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "updating service account %s", accountID)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+	return nil
+}
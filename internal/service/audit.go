@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// These are the actions recorded by audit events.
+const (
+	AuditUserCreate           = "user.create"
+	AuditUserUpdate           = "user.update"
+	AuditUserPatch            = "user.patch"
+	AuditUserDelete           = "user.delete"
+	AuditUserRestore          = "user.restore"
+	AuditUserLogin            = "user.login"
+	AuditUserImpersonate      = "user.impersonate"
+	AuditTokenRefresh         = "user.token_refresh"
+	AuditLogout               = "user.logout"
+	AuditTokensRevoke         = "user.tokens_revoke"
+	AuditPasswordChange       = "user.password_change"
+	AuditPasswordForgot       = "user.password_forgot"
+	AuditPasswordReset        = "user.password_reset"
+	AuditEmailVerify          = "user.email_verify"
+	AuditEmailResend          = "user.email_resend"
+	AuditEmailChange          = "user.email_change"
+	AuditEmailConfirm         = "user.email_confirm"
+	AuditRoleChange           = "user.role_change"
+	AuditRoleCreate           = "role.create"
+	AuditRoleUpdate           = "role.update"
+	AuditRoleDelete           = "role.delete"
+	AuditOrganizationCreate   = "organization.create"
+	AuditOrganizationUpdate   = "organization.update"
+	AuditOrganizationDelete   = "organization.delete"
+	AuditGroupCreate          = "group.create"
+	AuditGroupUpdate          = "group.update"
+	AuditGroupDelete          = "group.delete"
+	AuditGroupMemberAdd       = "group.member_add"
+	AuditGroupMemberRemove    = "group.member_remove"
+	AuditClientCreate         = "client.create"
+	AuditClientDelete         = "client.delete"
+	AuditClientAuthorize      = "client.authorize"
+	AuditCodeExchange         = "client.code_exchange"
+	AuditServiceAccountCreate = "service_account.create"
+	AuditServiceAccountRotate = "service_account.secret_rotate"
+	AuditServiceAccountDelete = "service_account.delete"
+	AuditServiceAccountToken  = "service_account.token"
+)
+
+// AuditEvent records an attempt to mutate the state of the service: who
+// attempted it, on what, when and from where, the changes it made and the
+// error it failed with, if any. Events are chained by their hashes so events
+// removed from the middle of the chain can be detected.
+type AuditEvent struct {
+	Seq            int64        `db:"seq" json:"seq"`
+	ID             string       `db:"event_id" json:"id"`
+	ActorID        string       `db:"actor_id" json:"actor_id,omitempty"`
+	ImpersonatorID string       `db:"impersonator_id" json:"impersonator_id,omitempty"`
+	TenantID       *string      `db:"tenant_id" json:"tenant_id,omitempty"`
+	Action         string       `db:"action" json:"action"`
+	TargetID       string       `db:"target_id" json:"target_id,omitempty"`
+	TraceID        string       `db:"trace_id" json:"trace_id"`
+	ClientIP       string       `db:"client_ip" json:"client_ip,omitempty"`
+	Changes        AuditChanges `db:"changes" json:"changes"`
+	Error          string       `db:"error" json:"error,omitempty"`
+	DateCreated    time.Time    `db:"date_created" json:"date_created"`
+	PrevHash       string       `db:"prev_hash" json:"prev_hash"`
+	Hash           string       `db:"hash" json:"hash"`
+}
+
+// ComputeHash returns the hash of the event chained to the hash of the
+// previous one. The sequence number isn't part of it since it's assigned by
+// the DB.
+func (e AuditEvent) ComputeHash(prevHash string) string {
+	var tenant string
+	if e.TenantID != nil {
+		tenant = *e.TenantID
+	}
+
+	fields := []string{
+		prevHash,
+		e.ID,
+		e.DateCreated.UTC().Format(time.RFC3339Nano),
+		e.ActorID,
+		e.ImpersonatorID,
+		tenant,
+		e.Action,
+		e.TargetID,
+		e.TraceID,
+		e.ClientIP,
+		string(e.Changes),
+		e.Error,
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditHead is the last event appended to the chain of audit events. It's
+// kept apart from the events and signed, so events removed from the end of
+// the chain can be detected.
+type AuditHead struct {
+	Seq       int64  `db:"seq"`
+	Hash      string `db:"hash"`
+	Signature string `db:"signature"`
+}
+
+// Sign returns the head signed with the key. Without a key it isn't signed.
+func (h AuditHead) Sign(key []byte) AuditHead {
+	h.Signature = ""
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(strconv.FormatInt(h.Seq, 10) + "\n" + h.Hash))
+		h.Signature = hex.EncodeToString(mac.Sum(nil))
+	}
+	return h
+}
+
+// Verify reports whether the head was signed with the key. Any head is
+// accepted without a key.
+func (h AuditHead) Verify(key []byte) bool {
+	if len(key) == 0 {
+		return true
+	}
+	return hmac.Equal([]byte(h.Signature), []byte(h.Sign(key).Signature))
+}
+
+// AuditChanges is the JSON document of the changes made by a mutation,
+// keyed by the field they were made to. It's kept as is since it's part of
+// the hash of the event.
+type AuditChanges string
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c AuditChanges) MarshalJSON() ([]byte, error) {
+	if c == "" {
+		return []byte("{}"), nil
+	}
+	return []byte(c), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *AuditChanges) UnmarshalJSON(data []byte) error {
+	*c = AuditChanges(data)
+	return nil
+}
+
+// AuditChange is the value of a field before and after a mutation. Nil
+// values belong to fields of resources that didn't exist.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter holds the criteria used to filter audit events in a query.
+// Zero values are ignored.
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+	From     time.Time
+	To       time.Time
+}
+
+// AuditResult is a page of audit events, most recent first.
+type AuditResult struct {
+	Items       []AuditEvent `json:"items"`
+	Total       int          `json:"total"`
+	Page        int          `json:"page"`
+	RowsPerPage int          `json:"rows_per_page"`
+}
+
+// AuditVerification is the result of verifying the chain of audit events.
+// Events removed from the end of the chain can't be detected by the chain
+// itself, so it's compared against its AuditHead too.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Events   int    `json:"events"`
+	Head     string `json:"head"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+}
+
+// auditBatchSize is the number of events verified at a time.
+const auditBatchSize = 500
+
+// clientIPKey is used to store/retrieve the IP address of a client from a
+// context.Context.
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the IP address of the client
+// performing a request, so it can be recorded by audit events.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// clientIP returns the IP address of the client carried by ctx, if any.
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// QueryAuditEvents retrieves a page of the audit events of the tenant of the
+// claims matching the filter, most recent first.
+func (us userService) QueryAuditEvents(ctx context.Context, traceID string, claims auth.Claims, filter AuditFilter, page, rowsPerPage int) (AuditResult, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryAuditEvents")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermAuditRead, auth.Attributes{auth.AttrType: resourceAuditEvent}); err != nil {
+		return AuditResult{}, err
+	}
+
+	if page < 1 || rowsPerPage < 1 || rowsPerPage > MaxRowsPerPage {
+		return AuditResult{}, ErrInvalidPagination
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return AuditResult{}, err
+	}
+
+	events, total, err := us.repo.QueryAuditEvents(ctx, tenant, filter, page, rowsPerPage)
+	if err != nil {
+		return AuditResult{}, errors.Wrap(err, "querying audit events")
+	}
+
+	ar := AuditResult{
+		Items:       events,
+		Total:       total,
+		Page:        page,
+		RowsPerPage: rowsPerPage,
+	}
+
+	return ar, nil
+}
+
+// VerifyAuditEvents walks the whole chain of audit events checking that
+// every event is linked to the previous one, that none of them was modified
+// and that the chain ends at its head. Since the chain spans every tenant,
+// only Users allowed to act across tenants can verify it.
+func (us userService) VerifyAuditEvents(ctx context.Context, traceID string, claims auth.Claims) (AuditVerification, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.verifyAuditEvents")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermAuditRead, auth.Attributes{auth.AttrType: resourceAuditEvent}); err != nil {
+		return AuditVerification{}, err
+	}
+	if err := us.requireAnyTenant(ctx, claims); err != nil {
+		return AuditVerification{}, err
+	}
+
+	head, err := us.repo.GetAuditHead(ctx)
+	if err != nil {
+		return AuditVerification{}, errors.Wrap(err, "getting audit chain head")
+	}
+
+	// A head that doesn't match its signature was changed to point to an
+	// earlier event, hiding the ones removed after it.
+	if !head.Verify(us.cfg.AuditKey) {
+		return AuditVerification{BrokenAt: head.Seq}, nil
+	}
+
+	// Events appended after the head was read aren't verified.
+	var (
+		v     = AuditVerification{Valid: true}
+		after int64
+	)
+	for {
+		events, err := us.repo.QueryAuditChain(ctx, after, head.Seq, auditBatchSize)
+		if err != nil {
+			return AuditVerification{}, errors.Wrap(err, "querying audit events")
+		}
+
+		for _, e := range events {
+			if e.PrevHash != v.Head || e.ComputeHash(v.Head) != e.Hash {
+				v.Valid = false
+				v.BrokenAt = e.Seq
+				return v, nil
+			}
+			v.Head = e.Hash
+			v.Events++
+			after = e.Seq
+		}
+
+		if len(events) < auditBatchSize {
+			break
+		}
+	}
+
+	// Events were removed from the end of the chain.
+	if after != head.Seq || v.Head != head.Hash {
+		v.Valid = false
+		v.BrokenAt = head.Seq
+	}
+
+	return v, nil
+}
@@ -0,0 +1,673 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+// auditingDecorator records an AuditEvent for every mutation attempted
+// through the UserService, including the failed ones. The state of the
+// affected resources is read before and after the mutation to record the
+// changes it made. Resources that can't be read are recorded as missing.
+type auditingDecorator struct {
+	repo    Repository
+	key     []byte
+	log     *log.Logger
+	Service UserService
+}
+
+// NewAuditingDecorator returns a UserService that records its mutations as
+// audit events, signing the head of their chain with the key. Events that
+// can't be recorded are written to log, if set, and don't change the result
+// of the mutation, which may already be committed.
+func NewAuditingDecorator(repo Repository, us UserService, key []byte, log *log.Logger) (UserService, error) {
+	if repo == nil {
+		return nil, errors.New("repo can't be nil")
+	}
+	if us == nil {
+		return nil, errors.New("UserService can't be nil")
+	}
+
+	return &auditingDecorator{
+		repo:    repo,
+		key:     key,
+		log:     log,
+		Service: us,
+	}, nil
+}
+
+// snapshot is the state of a resource as recorded by audit events. Missing
+// resources have a nil snapshot.
+type snapshot map[string]interface{}
+
+// newAuditEvent returns an event of an action performed with the claims on a
+// target. Anonymous actions have empty claims.
+func newAuditEvent(ctx context.Context, traceID string, claims auth.Claims, action, targetID string, now time.Time) AuditEvent {
+	e := AuditEvent{
+		ID:       uuid.New().String(),
+		ActorID:  claims.Subject,
+		Action:   action,
+		TargetID: targetID,
+		TraceID:  traceID,
+		ClientIP: clientIP(ctx),
+
+		// The DB only keeps microseconds, which would change the hash.
+		DateCreated: now.UTC().Truncate(time.Microsecond),
+	}
+	if claims.Impersonated() {
+		e.ImpersonatorID = claims.Actor.Subject
+	}
+	if claims.TenantID != "" {
+		tenant := claims.TenantID
+		e.TenantID = &tenant
+	}
+	return e
+}
+
+// record saves the event along with the changes between the snapshots and
+// the error of the mutation. The error of the mutation is returned as is so
+// callers can still compare it.
+func (d *auditingDecorator) record(ctx context.Context, e AuditEvent, before, after snapshot, err error) error {
+	// Events belong to the tenant of the affected resource, when it has one.
+	for _, s := range []snapshot{after, before} {
+		if tenant, ok := s["tenant_id"].(string); ok && tenant != "" {
+			e.TenantID = &tenant
+			break
+		}
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	changes, rerr := auditChanges(before, after)
+	if rerr == nil {
+		e.Changes = changes
+		_, rerr = d.repo.CreateAuditEvent(ctx, e, d.key)
+	}
+	if rerr != nil && d.log != nil {
+		d.log.Printf("audit: ERROR: recording %s event %s: %v", e.Action, e.ID, rerr)
+	}
+
+	return err
+}
+
+// auditChanges returns the JSON document of the fields that differ between
+// two snapshots.
+func auditChanges(before, after snapshot) (AuditChanges, error) {
+	changes := make(map[string]AuditChange)
+	for field, b := range before {
+		a := after[field]
+		same, err := sameJSON(b, a)
+		if err != nil {
+			return "", err
+		}
+		if !same {
+			changes[field] = AuditChange{Before: b, After: a}
+		}
+	}
+	for field, a := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = AuditChange{After: a}
+		}
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return "", errors.Wrap(err, "encoding changes")
+	}
+	return AuditChanges(data), nil
+}
+
+func sameJSON(a, b interface{}) (bool, error) {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false, errors.Wrap(err, "encoding changes")
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false, errors.Wrap(err, "encoding changes")
+	}
+	return bytes.Equal(ja, jb), nil
+}
+
+// userSnapshot returns the recorded state of a User.
+func userSnapshot(u User) snapshot {
+	return snapshot{
+		"tenant_id":           u.TenantID,
+		"name":                u.Name,
+		"last_name":           u.LastName,
+		"email":               u.Email,
+		"country":             u.Country,
+		"roles":               []string(u.Roles),
+		"email_verified":      u.EmailVerifiedAt != nil,
+		"password_changed_at": u.PasswordChangedAt,
+	}
+}
+
+func (d *auditingDecorator) user(ctx context.Context, userID string) snapshot {
+	u, err := d.repo.GetByID(ctx, AnyTenant, userID)
+	if err != nil {
+		return nil
+	}
+	return userSnapshot(u)
+}
+
+func (d *auditingDecorator) userGroups(ctx context.Context, userID string) snapshot {
+	u, err := d.repo.GetByID(ctx, AnyTenant, userID)
+	if err != nil {
+		return nil
+	}
+	groups, err := d.repo.QueryUserGroups(ctx, AnyTenant, userID)
+	if err != nil {
+		return nil
+	}
+
+	ids := make([]string, len(groups))
+	for i, g := range groups {
+		ids[i] = g.ID
+	}
+	return snapshot{"tenant_id": u.TenantID, "groups": ids}
+}
+
+func (d *auditingDecorator) role(ctx context.Context, name string) snapshot {
+	roles, err := d.repo.QueryRoles(ctx)
+	if err != nil {
+		return nil
+	}
+	for _, r := range roles {
+		if r.Name == name {
+			return snapshot{"permissions": []string(r.Permissions)}
+		}
+	}
+	return nil
+}
+
+func (d *auditingDecorator) organization(ctx context.Context, orgID string) snapshot {
+	o, err := d.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil
+	}
+	return snapshot{"tenant_id": o.ID, "name": o.Name}
+}
+
+func (d *auditingDecorator) client(ctx context.Context, clientID string) snapshot {
+	c, err := d.repo.GetClient(ctx, clientID)
+	if err != nil {
+		return nil
+	}
+	return snapshot{"name": c.Name, "redirect_uris": []string(c.RedirectURIs), "scopes": []string(c.Scopes)}
+}
+
+func (d *auditingDecorator) serviceAccount(ctx context.Context, accountID string) snapshot {
+	sa, err := d.repo.GetServiceAccount(ctx, AnyTenant, accountID)
+	if err != nil {
+		return nil
+	}
+	return snapshot{"tenant_id": sa.TenantID, "name": sa.Name, "permissions": []string(sa.Permissions)}
+}
+
+func (d *auditingDecorator) group(ctx context.Context, groupID string) snapshot {
+	g, err := d.repo.GetGroup(ctx, AnyTenant, groupID)
+	if err != nil {
+		return nil
+	}
+	return snapshot{"tenant_id": g.TenantID, "name": g.Name}
+}
+
+func (d *auditingDecorator) Create(ctx context.Context, traceID string, nur NewUserRequest, now time.Time) (User, error) {
+	u, err := d.Service.Create(ctx, traceID, nur, now)
+
+	var after snapshot
+	if err == nil {
+		after = userSnapshot(u)
+	}
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditUserCreate, u.ID, now)
+	return u, d.record(ctx, e, nil, after, err)
+}
+
+func (d *auditingDecorator) Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, version int, now time.Time) (int, error) {
+	before := d.user(ctx, userID)
+	updated, err := d.Service.Update(ctx, traceID, claims, userID, uur, version, now)
+	after := d.user(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditUserUpdate, userID, now)
+	return updated, d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) Patch(ctx context.Context, traceID string, claims auth.Claims, userID string, pur PatchUserRequest, version int, now time.Time) (int, error) {
+	before := d.user(ctx, userID)
+	patched, err := d.Service.Patch(ctx, traceID, claims, userID, pur, version, now)
+	after := d.user(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditUserPatch, userID, now)
+	return patched, d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) ChangePassword(ctx context.Context, traceID string, claims auth.Claims, userID string, cpr ChangePasswordRequest, now time.Time) error {
+	before := d.user(ctx, userID)
+	err := d.Service.ChangePassword(ctx, traceID, claims, userID, cpr, now)
+	after := d.user(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditPasswordChange, userID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) ForgotPassword(ctx context.Context, traceID string, email string, now time.Time) error {
+	err := d.Service.ForgotPassword(ctx, traceID, email, now)
+
+	// The User isn't looked up, which would tell whether the email exists by
+	// how long it takes to answer.
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditPasswordForgot, "", now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) ResetPassword(ctx context.Context, traceID string, rpr ResetPasswordRequest, now time.Time) error {
+	err := d.Service.ResetPassword(ctx, traceID, rpr, now)
+
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditPasswordReset, "", now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) VerifyEmail(ctx context.Context, traceID string, token string, now time.Time) error {
+	err := d.Service.VerifyEmail(ctx, traceID, token, now)
+
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditEmailVerify, "", now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) ResendVerification(ctx context.Context, traceID string, email string, now time.Time) error {
+	err := d.Service.ResendVerification(ctx, traceID, email, now)
+
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditEmailResend, "", now)
+	if u, lerr := d.repo.GetByEmail(ctx, email); lerr == nil {
+		e.TargetID = u.ID
+		e.TenantID = &u.TenantID
+	}
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) RequestEmailChange(ctx context.Context, traceID string, claims auth.Claims, userID string, cer ChangeEmailRequest, now time.Time) error {
+	err := d.Service.RequestEmailChange(ctx, traceID, claims, userID, cer, now)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditEmailChange, userID, now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) ConfirmEmailChange(ctx context.Context, traceID string, token string, now time.Time) error {
+	err := d.Service.ConfirmEmailChange(ctx, traceID, token, now)
+
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditEmailConfirm, "", now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) UpdateRoles(ctx context.Context, traceID string, claims auth.Claims, userID string, urr UpdateRolesRequest, now time.Time) error {
+	before := d.user(ctx, userID)
+	err := d.Service.UpdateRoles(ctx, traceID, claims, userID, urr, now)
+	after := d.user(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditRoleChange, userID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) QueryRoleChanges(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]RoleChange, error) {
+	return d.Service.QueryRoleChanges(ctx, traceID, claims, userID)
+}
+
+func (d *auditingDecorator) Impersonate(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) (auth.Claims, error) {
+	ic, err := d.Service.Impersonate(ctx, traceID, claims, userID, now)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditUserImpersonate, userID, now)
+	return ic, d.record(ctx, e, nil, nil, err)
+}
+
+// IssueRefreshToken isn't recorded since refresh tokens are only issued
+// along with logins, which are.
+func (d *auditingDecorator) IssueRefreshToken(ctx context.Context, traceID string, claims auth.Claims, now time.Time) (string, error) {
+	return d.Service.IssueRefreshToken(ctx, traceID, claims, now)
+}
+
+// Refresh records refreshes as performed by the User the token belongs to,
+// which is unknown when the token is rejected.
+func (d *auditingDecorator) Refresh(ctx context.Context, traceID string, token string, now time.Time) (auth.Claims, string, error) {
+	claims, next, err := d.Service.Refresh(ctx, traceID, token, now)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditTokenRefresh, claims.Subject, now)
+	return claims, next, d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) Logout(ctx context.Context, traceID string, claims auth.Claims, lr LogoutRequest, now time.Time) error {
+	err := d.Service.Logout(ctx, traceID, claims, lr, now)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditLogout, claims.Subject, now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) QueryClients(ctx context.Context, traceID string, claims auth.Claims) ([]Client, error) {
+	return d.Service.QueryClients(ctx, traceID, claims)
+}
+
+func (d *auditingDecorator) RegisterClient(ctx context.Context, traceID string, claims auth.Claims, ncr NewClientRequest, now time.Time) (Client, error) {
+	c, err := d.Service.RegisterClient(ctx, traceID, claims, ncr, now)
+
+	var after snapshot
+	if err == nil {
+		after = d.client(ctx, c.ID)
+	}
+
+	// Clients are shared by every tenant.
+	e := newAuditEvent(ctx, traceID, claims, AuditClientCreate, c.ID, now)
+	e.TenantID = nil
+	return c, d.record(ctx, e, nil, after, err)
+}
+
+func (d *auditingDecorator) DeleteClient(ctx context.Context, traceID string, claims auth.Claims, clientID string) error {
+	before := d.client(ctx, clientID)
+	err := d.Service.DeleteClient(ctx, traceID, claims, clientID)
+	after := d.client(ctx, clientID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditClientDelete, clientID, time.Now())
+	e.TenantID = nil
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) CheckAuthorization(ctx context.Context, traceID string, ar AuthorizationRequest) (Authorization, error) {
+	return d.Service.CheckAuthorization(ctx, traceID, ar)
+}
+
+// Authorize records authorizations as logins to the Client, performed by the
+// User once they authenticate. The tenant of the User is recorded even when
+// they fail to.
+func (d *auditingDecorator) Authorize(ctx context.Context, traceID string, ar AuthorizationRequest, lr LoginRequest, consent bool, now time.Time) (string, error) {
+	code, err := d.Service.Authorize(ctx, traceID, ar, lr, consent, now)
+
+	e := newAuditEvent(ctx, traceID, auth.Claims{}, AuditClientAuthorize, ar.ClientID, now)
+	if u, lerr := d.repo.GetByEmail(ctx, lr.Email); lerr == nil {
+		if err == nil {
+			e.ActorID = u.ID
+		}
+		e.TenantID = &u.TenantID
+	}
+	return code, d.record(ctx, e, nil, nil, err)
+}
+
+// ExchangeCode records failed exchanges too, since replayed codes and
+// mismatched verifiers are attempts to steal them.
+func (d *auditingDecorator) ExchangeCode(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error) {
+	g, err := d.Service.ExchangeCode(ctx, traceID, tr, now)
+
+	e := newAuditEvent(ctx, traceID, g.Claims, AuditCodeExchange, g.Claims.Subject, now)
+	return g, d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) ExchangeRefreshToken(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error) {
+	g, err := d.Service.ExchangeRefreshToken(ctx, traceID, tr, now)
+
+	e := newAuditEvent(ctx, traceID, g.Claims, AuditTokenRefresh, g.Claims.Subject, now)
+	return g, d.record(ctx, e, nil, nil, err)
+}
+
+// ExchangeClientCredentials records the tokens issued to service accounts
+// as actions of the service account, even when it fails to authenticate.
+func (d *auditingDecorator) ExchangeClientCredentials(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error) {
+	g, err := d.Service.ExchangeClientCredentials(ctx, traceID, tr, now)
+
+	e := newAuditEvent(ctx, traceID, g.Claims, AuditServiceAccountToken, tr.ClientID, now)
+	if sa, lerr := d.repo.GetServiceAccount(ctx, AnyTenant, tr.ClientID); lerr == nil {
+		e.TenantID = &sa.TenantID
+	}
+	return g, d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) QueryServiceAccounts(ctx context.Context, traceID string, claims auth.Claims) ([]ServiceAccount, error) {
+	return d.Service.QueryServiceAccounts(ctx, traceID, claims)
+}
+
+func (d *auditingDecorator) CreateServiceAccount(ctx context.Context, traceID string, claims auth.Claims, nsr NewServiceAccountRequest, now time.Time) (ServiceAccount, string, error) {
+	sa, secret, err := d.Service.CreateServiceAccount(ctx, traceID, claims, nsr, now)
+
+	var after snapshot
+	if err == nil {
+		after = d.serviceAccount(ctx, sa.ID)
+	}
+	e := newAuditEvent(ctx, traceID, claims, AuditServiceAccountCreate, sa.ID, now)
+	return sa, secret, d.record(ctx, e, nil, after, err)
+}
+
+func (d *auditingDecorator) RotateServiceAccountSecret(ctx context.Context, traceID string, claims auth.Claims, accountID string, now time.Time) (string, error) {
+	secret, err := d.Service.RotateServiceAccountSecret(ctx, traceID, claims, accountID, now)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditServiceAccountRotate, accountID, now)
+	return secret, d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) DeleteServiceAccount(ctx context.Context, traceID string, claims auth.Claims, accountID string) error {
+	before := d.serviceAccount(ctx, accountID)
+	err := d.Service.DeleteServiceAccount(ctx, traceID, claims, accountID)
+	after := d.serviceAccount(ctx, accountID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditServiceAccountDelete, accountID, time.Now())
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) RevokeTokens(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error {
+	err := d.Service.RevokeTokens(ctx, traceID, claims, userID, now)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditTokensRevoke, userID, now)
+	return d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) QueryRoles(ctx context.Context, traceID string, claims auth.Claims) ([]Role, error) {
+	return d.Service.QueryRoles(ctx, traceID, claims)
+}
+
+func (d *auditingDecorator) CreateRole(ctx context.Context, traceID string, claims auth.Claims, nr NewRoleRequest, now time.Time) (Role, error) {
+	before := d.role(ctx, nr.Name)
+	r, err := d.Service.CreateRole(ctx, traceID, claims, nr, now)
+	after := d.role(ctx, nr.Name)
+
+	// Roles are shared by every tenant.
+	e := newAuditEvent(ctx, traceID, claims, AuditRoleCreate, nr.Name, now)
+	e.TenantID = nil
+	return r, d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) UpdateRole(ctx context.Context, traceID string, claims auth.Claims, name string, urr UpdateRoleRequest) error {
+	before := d.role(ctx, name)
+	err := d.Service.UpdateRole(ctx, traceID, claims, name, urr)
+	after := d.role(ctx, name)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditRoleUpdate, name, time.Now())
+	e.TenantID = nil
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) DeleteRole(ctx context.Context, traceID string, claims auth.Claims, name string) error {
+	before := d.role(ctx, name)
+	err := d.Service.DeleteRole(ctx, traceID, claims, name)
+	after := d.role(ctx, name)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditRoleDelete, name, time.Now())
+	e.TenantID = nil
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) CreateInOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, nur NewUserRequest, now time.Time) (User, error) {
+	u, err := d.Service.CreateInOrganization(ctx, traceID, claims, orgID, nur, now)
+
+	var after snapshot
+	if err == nil {
+		after = userSnapshot(u)
+	}
+	e := newAuditEvent(ctx, traceID, claims, AuditUserCreate, u.ID, now)
+	return u, d.record(ctx, e, nil, after, err)
+}
+
+func (d *auditingDecorator) QueryOrganizations(ctx context.Context, traceID string, claims auth.Claims) ([]Organization, error) {
+	return d.Service.QueryOrganizations(ctx, traceID, claims)
+}
+
+func (d *auditingDecorator) GetOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) (Organization, error) {
+	return d.Service.GetOrganization(ctx, traceID, claims, orgID)
+}
+
+func (d *auditingDecorator) CreateOrganization(ctx context.Context, traceID string, claims auth.Claims, nor NewOrganizationRequest, now time.Time) (Organization, error) {
+	o, err := d.Service.CreateOrganization(ctx, traceID, claims, nor, now)
+
+	var after snapshot
+	if err == nil {
+		after = d.organization(ctx, o.ID)
+	}
+	e := newAuditEvent(ctx, traceID, claims, AuditOrganizationCreate, o.ID, now)
+	return o, d.record(ctx, e, nil, after, err)
+}
+
+func (d *auditingDecorator) UpdateOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, uor UpdateOrganizationRequest, now time.Time) error {
+	before := d.organization(ctx, orgID)
+	err := d.Service.UpdateOrganization(ctx, traceID, claims, orgID, uor, now)
+	after := d.organization(ctx, orgID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditOrganizationUpdate, orgID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) DeleteOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) error {
+	before := d.organization(ctx, orgID)
+	err := d.Service.DeleteOrganization(ctx, traceID, claims, orgID)
+	after := d.organization(ctx, orgID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditOrganizationDelete, orgID, time.Now())
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) QueryGroups(ctx context.Context, traceID string, claims auth.Claims) ([]Group, error) {
+	return d.Service.QueryGroups(ctx, traceID, claims)
+}
+
+func (d *auditingDecorator) CreateGroup(ctx context.Context, traceID string, claims auth.Claims, ngr NewGroupRequest, now time.Time) (Group, error) {
+	g, err := d.Service.CreateGroup(ctx, traceID, claims, ngr, now)
+
+	var after snapshot
+	if err == nil {
+		after = d.group(ctx, g.ID)
+	}
+	e := newAuditEvent(ctx, traceID, claims, AuditGroupCreate, g.ID, now)
+	return g, d.record(ctx, e, nil, after, err)
+}
+
+func (d *auditingDecorator) UpdateGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string, ugr UpdateGroupRequest, now time.Time) error {
+	before := d.group(ctx, groupID)
+	err := d.Service.UpdateGroup(ctx, traceID, claims, groupID, ugr, now)
+	after := d.group(ctx, groupID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditGroupUpdate, groupID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) DeleteGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string) error {
+	before := d.group(ctx, groupID)
+	err := d.Service.DeleteGroup(ctx, traceID, claims, groupID)
+	after := d.group(ctx, groupID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditGroupDelete, groupID, time.Now())
+	return d.record(ctx, e, before, after, err)
+}
+
+// Memberships are recorded as changes to the groups of the User.
+func (d *auditingDecorator) AddGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string, now time.Time) error {
+	before := d.userGroups(ctx, userID)
+	err := d.Service.AddGroupMember(ctx, traceID, claims, groupID, userID, now)
+	after := d.userGroups(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditGroupMemberAdd, userID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) RemoveGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string) error {
+	before := d.userGroups(ctx, userID)
+	err := d.Service.RemoveGroupMember(ctx, traceID, claims, groupID, userID)
+	after := d.userGroups(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditGroupMemberRemove, userID, time.Now())
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) QueryGroupMembers(ctx context.Context, traceID string, claims auth.Claims, groupID string, page, rowsPerPage int) (QueryResult, error) {
+	return d.Service.QueryGroupMembers(ctx, traceID, claims, groupID, page, rowsPerPage)
+}
+
+func (d *auditingDecorator) QueryUserGroups(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]Group, error) {
+	return d.Service.QueryUserGroups(ctx, traceID, claims, userID)
+}
+
+func (d *auditingDecorator) QueryAuditEvents(ctx context.Context, traceID string, claims auth.Claims, filter AuditFilter, page, rowsPerPage int) (AuditResult, error) {
+	return d.Service.QueryAuditEvents(ctx, traceID, claims, filter, page, rowsPerPage)
+}
+
+func (d *auditingDecorator) VerifyAuditEvents(ctx context.Context, traceID string, claims auth.Claims) (AuditVerification, error) {
+	return d.Service.VerifyAuditEvents(ctx, traceID, claims)
+}
+
+func (d *auditingDecorator) Delete(ctx context.Context, traceID string, claims auth.Claims, userID string, version int, now time.Time) error {
+	before := d.user(ctx, userID)
+	err := d.Service.Delete(ctx, traceID, claims, userID, version, now)
+	after := d.user(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditUserDelete, userID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) Restore(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error {
+	before := d.user(ctx, userID)
+	err := d.Service.Restore(ctx, traceID, claims, userID, now)
+	after := d.user(ctx, userID)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditUserRestore, userID, now)
+	return d.record(ctx, e, before, after, err)
+}
+
+func (d *auditingDecorator) GetByID(ctx context.Context, traceID string, claims auth.Claims, userID string) (User, error) {
+	return d.Service.GetByID(ctx, traceID, claims, userID)
+}
+
+func (d *auditingDecorator) Query(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, orderBy OrderBy, page, rowsPerPage int) (QueryResult, error) {
+	return d.Service.Query(ctx, traceID, claims, filter, orderBy, page, rowsPerPage)
+}
+
+func (d *auditingDecorator) QueryAfter(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, after Cursor, rowsPerPage int) (QueryResult, error) {
+	return d.Service.QueryAfter(ctx, traceID, claims, filter, after, rowsPerPage)
+}
+
+func (d *auditingDecorator) Search(ctx context.Context, traceID string, claims auth.Claims, terms string, limit int) ([]User, error) {
+	return d.Service.Search(ctx, traceID, claims, terms, limit)
+}
+
+// Authenticate records logins. The User failed logins are attempted for is
+// recorded as the target, when it exists.
+func (d *auditingDecorator) Authenticate(ctx context.Context, traceID string, now time.Time, email, password string) (auth.Claims, error) {
+	claims, err := d.Service.Authenticate(ctx, traceID, now, email, password)
+
+	e := newAuditEvent(ctx, traceID, claims, AuditUserLogin, claims.Subject, now)
+	if err != nil {
+		if u, lerr := d.repo.GetByEmail(ctx, email); lerr == nil {
+			e.TargetID = u.ID
+			e.TenantID = &u.TenantID
+		}
+	}
+	return claims, d.record(ctx, e, nil, nil, err)
+}
+
+func (d *auditingDecorator) ValidateClaims(ctx context.Context, claims auth.Claims) error {
+	return d.Service.ValidateClaims(ctx, claims)
+}
+
+func (d *auditingDecorator) Permissions(ctx context.Context, claims auth.Claims) (auth.Permissions, error) {
+	return d.Service.Permissions(ctx, claims)
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// resourceGroup is the type of the Groups authorization requests are made for.
+const resourceGroup = "group"
+
+// QueryGroups retrieves the Groups of the tenant of the claims.
+func (us userService) QueryGroups(ctx context.Context, traceID string, claims auth.Claims) ([]Group, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryGroups")
+	defer span.End()
+
+	resource := auth.Attributes{auth.AttrType: resourceGroup, auth.AttrTenantID: claims.TenantID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermUsersRead, resource); err != nil {
+		return nil, err
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := us.repo.QueryGroups(ctx, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying groups")
+	}
+
+	return groups, nil
+}
+
+// CreateGroup creates a new Group in the Organization of the claims.
+func (us userService) CreateGroup(ctx context.Context, traceID string, claims auth.Claims, ngr NewGroupRequest, now time.Time) (Group, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.createGroup")
+	defer span.End()
+
+	resource := auth.Attributes{auth.AttrType: resourceGroup, auth.AttrTenantID: claims.TenantID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermGroupsManage, resource); err != nil {
+		return Group{}, err
+	}
+
+	g := Group{
+		ID:          uuid.New().String(),
+		TenantID:    claims.TenantID,
+		Name:        ngr.Name,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+	if err := us.repo.CreateGroup(ctx, g); err != nil {
+		switch err {
+		case ErrDuplicatedGroup:
+			return Group{}, err
+		default:
+			return Group{}, errors.Wrapf(err, "creating group %s", g.Name)
+		}
+	}
+
+	return g, nil
+}
+
+// UpdateGroup renames a Group.
+func (us userService) UpdateGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string, ugr UpdateGroupRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.updateGroup")
+	defer span.End()
+
+	g, err := us.authorizeGroup(ctx, traceID, claims, auth.PermGroupsManage, groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := us.repo.UpdateGroup(ctx, g.TenantID, g.ID, ugr.Name, now); err != nil {
+		switch err {
+		case ErrNotFound, ErrDuplicatedGroup:
+			return err
+		default:
+			return errors.Wrapf(err, "updating group %s", groupID)
+		}
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes a Group. Its members are removed from it.
+func (us userService) DeleteGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.deleteGroup")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	g, err := us.authorizeGroup(ctx, traceID, claims, auth.PermGroupsManage, groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := us.repo.DeleteGroup(ctx, g.TenantID, g.ID); err != nil {
+		switch err {
+		case ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "deleting group %s", groupID)
+		}
+	}
+
+	return nil
+}
+
+// AddGroupMember adds a User to a Group. Only Users of the Organization of
+// the Group can be added to it. Adding a member twice has no effect.
+func (us userService) AddGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.addGroupMember")
+	defer span.End()
+
+	g, err := us.authorizeGroup(ctx, traceID, claims, auth.PermGroupsManage, groupID)
+	if err != nil {
+		return err
+	}
+
+	u, err := us.repo.GetByID(ctx, g.TenantID, userID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "searching for user %q", userID)
+		}
+	}
+
+	if err := us.repo.AddGroupMember(ctx, g.TenantID, g.ID, u.ID, now); err != nil {
+		return errors.Wrapf(err, "adding user %s to group %s", userID, groupID)
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes a User from a Group.
+func (us userService) RemoveGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.removeGroupMember")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	g, err := us.authorizeGroup(ctx, traceID, claims, auth.PermGroupsManage, groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := us.repo.RemoveGroupMember(ctx, g.TenantID, g.ID, userID); err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "removing user %s from group %s", userID, groupID)
+		}
+	}
+
+	return nil
+}
+
+// QueryGroupMembers retrieves a page of the members of a Group, in the order
+// they joined it.
+func (us userService) QueryGroupMembers(ctx context.Context, traceID string, claims auth.Claims, groupID string, page, rowsPerPage int) (QueryResult, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryGroupMembers")
+	defer span.End()
+
+	g, err := us.authorizeGroup(ctx, traceID, claims, auth.PermUsersRead, groupID)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	if page < 1 || rowsPerPage < 1 || rowsPerPage > MaxRowsPerPage {
+		return QueryResult{}, ErrInvalidPagination
+	}
+
+	users, total, err := us.repo.QueryGroupMembers(ctx, g.TenantID, g.ID, page, rowsPerPage)
+	if err != nil {
+		return QueryResult{}, errors.Wrapf(err, "querying members of group %s", groupID)
+	}
+
+	qr := QueryResult{
+		Items:       users,
+		Total:       total,
+		Page:        page,
+		RowsPerPage: rowsPerPage,
+	}
+
+	return qr, nil
+}
+
+// QueryUserGroups retrieves the Groups a User is a member of. Users can
+// retrieve their own Groups.
+func (us userService) QueryUserGroups(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]Group, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryUserGroups")
+	defer span.End()
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersRead, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := us.repo.QueryUserGroups(ctx, u.TenantID, u.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying groups of user %s", userID)
+	}
+
+	return groups, nil
+}
+
+// groupIDs returns the IDs of the Groups a User is a member of, to be
+// embedded in their token.
+func (us userService) groupIDs(ctx context.Context, u User) ([]string, error) {
+	groups, err := us.repo.QueryUserGroups(ctx, u.TenantID, u.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying groups of user %s", u.ID)
+	}
+
+	ids := make([]string, len(groups))
+	for i, g := range groups {
+		ids[i] = g.ID
+	}
+	return ids, nil
+}
+
+// authorizeGroup retrieves a Group of the tenant of the claims and checks
+// that the policy allows the action over it. Like with Users, missing Groups
+// are only reported to the ones allowed to perform the action.
+func (us userService) authorizeGroup(ctx context.Context, traceID string, claims auth.Claims, action, groupID string) (Group, error) {
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return Group{}, err
+	}
+
+	g, err := us.repo.GetGroup(ctx, tenant, groupID)
+	switch err {
+	case nil:
+		resource := auth.Attributes{auth.AttrType: resourceGroup, auth.AttrID: g.ID, auth.AttrTenantID: g.TenantID}
+		if err := us.authorize(ctx, traceID, claims, action, resource); err != nil {
+			return Group{}, err
+		}
+		return g, nil
+	case ErrInvalidID, ErrNotFound:
+		resource := auth.Attributes{auth.AttrType: resourceGroup, auth.AttrID: groupID, auth.AttrTenantID: claims.TenantID}
+		if err := us.authorize(ctx, traceID, claims, action, resource); err != nil {
+			return Group{}, err
+		}
+		return Group{}, err
+	default:
+		return Group{}, errors.Wrapf(err, "searching for group %q", groupID)
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Impersonate returns the claims of a short-lived session in which the User
+// of the claims acts as another one. The claims keep track of the actor so
+// the session can be told apart from the ones of the impersonated User. Only
+// Users that hold every permission of the impersonated one can impersonate
+// them, and impersonated sessions can't impersonate anyone else.
+func (us userService) Impersonate(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) (auth.Claims, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.impersonate")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return auth.Claims{}, ErrImpersonating
+	}
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersImpersonate, userID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	if u.ID == claims.Subject {
+		return auth.Claims{}, ErrForbidden
+	}
+
+	perms, err := us.perms.Granted(ctx, claims)
+	if err != nil {
+		return auth.Claims{}, errors.Wrap(err, "resolving permissions")
+	}
+	target, err := us.perms.Permissions(ctx, u.Roles)
+	if err != nil {
+		return auth.Claims{}, errors.Wrap(err, "resolving permissions")
+	}
+	if !perms.Includes(target) {
+		return auth.Claims{}, ErrForbidden
+	}
+
+	ic, err := us.newClaims(ctx, u, now, us.cfg.ImpersonationTTL)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	ic.Actor = &auth.Actor{
+		Subject:  claims.Subject,
+		TenantID: claims.TenantID,
+	}
+
+	return ic, nil
+}
+
+// validateActor checks that the actor of an impersonated session still
+// exists, hasn't changed their password since the session started and is
+// still allowed to impersonate Users.
+func (us userService) validateActor(ctx context.Context, claims auth.Claims) error {
+	actor, err := us.repo.GetByID(ctx, claims.Actor.TenantID, claims.Actor.Subject)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return auth.ErrTokenRevoked
+		default:
+			return errors.Wrapf(err, "searching for user %q", claims.Actor.Subject)
+		}
+	}
+
+	if actor.PasswordChangedAt != nil && claims.IssuedBefore(*actor.PasswordChangedAt) {
+		return auth.ErrTokenRevoked
+	}
+
+	perms, err := us.perms.Permissions(ctx, actor.Roles)
+	if err != nil {
+		return errors.Wrap(err, "resolving permissions")
+	}
+	if !perms.Has(auth.PermUsersImpersonate) {
+		return auth.ErrTokenRevoked
+	}
+
+	return nil
+}
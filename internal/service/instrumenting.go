@@ -44,22 +44,454 @@ func (d *instrumentingDecorator) Create(ctx context.Context, traceID string, nur
 	return d.Service.Create(ctx, traceID, nur, now)
 }
 
-func (d *instrumentingDecorator) Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, now time.Time) (err error) {
+func (d *instrumentingDecorator) Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, version int, now time.Time) (updated int, err error) {
 	defer func(begin time.Time) {
 		d.requestCount.With("method", "update").Add(1)
 		d.requestLatency.With("method", "update", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return d.Service.Update(ctx, traceID, claims, userID, uur, now)
+	return d.Service.Update(ctx, traceID, claims, userID, uur, version, now)
 }
 
-func (d *instrumentingDecorator) Delete(ctx context.Context, traceID string, claims auth.Claims, userID string) (err error) {
+func (d *instrumentingDecorator) Patch(ctx context.Context, traceID string, claims auth.Claims, userID string, pur PatchUserRequest, version int, now time.Time) (patched int, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "patch").Add(1)
+		d.requestLatency.With("method", "patch", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Patch(ctx, traceID, claims, userID, pur, version, now)
+}
+
+func (d *instrumentingDecorator) ChangePassword(ctx context.Context, traceID string, claims auth.Claims, userID string, cpr ChangePasswordRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "change_password").Add(1)
+		d.requestLatency.With("method", "change_password", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ChangePassword(ctx, traceID, claims, userID, cpr, now)
+}
+
+func (d *instrumentingDecorator) ForgotPassword(ctx context.Context, traceID string, email string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "forgot_password").Add(1)
+		d.requestLatency.With("method", "forgot_password", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ForgotPassword(ctx, traceID, email, now)
+}
+
+func (d *instrumentingDecorator) ResetPassword(ctx context.Context, traceID string, rpr ResetPasswordRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "reset_password").Add(1)
+		d.requestLatency.With("method", "reset_password", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ResetPassword(ctx, traceID, rpr, now)
+}
+
+func (d *instrumentingDecorator) VerifyEmail(ctx context.Context, traceID string, token string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "verify_email").Add(1)
+		d.requestLatency.With("method", "verify_email", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.VerifyEmail(ctx, traceID, token, now)
+}
+
+func (d *instrumentingDecorator) ResendVerification(ctx context.Context, traceID string, email string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "resend_verification").Add(1)
+		d.requestLatency.With("method", "resend_verification", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ResendVerification(ctx, traceID, email, now)
+}
+
+func (d *instrumentingDecorator) RequestEmailChange(ctx context.Context, traceID string, claims auth.Claims, userID string, cer ChangeEmailRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "request_email_change").Add(1)
+		d.requestLatency.With("method", "request_email_change", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.RequestEmailChange(ctx, traceID, claims, userID, cer, now)
+}
+
+func (d *instrumentingDecorator) ConfirmEmailChange(ctx context.Context, traceID string, token string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "confirm_email_change").Add(1)
+		d.requestLatency.With("method", "confirm_email_change", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ConfirmEmailChange(ctx, traceID, token, now)
+}
+
+func (d *instrumentingDecorator) UpdateRoles(ctx context.Context, traceID string, claims auth.Claims, userID string, urr UpdateRolesRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "update_roles").Add(1)
+		d.requestLatency.With("method", "update_roles", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.UpdateRoles(ctx, traceID, claims, userID, urr, now)
+}
+
+func (d *instrumentingDecorator) QueryRoleChanges(ctx context.Context, traceID string, claims auth.Claims, userID string) (changes []RoleChange, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_role_changes").Add(1)
+		d.requestLatency.With("method", "query_role_changes", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryRoleChanges(ctx, traceID, claims, userID)
+}
+
+func (d *instrumentingDecorator) QueryRoles(ctx context.Context, traceID string, claims auth.Claims) (roles []Role, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_roles").Add(1)
+		d.requestLatency.With("method", "query_roles", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryRoles(ctx, traceID, claims)
+}
+
+func (d *instrumentingDecorator) CreateRole(ctx context.Context, traceID string, claims auth.Claims, nr NewRoleRequest, now time.Time) (r Role, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "create_role").Add(1)
+		d.requestLatency.With("method", "create_role", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.CreateRole(ctx, traceID, claims, nr, now)
+}
+
+func (d *instrumentingDecorator) UpdateRole(ctx context.Context, traceID string, claims auth.Claims, name string, urr UpdateRoleRequest) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "update_role").Add(1)
+		d.requestLatency.With("method", "update_role", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.UpdateRole(ctx, traceID, claims, name, urr)
+}
+
+func (d *instrumentingDecorator) DeleteRole(ctx context.Context, traceID string, claims auth.Claims, name string) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "delete_role").Add(1)
+		d.requestLatency.With("method", "delete_role", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.DeleteRole(ctx, traceID, claims, name)
+}
+
+func (d *instrumentingDecorator) CreateInOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, nur NewUserRequest, now time.Time) (user User, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "create_in_organization").Add(1)
+		d.requestLatency.With("method", "create_in_organization", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.CreateInOrganization(ctx, traceID, claims, orgID, nur, now)
+}
+
+func (d *instrumentingDecorator) QueryOrganizations(ctx context.Context, traceID string, claims auth.Claims) (orgs []Organization, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_organizations").Add(1)
+		d.requestLatency.With("method", "query_organizations", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryOrganizations(ctx, traceID, claims)
+}
+
+func (d *instrumentingDecorator) GetOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) (org Organization, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "get_organization").Add(1)
+		d.requestLatency.With("method", "get_organization", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.GetOrganization(ctx, traceID, claims, orgID)
+}
+
+func (d *instrumentingDecorator) CreateOrganization(ctx context.Context, traceID string, claims auth.Claims, nor NewOrganizationRequest, now time.Time) (org Organization, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "create_organization").Add(1)
+		d.requestLatency.With("method", "create_organization", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.CreateOrganization(ctx, traceID, claims, nor, now)
+}
+
+func (d *instrumentingDecorator) UpdateOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, uor UpdateOrganizationRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "update_organization").Add(1)
+		d.requestLatency.With("method", "update_organization", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.UpdateOrganization(ctx, traceID, claims, orgID, uor, now)
+}
+
+func (d *instrumentingDecorator) DeleteOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "delete_organization").Add(1)
+		d.requestLatency.With("method", "delete_organization", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.DeleteOrganization(ctx, traceID, claims, orgID)
+}
+
+func (d *instrumentingDecorator) QueryGroups(ctx context.Context, traceID string, claims auth.Claims) (res []Group, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_groups").Add(1)
+		d.requestLatency.With("method", "query_groups", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryGroups(ctx, traceID, claims)
+}
+
+func (d *instrumentingDecorator) CreateGroup(ctx context.Context, traceID string, claims auth.Claims, ngr NewGroupRequest, now time.Time) (res Group, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "create_group").Add(1)
+		d.requestLatency.With("method", "create_group", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.CreateGroup(ctx, traceID, claims, ngr, now)
+}
+
+func (d *instrumentingDecorator) UpdateGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string, ugr UpdateGroupRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "update_group").Add(1)
+		d.requestLatency.With("method", "update_group", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.UpdateGroup(ctx, traceID, claims, groupID, ugr, now)
+}
+
+func (d *instrumentingDecorator) DeleteGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "delete_group").Add(1)
+		d.requestLatency.With("method", "delete_group", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.DeleteGroup(ctx, traceID, claims, groupID)
+}
+
+func (d *instrumentingDecorator) AddGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "add_group_member").Add(1)
+		d.requestLatency.With("method", "add_group_member", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.AddGroupMember(ctx, traceID, claims, groupID, userID, now)
+}
+
+func (d *instrumentingDecorator) RemoveGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "remove_group_member").Add(1)
+		d.requestLatency.With("method", "remove_group_member", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.RemoveGroupMember(ctx, traceID, claims, groupID, userID)
+}
+
+func (d *instrumentingDecorator) QueryGroupMembers(ctx context.Context, traceID string, claims auth.Claims, groupID string, page, rowsPerPage int) (res QueryResult, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_group_members").Add(1)
+		d.requestLatency.With("method", "query_group_members", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryGroupMembers(ctx, traceID, claims, groupID, page, rowsPerPage)
+}
+
+func (d *instrumentingDecorator) QueryUserGroups(ctx context.Context, traceID string, claims auth.Claims, userID string) (res []Group, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_user_groups").Add(1)
+		d.requestLatency.With("method", "query_user_groups", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryUserGroups(ctx, traceID, claims, userID)
+}
+
+func (d *instrumentingDecorator) Impersonate(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) (res auth.Claims, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "impersonate").Add(1)
+		d.requestLatency.With("method", "impersonate", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Impersonate(ctx, traceID, claims, userID, now)
+}
+
+func (d *instrumentingDecorator) IssueRefreshToken(ctx context.Context, traceID string, claims auth.Claims, now time.Time) (token string, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "issue_refresh_token").Add(1)
+		d.requestLatency.With("method", "issue_refresh_token", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.IssueRefreshToken(ctx, traceID, claims, now)
+}
+
+func (d *instrumentingDecorator) Refresh(ctx context.Context, traceID string, token string, now time.Time) (claims auth.Claims, next string, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "refresh").Add(1)
+		d.requestLatency.With("method", "refresh", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Refresh(ctx, traceID, token, now)
+}
+
+func (d *instrumentingDecorator) Logout(ctx context.Context, traceID string, claims auth.Claims, lr LogoutRequest, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "logout").Add(1)
+		d.requestLatency.With("method", "logout", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Logout(ctx, traceID, claims, lr, now)
+}
+
+func (d *instrumentingDecorator) QueryClients(ctx context.Context, traceID string, claims auth.Claims) (clients []Client, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_clients").Add(1)
+		d.requestLatency.With("method", "query_clients", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryClients(ctx, traceID, claims)
+}
+
+func (d *instrumentingDecorator) RegisterClient(ctx context.Context, traceID string, claims auth.Claims, ncr NewClientRequest, now time.Time) (c Client, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "register_client").Add(1)
+		d.requestLatency.With("method", "register_client", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.RegisterClient(ctx, traceID, claims, ncr, now)
+}
+
+func (d *instrumentingDecorator) DeleteClient(ctx context.Context, traceID string, claims auth.Claims, clientID string) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "delete_client").Add(1)
+		d.requestLatency.With("method", "delete_client", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.DeleteClient(ctx, traceID, claims, clientID)
+}
+
+func (d *instrumentingDecorator) CheckAuthorization(ctx context.Context, traceID string, ar AuthorizationRequest) (authz Authorization, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "check_authorization").Add(1)
+		d.requestLatency.With("method", "check_authorization", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.CheckAuthorization(ctx, traceID, ar)
+}
+
+func (d *instrumentingDecorator) Authorize(ctx context.Context, traceID string, ar AuthorizationRequest, lr LoginRequest, consent bool, now time.Time) (code string, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "authorize").Add(1)
+		d.requestLatency.With("method", "authorize", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Authorize(ctx, traceID, ar, lr, consent, now)
+}
+
+func (d *instrumentingDecorator) ExchangeCode(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (g Grant, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "exchange_code").Add(1)
+		d.requestLatency.With("method", "exchange_code", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ExchangeCode(ctx, traceID, tr, now)
+}
+
+func (d *instrumentingDecorator) ExchangeRefreshToken(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (g Grant, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "exchange_refresh_token").Add(1)
+		d.requestLatency.With("method", "exchange_refresh_token", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ExchangeRefreshToken(ctx, traceID, tr, now)
+}
+
+func (d *instrumentingDecorator) ExchangeClientCredentials(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (g Grant, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "exchange_client_credentials").Add(1)
+		d.requestLatency.With("method", "exchange_client_credentials", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ExchangeClientCredentials(ctx, traceID, tr, now)
+}
+
+func (d *instrumentingDecorator) QueryServiceAccounts(ctx context.Context, traceID string, claims auth.Claims) (accounts []ServiceAccount, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_service_accounts").Add(1)
+		d.requestLatency.With("method", "query_service_accounts", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryServiceAccounts(ctx, traceID, claims)
+}
+
+func (d *instrumentingDecorator) CreateServiceAccount(ctx context.Context, traceID string, claims auth.Claims, nsr NewServiceAccountRequest, now time.Time) (sa ServiceAccount, secret string, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "create_service_account").Add(1)
+		d.requestLatency.With("method", "create_service_account", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.CreateServiceAccount(ctx, traceID, claims, nsr, now)
+}
+
+func (d *instrumentingDecorator) RotateServiceAccountSecret(ctx context.Context, traceID string, claims auth.Claims, accountID string, now time.Time) (secret string, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "rotate_service_account_secret").Add(1)
+		d.requestLatency.With("method", "rotate_service_account_secret", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.RotateServiceAccountSecret(ctx, traceID, claims, accountID, now)
+}
+
+func (d *instrumentingDecorator) DeleteServiceAccount(ctx context.Context, traceID string, claims auth.Claims, accountID string) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "delete_service_account").Add(1)
+		d.requestLatency.With("method", "delete_service_account", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.DeleteServiceAccount(ctx, traceID, claims, accountID)
+}
+
+func (d *instrumentingDecorator) RevokeTokens(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "revoke_tokens").Add(1)
+		d.requestLatency.With("method", "revoke_tokens", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.RevokeTokens(ctx, traceID, claims, userID, now)
+}
+
+func (d *instrumentingDecorator) QueryAuditEvents(ctx context.Context, traceID string, claims auth.Claims, filter AuditFilter, page, rowsPerPage int) (res AuditResult, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_audit_events").Add(1)
+		d.requestLatency.With("method", "query_audit_events", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryAuditEvents(ctx, traceID, claims, filter, page, rowsPerPage)
+}
+
+func (d *instrumentingDecorator) VerifyAuditEvents(ctx context.Context, traceID string, claims auth.Claims) (res AuditVerification, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "verify_audit_events").Add(1)
+		d.requestLatency.With("method", "verify_audit_events", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.VerifyAuditEvents(ctx, traceID, claims)
+}
+
+func (d *instrumentingDecorator) Delete(ctx context.Context, traceID string, claims auth.Claims, userID string, version int, now time.Time) (err error) {
 	defer func(begin time.Time) {
 		d.requestCount.With("method", "delete").Add(1)
 		d.requestLatency.With("method", "delete", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return d.Service.Delete(ctx, traceID, claims, userID)
+	return d.Service.Delete(ctx, traceID, claims, userID, version, now)
+}
+
+func (d *instrumentingDecorator) Restore(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "restore").Add(1)
+		d.requestLatency.With("method", "restore", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Restore(ctx, traceID, claims, userID, now)
 }
 
 func (d *instrumentingDecorator) GetByID(ctx context.Context, traceID string, claims auth.Claims, userID string) (user User, err error) {
@@ -71,6 +503,33 @@ func (d *instrumentingDecorator) GetByID(ctx context.Context, traceID string, cl
 	return d.Service.GetByID(ctx, traceID, claims, userID)
 }
 
+func (d *instrumentingDecorator) Query(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, orderBy OrderBy, page, rowsPerPage int) (qr QueryResult, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query").Add(1)
+		d.requestLatency.With("method", "query", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Query(ctx, traceID, claims, filter, orderBy, page, rowsPerPage)
+}
+
+func (d *instrumentingDecorator) QueryAfter(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, after Cursor, rowsPerPage int) (qr QueryResult, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "query_after").Add(1)
+		d.requestLatency.With("method", "query_after", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.QueryAfter(ctx, traceID, claims, filter, after, rowsPerPage)
+}
+
+func (d *instrumentingDecorator) Search(ctx context.Context, traceID string, claims auth.Claims, terms string, limit int) (users []User, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "search").Add(1)
+		d.requestLatency.With("method", "search", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Search(ctx, traceID, claims, terms, limit)
+}
+
 func (d *instrumentingDecorator) Authenticate(ctx context.Context, traceID string, now time.Time, email, password string) (claims auth.Claims, err error) {
 	defer func(begin time.Time) {
 		d.requestCount.With("method", "authenticate").Add(1)
@@ -79,3 +538,21 @@ func (d *instrumentingDecorator) Authenticate(ctx context.Context, traceID strin
 
 	return d.Service.Authenticate(ctx, traceID, now, email, password)
 }
+
+func (d *instrumentingDecorator) ValidateClaims(ctx context.Context, claims auth.Claims) (err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "validate_claims").Add(1)
+		d.requestLatency.With("method", "validate_claims", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.ValidateClaims(ctx, claims)
+}
+
+func (d *instrumentingDecorator) Permissions(ctx context.Context, claims auth.Claims) (perms auth.Permissions, err error) {
+	defer func(begin time.Time) {
+		d.requestCount.With("method", "permissions").Add(1)
+		d.requestLatency.With("method", "permissions", "success", fmt.Sprint(err == nil)).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return d.Service.Permissions(ctx, claims)
+}
@@ -4,11 +4,13 @@ import (
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/santiagoh1997/service-template/internal/auth"
 )
 
 // User represents an individual user.
 type User struct {
 	ID           string         `db:"user_id" json:"id"`
+	TenantID     string         `db:"tenant_id" json:"tenant_id"`
 	Name         string         `db:"name" json:"name"`
 	LastName     string         `db:"last_name" json:"last_name"`
 	Email        string         `db:"email" json:"email"`
@@ -17,17 +19,22 @@ type User struct {
 	PasswordHash []byte         `db:"password_hash" json:"-"`
 	DateCreated  time.Time      `db:"date_created" json:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+	Version      int            `db:"version" json:"-"`
+	DeletedAt    *time.Time     `db:"deleted_at" json:"deleted_at,omitempty"`
+
+	EmailVerifiedAt   *time.Time `db:"email_verified_at" json:"email_verified_at,omitempty"`
+	PasswordChangedAt *time.Time `db:"password_changed_at" json:"-"`
 }
 
-// NewUserRequest contains all the needed data to create a User.
+// NewUserRequest contains all the needed data to create a User. New Users
+// always get the default role, other roles can only be granted by admins.
 type NewUserRequest struct {
-	Name            string   `json:"name" validate:"required"`
-	Email           string   `json:"email" validate:"required,email"`
-	LastName        string   `json:"last_name" validate:"required"`
-	Country         string   `json:"country" validate:"required"`
-	Roles           []string `json:"roles" validate:"required"`
-	Password        string   `json:"password" validate:"required"`
-	PasswordConfirm string   `json:"password_confirm" validate:"eqfield=Password"`
+	Name            string `json:"name" validate:"required"`
+	Email           string `json:"email" validate:"required,email"`
+	LastName        string `json:"last_name" validate:"required"`
+	Country         string `json:"country" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
 }
 
 // UpdateUserRequest contains the information needed to modify an existing User.
@@ -37,8 +44,305 @@ type UpdateUserRequest struct {
 	Country  string `json:"country" validate:"required"`
 }
 
+// PatchUserRequest contains the fields of an existing User that change in a
+// partial update. Nil fields are left untouched.
+type PatchUserRequest struct {
+	Name     *string
+	LastName *string
+	Country  *string
+}
+
+// NewPatchUserRequest returns a PatchUserRequest holding the fields of uur
+// that differ from the ones of u.
+func NewPatchUserRequest(u User, uur UpdateUserRequest) PatchUserRequest {
+	var pur PatchUserRequest
+	if uur.Name != u.Name {
+		pur.Name = &uur.Name
+	}
+	if uur.LastName != u.LastName {
+		pur.LastName = &uur.LastName
+	}
+	if uur.Country != u.Country {
+		pur.Country = &uur.Country
+	}
+	return pur
+}
+
+// Empty reports whether the PatchUserRequest doesn't change any field.
+func (pur PatchUserRequest) Empty() bool {
+	return pur.Name == nil && pur.LastName == nil && pur.Country == nil
+}
+
+// UpdateRolesRequest contains the complete set of roles a User should have.
+type UpdateRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1"`
+}
+
+// RoleChange records the roles granted to and revoked from a User by an admin.
+type RoleChange struct {
+	ID          string         `db:"change_id" json:"id"`
+	UserID      string         `db:"user_id" json:"user_id"`
+	ActorID     string         `db:"actor_id" json:"actor_id"`
+	Granted     pq.StringArray `db:"granted" json:"granted"`
+	Revoked     pq.StringArray `db:"revoked" json:"revoked"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+}
+
+// Role represents a named set of permissions that can be assigned to Users.
+type Role struct {
+	Name        string         `db:"name" json:"name"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+}
+
+// NewRoleRequest contains the information needed to define a Role.
+type NewRoleRequest struct {
+	Name        string   `json:"name" validate:"required,max=64"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// UpdateRoleRequest contains the complete set of permissions a Role should grant.
+type UpdateRoleRequest struct {
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// Organization is a tenant of the service. Every User belongs to one.
+type Organization struct {
+	ID          string    `db:"organization_id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewOrganizationRequest contains the information needed to create an Organization.
+type NewOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+// UpdateOrganizationRequest contains the fields of an Organization that can be changed.
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+// Group is a set of Users of the same Organization.
+type Group struct {
+	ID          string    `db:"group_id" json:"id"`
+	TenantID    string    `db:"tenant_id" json:"tenant_id"`
+	Name        string    `db:"name" json:"name"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewGroupRequest contains the information needed to create a Group.
+type NewGroupRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+// UpdateGroupRequest contains the fields of a Group that can be changed.
+type UpdateGroupRequest struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+// AddGroupMemberRequest contains the User to add to a Group.
+type AddGroupMemberRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+}
+
+// ChangePasswordRequest contains the information needed to change the
+// password of a User. The current password can only be omitted by admins
+// resetting the password of another User.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// ChangeEmailRequest contains the information needed to request a change of
+// the email of a User. The current password can only be omitted by admins
+// changing the email of another User.
+type ChangeEmailRequest struct {
+	Email           string `json:"email" validate:"required,email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// ConfirmEmailChangeRequest contains the token sent to the new email of a User.
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ForgotPasswordRequest contains the email of a User that wants to reset
+// their password.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest contains the information needed to reset a password
+// using a token obtained through a ForgotPasswordRequest.
+type ResetPasswordRequest struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// VerifyEmailRequest contains the token sent to a User to verify their email.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerificationRequest contains the email of a User that wants to
+// receive a new verification token.
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 // LoginRequest is used in order to authenticate a client.
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required"`
 	Password string `json:"password" validate:"required"`
 }
+
+// RefreshRequest contains a refresh token to exchange for a new access token
+// along with the refresh token to use next time. The access token is signed
+// with the key of KID, when given.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+	KID          string `json:"kid"`
+}
+
+// LogoutRequest optionally contains the refresh token issued along with the
+// token being revoked, so it's revoked as well.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is a stored refresh token. Every refresh token belongs to the
+// family of tokens issued since the User authenticated, which is revoked as a
+// whole if a token that was already exchanged is presented again. ClientID
+// and Scopes are only set on the tokens issued to OAuth clients.
+type RefreshToken struct {
+	TokenHash   string         `db:"token_hash"`
+	FamilyID    string         `db:"family_id"`
+	UserID      string         `db:"user_id"`
+	ClientID    *string        `db:"client_id"`
+	Scopes      pq.StringArray `db:"scopes"`
+	AuthTime    time.Time      `db:"auth_time"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+	UsedAt      *time.Time     `db:"used_at"`
+	RevokedAt   *time.Time     `db:"revoked_at"`
+	DateCreated time.Time      `db:"date_created"`
+}
+
+// Client is an application registered to obtain tokens on behalf of Users
+// through the OAuth 2.0 authorization code flow. Clients are public, so they
+// prove they started a flow with PKCE instead of a secret.
+type Client struct {
+	ID           string         `db:"client_id" json:"id"`
+	Name         string         `db:"name" json:"name"`
+	RedirectURIs pq.StringArray `db:"redirect_uris" json:"redirect_uris"`
+	Scopes       pq.StringArray `db:"scopes" json:"scopes"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// NewClientRequest contains the information needed to register a Client.
+// Scopes are the ones the Client can request.
+type NewClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=128"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+}
+
+// AuthorizationRequest contains the parameters of an OAuth 2.0 authorization
+// request (RFC 6749 section 4.1.1) along with its PKCE code challenge (RFC
+// 7636). Scope holds space separated scopes and Nonce is echoed in the ID
+// token of OpenID Connect requests.
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// Authorization describes a valid AuthorizationRequest so Users can be asked
+// to consent to it. RedirectURI is the one the response is sent to, which is
+// the only one registered for the Client when the request doesn't have one.
+type Authorization struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+// TokenRequest contains the parameters of an OAuth 2.0 token request. Only
+// the ones of its grant type are set. ClientSecret and Scope are only used
+// by service accounts, Scope holding space separated permissions.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// Consent records the scopes a User granted to a Client.
+type Consent struct {
+	UserID      string         `db:"user_id"`
+	ClientID    string         `db:"client_id"`
+	Scopes      pq.StringArray `db:"scopes"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+// AuthorizationCode is a stored authorization code. RedirectURI is the one of
+// the request, which may be empty, and FamilyID the family of the refresh
+// tokens issued in exchange for the code, once it's used.
+type AuthorizationCode struct {
+	CodeHash      string         `db:"code_hash"`
+	ClientID      string         `db:"client_id"`
+	UserID        string         `db:"user_id"`
+	RedirectURI   string         `db:"redirect_uri"`
+	Scopes        pq.StringArray `db:"scopes"`
+	CodeChallenge string         `db:"code_challenge"`
+	Nonce         string         `db:"nonce"`
+	FamilyID      *string        `db:"family_id"`
+	AuthTime      time.Time      `db:"auth_time"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+	UsedAt        *time.Time     `db:"used_at"`
+	DateCreated   time.Time      `db:"date_created"`
+}
+
+// Grant holds what the token endpoint issues to a Client: the claims of its
+// access token, a refresh token and, when the Client was granted the openid
+// scope, the claims of an ID token.
+type Grant struct {
+	Claims       auth.Claims
+	RefreshToken string
+	IDClaims     *auth.IDClaims
+}
+
+// ServiceAccount is a non-human principal of an Organization, such as a
+// backend job. It authenticates with its ID and a secret through the client
+// credentials grant, and holds permissions directly instead of roles.
+type ServiceAccount struct {
+	ID          string         `db:"service_account_id" json:"id"`
+	TenantID    string         `db:"tenant_id" json:"tenant_id"`
+	Name        string         `db:"name" json:"name"`
+	SecretHash  string         `db:"secret_hash" json:"-"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// NewServiceAccountRequest contains the information needed to create a
+// ServiceAccount.
+type NewServiceAccountRequest struct {
+	Name        string   `json:"name" validate:"required,max=128"`
+	Permissions []string `json:"permissions" validate:"required,min=1"`
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// resourceClient is the type of the OAuth clients authorization requests
+// are made for.
+const resourceClient = "client"
+
+// codeChallengeS256 is the only PKCE code challenge method supported, since
+// plain challenges don't protect codes leaked along with their requests.
+const codeChallengeS256 = "S256"
+
+// QueryClients retrieves every OAuth Client.
+func (us userService) QueryClients(ctx context.Context, traceID string, claims auth.Claims) ([]Client, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryClients")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermClientsManage, auth.Attributes{auth.AttrType: resourceClient}); err != nil {
+		return nil, err
+	}
+
+	clients, err := us.repo.QueryClients(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying clients")
+	}
+
+	return clients, nil
+}
+
+// RegisterClient registers an OAuth Client. Redirect URIs must be absolute
+// and use https, unless they point to a loopback address or use the private
+// scheme of a native app (RFC 8252).
+func (us userService) RegisterClient(ctx context.Context, traceID string, claims auth.Claims, ncr NewClientRequest, now time.Time) (Client, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.registerClient")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermClientsManage, auth.Attributes{auth.AttrType: resourceClient}); err != nil {
+		return Client{}, err
+	}
+
+	for _, uri := range ncr.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return Client{}, ErrInvalidRedirectURI
+		}
+	}
+	scopes := []string{}
+	for _, scope := range ncr.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \"\\") {
+			return Client{}, ErrInvalidScope
+		}
+		scopes = appendMissing(scopes, scope)
+	}
+
+	c := Client{
+		ID:           uuid.New().String(),
+		Name:         ncr.Name,
+		RedirectURIs: ncr.RedirectURIs,
+		Scopes:       scopes,
+		DateCreated:  now.UTC(),
+		DateUpdated:  now.UTC(),
+	}
+	if err := us.repo.CreateClient(ctx, c); err != nil {
+		return Client{}, errors.Wrapf(err, "creating client %s", c.Name)
+	}
+
+	return c, nil
+}
+
+// DeleteClient deletes an OAuth Client along with the refresh tokens issued
+// to it.
+func (us userService) DeleteClient(ctx context.Context, traceID string, claims auth.Claims, clientID string) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.deleteClient")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	resource := auth.Attributes{auth.AttrType: resourceClient, auth.AttrID: clientID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermClientsManage, resource); err != nil {
+		return err
+	}
+
+	if err := us.repo.DeleteClient(ctx, clientID); err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "deleting client %s", clientID)
+		}
+	}
+
+	return nil
+}
+
+// CheckAuthorization validates an authorization request. Requests without
+// scopes ask for every scope of the Client. Errors other than
+// ErrInvalidClient and ErrInvalidRedirectURI are meant to be sent to the
+// Client, so the returned Authorization holds the redirect URI to send them
+// to along with them.
+func (us userService) CheckAuthorization(ctx context.Context, traceID string, ar AuthorizationRequest) (Authorization, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.checkAuthorization")
+	defer span.End()
+
+	c, err := us.repo.GetClient(ctx, ar.ClientID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return Authorization{}, ErrInvalidClient
+		default:
+			return Authorization{}, errors.Wrapf(err, "searching for client %q", ar.ClientID)
+		}
+	}
+
+	authz := Authorization{
+		ClientID:    c.ID,
+		ClientName:  c.Name,
+		RedirectURI: ar.RedirectURI,
+		Scopes:      []string{},
+	}
+
+	// The redirect URI must match a registered one exactly, and can only be
+	// omitted when there's no doubt about which one to use.
+	switch {
+	case ar.RedirectURI == "" && len(c.RedirectURIs) == 1:
+		authz.RedirectURI = c.RedirectURIs[0]
+	case ar.RedirectURI == "" || !contains(c.RedirectURIs, ar.RedirectURI):
+		return Authorization{}, ErrInvalidRedirectURI
+	}
+
+	if ar.ResponseType != "code" {
+		return authz, ErrUnsupportedResponseType
+	}
+	if ar.CodeChallengeMethod != codeChallengeS256 || !validCodeChallenge(ar.CodeChallenge) {
+		return authz, ErrInvalidCodeChallenge
+	}
+
+	scopes := strings.Fields(ar.Scope)
+	if len(scopes) == 0 {
+		scopes = c.Scopes
+	}
+	for _, scope := range scopes {
+		if !contains(c.Scopes, scope) {
+			return authz, ErrInvalidScope
+		}
+		authz.Scopes = appendMissing(authz.Scopes, scope)
+	}
+
+	return authz, nil
+}
+
+// Authorize authenticates a User and issues an authorization code for the
+// request, which the Client exchanges for tokens. Users must consent to the
+// scopes they didn't grant the Client before.
+func (us userService) Authorize(ctx context.Context, traceID string, ar AuthorizationRequest, lr LoginRequest, consent bool, now time.Time) (string, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.authorize")
+	defer span.End()
+
+	authz, err := us.CheckAuthorization(ctx, traceID, ar)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := us.Authenticate(ctx, traceID, now, lr.Email, lr.Password)
+	if err != nil {
+		return "", err
+	}
+
+	granted, err := us.repo.GetConsent(ctx, claims.Subject, authz.ClientID)
+	if err != nil && err != ErrNotFound {
+		return "", errors.Wrapf(err, "searching for consent of user %s", claims.Subject)
+	}
+	if err == ErrNotFound || !containsAll(granted.Scopes, authz.Scopes) {
+		if !consent {
+			return "", ErrConsentRequired
+		}
+
+		scopes := append([]string{}, granted.Scopes...)
+		for _, scope := range authz.Scopes {
+			scopes = appendMissing(scopes, scope)
+		}
+		c := Consent{
+			UserID:      claims.Subject,
+			ClientID:    authz.ClientID,
+			Scopes:      scopes,
+			DateCreated: now.UTC(),
+			DateUpdated: now.UTC(),
+		}
+		if err := us.repo.SaveConsent(ctx, c); err != nil {
+			return "", errors.Wrapf(err, "saving consent of user %s", claims.Subject)
+		}
+	}
+
+	code, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	ac := AuthorizationCode{
+		CodeHash:      hash,
+		ClientID:      authz.ClientID,
+		UserID:        claims.Subject,
+		RedirectURI:   ar.RedirectURI,
+		Scopes:        authz.Scopes,
+		CodeChallenge: ar.CodeChallenge,
+		Nonce:         ar.Nonce,
+		AuthTime:      now.UTC(),
+		ExpiresAt:     now.Add(us.cfg.AuthorizationCodeTTL).UTC(),
+		DateCreated:   now.UTC(),
+	}
+	if err := us.repo.CreateAuthorizationCode(ctx, ac); err != nil {
+		return "", errors.Wrap(err, "creating authorization code")
+	}
+
+	return code, nil
+}
+
+// ExchangeCode exchanges an authorization code for the claims of an access
+// token, the first refresh token of a new family and, for OpenID Connect
+// requests, the claims of an ID token. Codes can only be exchanged once, by
+// the Client they were issued to, with the redirect URI of their request and
+// the verifier of their code challenge. Exchanging a code again revokes the
+// refresh tokens issued for it.
+func (us userService) ExchangeCode(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.exchangeCode")
+	defer span.End()
+
+	familyID := uuid.New().String()
+	ac, err := us.repo.UseAuthorizationCode(ctx, hashToken(tr.Code), familyID, now)
+	if err != nil {
+		switch err {
+		case ErrInvalidGrant:
+			return Grant{}, err
+		default:
+			return Grant{}, errors.Wrap(err, "using authorization code")
+		}
+	}
+
+	if ac.ClientID != tr.ClientID || ac.RedirectURI != tr.RedirectURI || !verifyCodeChallenge(ac.CodeChallenge, tr.CodeVerifier) {
+		return Grant{}, ErrInvalidGrant
+	}
+
+	u, err := us.repo.GetByID(ctx, AnyTenant, ac.UserID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return Grant{}, ErrInvalidGrant
+		default:
+			return Grant{}, errors.Wrapf(err, "searching for user %q", ac.UserID)
+		}
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return Grant{}, err
+	}
+
+	rt := RefreshToken{
+		TokenHash:   hash,
+		FamilyID:    familyID,
+		UserID:      u.ID,
+		ClientID:    &ac.ClientID,
+		Scopes:      ac.Scopes,
+		AuthTime:    ac.AuthTime,
+		ExpiresAt:   now.Add(us.cfg.RefreshTokenTTL).UTC(),
+		DateCreated: now.UTC(),
+	}
+	if err := us.repo.CreateRefreshToken(ctx, rt); err != nil {
+		return Grant{}, errors.Wrap(err, "creating refresh token")
+	}
+
+	return us.clientGrant(ctx, u, rt, token, ac.Nonce, now)
+}
+
+// ExchangeRefreshToken is Refresh for the refresh tokens issued to OAuth
+// Clients, which can only be exchanged by the Client they were issued to.
+func (us userService) ExchangeRefreshToken(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.exchangeRefreshToken")
+	defer span.End()
+
+	return us.refresh(ctx, tr.RefreshToken, tr.ClientID, now)
+}
+
+// clientGrant returns the Grant of the Client of a refresh token, limited to
+// the scopes of the refresh token. ID tokens are only issued to Clients that
+// were granted the openid scope.
+func (us userService) clientGrant(ctx context.Context, u User, rt RefreshToken, token, nonce string, now time.Time) (Grant, error) {
+	claims, err := us.newClaims(ctx, u, now, us.cfg.AccessTokenTTL)
+	if err != nil {
+		return Grant{}, err
+	}
+
+	g := Grant{
+		Claims:       claims,
+		RefreshToken: token,
+	}
+	if rt.ClientID == nil {
+		return g, nil
+	}
+
+	g.Claims.ClientID = *rt.ClientID
+	g.Claims.Scope = strings.Join(rt.Scopes, " ")
+	if contains(rt.Scopes, auth.ScopeOpenID) {
+		g.IDClaims = us.idClaims(u, *rt.ClientID, rt.Scopes, rt.AuthTime, nonce, now)
+	}
+
+	return g, nil
+}
+
+// validRedirectURI reports whether a redirect URI can be registered. Private
+// schemes must be reverse domain names, such as com.example.app.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(uri, "#") {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return true
+		}
+		return false
+	}
+	return strings.Contains(u.Scheme, ".")
+}
+
+// validCodeChallenge reports whether a code challenge is the base64url
+// encoded SHA-256 hash of a verifier.
+func validCodeChallenge(challenge string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(b) == sha256.Size
+}
+
+// verifyCodeChallenge reports whether the verifier is the one the S256 code
+// challenge was derived from. Verifiers are 43 to 128 unreserved characters.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, r := range verifier {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("-._~", r)) {
+			return false
+		}
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// contains reports whether s is one of the values.
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAll reports whether every one of want is one of the values.
+func containsAll(values, want []string) bool {
+	for _, s := range want {
+		if !contains(values, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendMissing appends s to the values, unless it's already one of them.
+func appendMissing(values []string, s string) []string {
+	if contains(values, s) {
+		return values
+	}
+	return append(values, s)
+}
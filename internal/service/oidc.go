@@ -0,0 +1,57 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/santiagoh1997/service-template/internal/auth"
+)
+
+// idClaims returns the claims of the ID token of a User for an OAuth Client,
+// which carry the profile claims of the scopes the Client was granted.
+func (us userService) idClaims(u User, clientID string, scopes []string, authTime time.Time, nonce string, now time.Time) *auth.IDClaims {
+	return &auth.IDClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Issuer:    us.cfg.Issuer,
+			Subject:   u.ID,
+			Audience:  clientID,
+			ExpiresAt: now.Add(us.cfg.AccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		AuthTime: authTime.Unix(),
+		Nonce:    nonce,
+		Profile:  profile(u, scopes),
+	}
+}
+
+// UserInfo returns the claims about a User the UserInfo endpoint responds
+// with to a Client that was granted the scopes.
+func UserInfo(u User, scopes []string) auth.UserInfo {
+	return auth.UserInfo{
+		Subject: u.ID,
+		Profile: profile(u, scopes),
+	}
+}
+
+// profile returns the standard claims of a User released by the scopes.
+func profile(u User, scopes []string) auth.Profile {
+	var p auth.Profile
+	if contains(scopes, auth.ScopeProfile) {
+		p.Name = strings.TrimSpace(u.Name + " " + u.LastName)
+		p.GivenName = u.Name
+		p.FamilyName = u.LastName
+		p.UpdatedAt = u.DateUpdated.Unix()
+	}
+	if contains(scopes, auth.ScopeEmail) {
+		verified := u.EmailVerifiedAt != nil
+		p.Email = u.Email
+		p.EmailVerified = &verified
+	}
+	if contains(scopes, auth.ScopeAddress) && u.Country != "" {
+		p.Address = &auth.Address{Country: u.Country}
+	}
+	return p
+}
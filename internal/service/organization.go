@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTenantID is the ID of the Organization Users that sign up by
+// themselves belong to. It's created by the migrations.
+const DefaultTenantID = "e9b53a4c-1a7f-4d2c-9a55-3c3f2a4d8e71"
+
+// AnyTenant is the tenant of the operations of Users allowed to act across
+// tenants. Repository operations given AnyTenant reach the Users of every tenant.
+const AnyTenant = "*"
+
+// resourceOrganization is the type of the Organizations authorization
+// requests are made for.
+const resourceOrganization = "organization"
+
+// crossTenantPermissions can only be granted by Users that hold them, since
+// they give access to every tenant.
+var crossTenantPermissions = []string{auth.PermTenantsAll, auth.PermOrganizationsManage, auth.PermClientsManage}
+
+// tenantOf returns the tenant the operations performed with the claims are
+// limited to. Users allowed to act across tenants aren't limited to their own.
+func (us userService) tenantOf(ctx context.Context, claims auth.Claims) (string, error) {
+	perms, err := us.perms.Granted(ctx, claims)
+	if err != nil {
+		return "", errors.Wrap(err, "resolving permissions")
+	}
+	if perms.Has(auth.PermTenantsAll) {
+		return AnyTenant, nil
+	}
+	return claims.TenantID, nil
+}
+
+// checkCrossTenant forbids granting permissions that reach across tenants to
+// the Users that don't hold them.
+func (us userService) checkCrossTenant(ctx context.Context, claims auth.Claims, granted auth.Permissions) error {
+	perms, err := us.perms.Granted(ctx, claims)
+	if err != nil {
+		return errors.Wrap(err, "resolving permissions")
+	}
+	for _, perm := range crossTenantPermissions {
+		if granted.Has(perm) && !perms.Has(perm) {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// CreateInOrganization adds a User to an Organization. Admins can only add
+// Users to their own Organization.
+func (us userService) CreateInOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, nur NewUserRequest, now time.Time) (User, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.createInOrganization")
+	defer span.End()
+
+	resource := auth.Attributes{auth.AttrType: resourceUser, auth.AttrTenantID: orgID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermUsersWrite, resource); err != nil {
+		return User{}, err
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return User{}, err
+	}
+	if tenant != AnyTenant && tenant != orgID {
+		return User{}, ErrForbidden
+	}
+
+	if _, err := us.repo.GetOrganization(ctx, orgID); err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return User{}, err
+		default:
+			return User{}, errors.Wrapf(err, "searching for organization %q", orgID)
+		}
+	}
+
+	return us.create(ctx, orgID, nur, now)
+}
+
+// QueryOrganizations retrieves every Organization.
+func (us userService) QueryOrganizations(ctx context.Context, traceID string, claims auth.Claims) ([]Organization, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryOrganizations")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermOrganizationsManage, auth.Attributes{auth.AttrType: resourceOrganization}); err != nil {
+		return nil, err
+	}
+
+	orgs, err := us.repo.QueryOrganizations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying organizations")
+	}
+
+	return orgs, nil
+}
+
+// GetOrganization retrieves an Organization by its ID. Users can retrieve
+// their own Organization.
+func (us userService) GetOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) (Organization, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.getOrganization")
+	defer span.End()
+
+	if claims.TenantID != orgID {
+		resource := auth.Attributes{auth.AttrType: resourceOrganization, auth.AttrID: orgID}
+		if err := us.authorize(ctx, traceID, claims, auth.PermOrganizationsManage, resource); err != nil {
+			return Organization{}, err
+		}
+	}
+
+	o, err := us.repo.GetOrganization(ctx, orgID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return Organization{}, err
+		default:
+			return Organization{}, errors.Wrapf(err, "searching for organization %q", orgID)
+		}
+	}
+
+	return o, nil
+}
+
+// CreateOrganization creates a new Organization.
+func (us userService) CreateOrganization(ctx context.Context, traceID string, claims auth.Claims, nor NewOrganizationRequest, now time.Time) (Organization, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.createOrganization")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermOrganizationsManage, auth.Attributes{auth.AttrType: resourceOrganization}); err != nil {
+		return Organization{}, err
+	}
+
+	o := Organization{
+		ID:          uuid.New().String(),
+		Name:        nor.Name,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+	if err := us.repo.CreateOrganization(ctx, o); err != nil {
+		switch err {
+		case ErrDuplicatedOrganization:
+			return Organization{}, err
+		default:
+			return Organization{}, errors.Wrapf(err, "creating organization %s", o.Name)
+		}
+	}
+
+	return o, nil
+}
+
+// UpdateOrganization renames an Organization.
+func (us userService) UpdateOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, uor UpdateOrganizationRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.updateOrganization")
+	defer span.End()
+
+	resource := auth.Attributes{auth.AttrType: resourceOrganization, auth.AttrID: orgID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermOrganizationsManage, resource); err != nil {
+		return err
+	}
+
+	if err := us.repo.UpdateOrganization(ctx, orgID, uor.Name, now); err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound, ErrDuplicatedOrganization:
+			return err
+		default:
+			return errors.Wrapf(err, "updating organization %s", orgID)
+		}
+	}
+
+	return nil
+}
+
+// DeleteOrganization deletes an Organization without Users. The default
+// Organization can't be deleted.
+func (us userService) DeleteOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.deleteOrganization")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	resource := auth.Attributes{auth.AttrType: resourceOrganization, auth.AttrID: orgID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermOrganizationsManage, resource); err != nil {
+		return err
+	}
+
+	if orgID == DefaultTenantID {
+		return ErrForbidden
+	}
+
+	if err := us.repo.DeleteOrganization(ctx, orgID); err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound, ErrOrganizationInUse:
+			return err
+		default:
+			return errors.Wrapf(err, "deleting organization %s", orgID)
+		}
+	}
+
+	return nil
+}
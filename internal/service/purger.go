@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Purger periodically removes for good the Users that were deleted longer
+// than the retention period ago.
+type Purger struct {
+	repo      Repository
+	log       *log.Logger
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewPurger constructs a Purger.
+func NewPurger(repo Repository, log *log.Logger, retention, interval time.Duration) (*Purger, error) {
+	if repo == nil {
+		return nil, errors.New("repo can't be nil")
+	}
+	if log == nil {
+		return nil, errors.New("log can't be nil")
+	}
+	if retention <= 0 || interval <= 0 {
+		return nil, errors.New("retention and interval must be positive")
+	}
+
+	return &Purger{
+		repo:      repo,
+		log:       log,
+		retention: retention,
+		interval:  interval,
+	}, nil
+}
+
+// Purge removes the Users deleted before the retention period and returns
+// how many were removed.
+func (p *Purger) Purge(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.purge")
+	defer span.End()
+
+	n, err := p.repo.Purge(ctx, now.Add(-p.retention))
+	if err != nil {
+		return 0, errors.Wrap(err, "purging users")
+	}
+
+	return n, nil
+}
+
+// Run purges Users on every interval until the context is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := p.Purge(ctx, now)
+			if err != nil {
+				p.log.Printf("purger: ERROR: %v", err)
+				continue
+			}
+			if n > 0 {
+				p.log.Printf("purger: purged %d deleted users", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"strings"
+	"time"
+)
+
+// Set of fields that the results of a query can be ordered by.
+const (
+	OrderByID          = "user_id"
+	OrderByName        = "name"
+	OrderByLastName    = "last_name"
+	OrderByEmail       = "email"
+	OrderByCountry     = "country"
+	OrderByDateCreated = "date_created"
+	OrderByDateUpdated = "date_updated"
+)
+
+// Set of directions for ordering the results of a query.
+const (
+	ASC  = "ASC"
+	DESC = "DESC"
+)
+
+// Limits applied to the pagination of a query.
+const (
+	DefaultRowsPerPage = 20
+	MaxRowsPerPage     = 100
+)
+
+// orderByFields is the set of fields that can be used for ordering.
+var orderByFields = map[string]bool{
+	OrderByID:          true,
+	OrderByName:        true,
+	OrderByLastName:    true,
+	OrderByEmail:       true,
+	OrderByCountry:     true,
+	OrderByDateCreated: true,
+	OrderByDateUpdated: true,
+}
+
+// DefaultOrderBy is used when a query doesn't specify an order.
+var DefaultOrderBy = OrderBy{Field: OrderByDateCreated, Direction: DESC}
+
+// OrderBy represents a field used to order the results of a query
+// and the direction of the ordering.
+type OrderBy struct {
+	Field     string
+	Direction string
+}
+
+// ParseOrderBy constructs an OrderBy from a string in the form "field,direction".
+// The direction is optional and defaults to ASC.
+func ParseOrderBy(s string) (OrderBy, error) {
+	if s == "" {
+		return DefaultOrderBy, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) > 2 {
+		return OrderBy{}, ErrInvalidOrderBy
+	}
+
+	ob := OrderBy{
+		Field:     strings.TrimSpace(parts[0]),
+		Direction: ASC,
+	}
+	if len(parts) == 2 {
+		ob.Direction = strings.ToUpper(strings.TrimSpace(parts[1]))
+	}
+
+	if err := ob.validate(); err != nil {
+		return OrderBy{}, err
+	}
+
+	return ob, nil
+}
+
+// validate checks that both the field and the direction are supported.
+func (ob OrderBy) validate() error {
+	if !orderByFields[ob.Field] {
+		return ErrInvalidOrderBy
+	}
+	if ob.Direction != ASC && ob.Direction != DESC {
+		return ErrInvalidOrderBy
+	}
+	return nil
+}
+
+// QueryFilter holds the criteria used to filter Users in a query.
+// Zero values are ignored.
+type QueryFilter struct {
+	Country       string
+	Role          string
+	EmailPrefix   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Cursor represents the position of the last User of a page when Users are
+// ordered by their creation date. It's used to seek the following page
+// without using offsets.
+type Cursor struct {
+	DateCreated time.Time `json:"date_created"`
+	ID          string    `json:"id"`
+	Direction   string    `json:"direction"`
+}
+
+// QueryResult represents a page of Users and the total amount of Users
+// matching the query. Total and Page are only known for offset based
+// queries. Next is set when there are more Users to retrieve after the page.
+type QueryResult struct {
+	Items       []User  `json:"items"`
+	Total       int     `json:"total,omitempty"`
+	Page        int     `json:"page,omitempty"`
+	RowsPerPage int     `json:"rows_per_page"`
+	Next        *Cursor `json:"-"`
+}
+
+// cursorAfter returns a Cursor pointing to the last User of a page.
+func cursorAfter(users []User, direction string) *Cursor {
+	last := users[len(users)-1]
+	return &Cursor{
+		DateCreated: last.DateCreated,
+		ID:          last.ID,
+		Direction:   direction,
+	}
+}
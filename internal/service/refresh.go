@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IssueRefreshToken returns a refresh token for the User of claims that were
+// just issued by Authenticate, starting a new family of refresh tokens. Only
+// the hash of the token is stored. Impersonated sessions can't be refreshed.
+func (us userService) IssueRefreshToken(ctx context.Context, traceID string, claims auth.Claims, now time.Time) (string, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.issueRefreshToken")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return "", ErrImpersonating
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	rt := RefreshToken{
+		TokenHash:   hash,
+		FamilyID:    uuid.New().String(),
+		UserID:      claims.Subject,
+		AuthTime:    now.UTC(),
+		ExpiresAt:   now.Add(us.cfg.RefreshTokenTTL).UTC(),
+		DateCreated: now.UTC(),
+	}
+
+	if err := us.repo.CreateRefreshToken(ctx, rt); err != nil {
+		return "", errors.Wrap(err, "creating refresh token")
+	}
+
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for the claims of a new access token and
+// the refresh token that replaces it in its family. The claims reflect the
+// current state of the User. Presenting a token that was already exchanged
+// revokes its whole family, as does the User changing their password since
+// they authenticated.
+func (us userService) Refresh(ctx context.Context, traceID string, token string, now time.Time) (auth.Claims, string, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.refresh")
+	defer span.End()
+
+	g, err := us.refresh(ctx, token, "", now)
+	if err != nil {
+		return auth.Claims{}, "", err
+	}
+
+	return g.Claims, g.RefreshToken, nil
+}
+
+// refresh rotates a refresh token issued to the OAuth Client, or to no
+// Client at all when clientID is empty. The family of the token is revoked
+// when the grant is refused.
+func (us userService) refresh(ctx context.Context, token, clientID string, now time.Time) (Grant, error) {
+	nextToken, nextHash, err := newToken()
+	if err != nil {
+		return Grant{}, err
+	}
+
+	next := RefreshToken{
+		TokenHash:   nextHash,
+		ExpiresAt:   now.Add(us.cfg.RefreshTokenTTL).UTC(),
+		DateCreated: now.UTC(),
+	}
+
+	rt, err := us.repo.RotateRefreshToken(ctx, hashToken(token), next, now)
+	if err != nil {
+		switch err {
+		case ErrInvalidRefreshToken, ErrRefreshTokenReused:
+			return Grant{}, err
+		default:
+			return Grant{}, errors.Wrap(err, "rotating refresh token")
+		}
+	}
+
+	// The successor is stored along with the rotation, so its family is
+	// revoked when the grant is refused rather than left usable.
+	g, err := us.refreshGrant(ctx, rt, nextToken, clientID, now)
+	if err != nil {
+		if rerr := us.repo.RevokeRefreshTokenFamily(ctx, rt.UserID, nextHash, now); rerr != nil {
+			return Grant{}, errors.Wrap(rerr, "revoking refresh tokens")
+		}
+		return Grant{}, err
+	}
+
+	return g, nil
+}
+
+// refreshGrant checks that the successor of a rotated refresh token can
+// still be granted to the Client and its User and returns the grant.
+func (us userService) refreshGrant(ctx context.Context, rt RefreshToken, token, clientID string, now time.Time) (Grant, error) {
+	// Tokens presented by another Client are consumed all the same, so a
+	// stolen token can't be retried.
+	var issuedTo string
+	if rt.ClientID != nil {
+		issuedTo = *rt.ClientID
+	}
+	if issuedTo != clientID {
+		return Grant{}, ErrInvalidRefreshToken
+	}
+
+	u, err := us.repo.GetByID(ctx, AnyTenant, rt.UserID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return Grant{}, ErrInvalidRefreshToken
+		default:
+			return Grant{}, errors.Wrapf(err, "searching for user %q", rt.UserID)
+		}
+	}
+
+	if u.PasswordChangedAt != nil && rt.AuthTime.Before(*u.PasswordChangedAt) {
+		return Grant{}, ErrInvalidRefreshToken
+	}
+
+	if us.cfg.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		return Grant{}, ErrEmailNotVerified
+	}
+
+	return us.clientGrant(ctx, u, rt, token, "", now)
+}
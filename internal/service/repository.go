@@ -5,12 +5,67 @@ import (
 	"time"
 )
 
-// Repository represents a persistance layer.
+// Repository represents a persistance layer. Operations taking a tenantID
+// only reach the Users of that tenant, or every User for AnyTenant.
 type Repository interface {
 	Create(ctx context.Context, u User, now time.Time) (User, error)
-	GetByID(ctx context.Context, userID string) (User, error)
-	Update(ctx context.Context, userID, name, lastName, country string, now time.Time) error
-	Delete(ctx context.Context, userID string) error
+	GetByID(ctx context.Context, tenantID, userID string) (User, error)
+	Update(ctx context.Context, tenantID, userID, name, lastName, country string, version int, now time.Time) (int, error)
+	Patch(ctx context.Context, tenantID, userID string, pur PatchUserRequest, version int, now time.Time) (int, error)
+	UpdatePassword(ctx context.Context, tenantID, userID string, passwordHash []byte, now time.Time) error
+	CreateResetToken(ctx context.Context, userID, tokenHash string, expiresAt, now time.Time) error
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash []byte, now time.Time) error
+	CreateVerificationToken(ctx context.Context, userID, email, tokenHash string, expiresAt, now time.Time) error
+	VerifyEmail(ctx context.Context, tokenHash string, now time.Time) error
+	CreateEmailChangeToken(ctx context.Context, userID, oldEmail, newEmail, tokenHash string, expiresAt, now time.Time) error
+	ChangeEmail(ctx context.Context, tokenHash string, now time.Time) error
+	CreateRefreshToken(ctx context.Context, rt RefreshToken) error
+	RotateRefreshToken(ctx context.Context, tokenHash string, next RefreshToken, now time.Time) (RefreshToken, error)
+	RevokeRefreshTokens(ctx context.Context, userID string, now time.Time) error
+	RevokeRefreshTokenFamily(ctx context.Context, userID, tokenHash string, now time.Time) error
+	QueryClients(ctx context.Context) ([]Client, error)
+	GetClient(ctx context.Context, clientID string) (Client, error)
+	CreateClient(ctx context.Context, c Client) error
+	DeleteClient(ctx context.Context, clientID string) error
+	GetConsent(ctx context.Context, userID, clientID string) (Consent, error)
+	SaveConsent(ctx context.Context, c Consent) error
+	CreateAuthorizationCode(ctx context.Context, ac AuthorizationCode) error
+	UseAuthorizationCode(ctx context.Context, codeHash, familyID string, now time.Time) (AuthorizationCode, error)
+	QueryServiceAccounts(ctx context.Context, tenantID string) ([]ServiceAccount, error)
+	GetServiceAccount(ctx context.Context, tenantID, accountID string) (ServiceAccount, error)
+	CreateServiceAccount(ctx context.Context, sa ServiceAccount) error
+	UpdateServiceAccountSecret(ctx context.Context, tenantID, accountID, secretHash string, now time.Time) error
+	DeleteServiceAccount(ctx context.Context, tenantID, accountID string) error
+	UpdateRoles(ctx context.Context, tenantID, userID string, roles []string, version int, rc RoleChange) error
+	QueryRoleChanges(ctx context.Context, tenantID, userID string) ([]RoleChange, error)
+	QueryRoles(ctx context.Context) ([]Role, error)
+	CreateRole(ctx context.Context, r Role) error
+	UpdateRole(ctx context.Context, name string, permissions []string) error
+	DeleteRole(ctx context.Context, name string) error
+	QueryOrganizations(ctx context.Context) ([]Organization, error)
+	GetOrganization(ctx context.Context, orgID string) (Organization, error)
+	CreateOrganization(ctx context.Context, o Organization) error
+	UpdateOrganization(ctx context.Context, orgID, name string, now time.Time) error
+	DeleteOrganization(ctx context.Context, orgID string) error
+	QueryGroups(ctx context.Context, tenantID string) ([]Group, error)
+	GetGroup(ctx context.Context, tenantID, groupID string) (Group, error)
+	CreateGroup(ctx context.Context, g Group) error
+	UpdateGroup(ctx context.Context, tenantID, groupID, name string, now time.Time) error
+	DeleteGroup(ctx context.Context, tenantID, groupID string) error
+	AddGroupMember(ctx context.Context, tenantID, groupID, userID string, now time.Time) error
+	RemoveGroupMember(ctx context.Context, tenantID, groupID, userID string) error
+	QueryGroupMembers(ctx context.Context, tenantID, groupID string, page, rowsPerPage int) ([]User, int, error)
+	QueryUserGroups(ctx context.Context, tenantID, userID string) ([]Group, error)
+	CreateAuditEvent(ctx context.Context, e AuditEvent, key []byte) (AuditEvent, error)
+	GetAuditHead(ctx context.Context) (AuditHead, error)
+	QueryAuditEvents(ctx context.Context, tenantID string, filter AuditFilter, page, rowsPerPage int) ([]AuditEvent, int, error)
+	QueryAuditChain(ctx context.Context, afterSeq, toSeq int64, limit int) ([]AuditEvent, error)
+	Delete(ctx context.Context, tenantID, userID string, version int, now time.Time) error
+	Restore(ctx context.Context, tenantID, userID string, now time.Time) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
 	GetByEmail(ctx context.Context, email string) (User, error)
 	CheckEmailInUse(ctx context.Context, email string) (bool, error)
+	Query(ctx context.Context, tenantID string, filter QueryFilter, orderBy OrderBy, page, rowsPerPage int) ([]User, int, error)
+	QueryAfter(ctx context.Context, tenantID string, filter QueryFilter, after Cursor, limit int) ([]User, error)
+	Search(ctx context.Context, tenantID, terms string, limit int) ([]User, error)
 }
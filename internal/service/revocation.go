@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Logout revokes the token of the claims until it expires, along with the
+// family of the refresh token of the request, if any. Tokens issued before
+// tokens had an ID can't be revoked individually.
+func (us userService) Logout(ctx context.Context, traceID string, claims auth.Claims, lr LogoutRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.logout")
+	defer span.End()
+
+	if claims.Id != "" {
+		if err := us.cfg.Denylist.Revoke(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+			return errors.Wrap(err, "revoking token")
+		}
+	}
+
+	if lr.RefreshToken != "" {
+		if err := us.repo.RevokeRefreshTokenFamily(ctx, claims.Subject, hashToken(lr.RefreshToken), now); err != nil {
+			return errors.Wrap(err, "revoking refresh token")
+		}
+	}
+
+	return nil
+}
+
+// RevokeTokens revokes every token and refresh token issued to a User so
+// far, including the ones of sessions impersonating them. Users can revoke
+// their own tokens to log out everywhere.
+func (us userService) RevokeTokens(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.revokeTokens")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermTokensRevoke, userID)
+	if err != nil {
+		return err
+	}
+
+	// The revocation lasts until the last token issued so far expires.
+	ttl := us.cfg.AccessTokenTTL
+	if us.cfg.ImpersonationTTL > ttl {
+		ttl = us.cfg.ImpersonationTTL
+	}
+	if err := us.cfg.Denylist.RevokeSubject(ctx, u.ID, now, now.Add(ttl)); err != nil {
+		return errors.Wrapf(err, "revoking tokens of user %s", u.ID)
+	}
+
+	if err := us.repo.RevokeRefreshTokens(ctx, u.ID, now); err != nil {
+		return errors.Wrapf(err, "revoking refresh tokens of user %s", u.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rolePermissions adapts the roles of a Repository to be loaded by an auth.Resolver.
+func rolePermissions(repo Repository) auth.RolesFunc {
+	return func(ctx context.Context) (map[string][]string, error) {
+		roles, err := repo.QueryRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		perms := make(map[string][]string, len(roles))
+		for _, r := range roles {
+			perms[r.Name] = r.Permissions
+		}
+		return perms, nil
+	}
+}
+
+// builtinRole reports whether a Role is required by the service and can't
+// be modified or deleted.
+func builtinRole(name string) bool {
+	return name == auth.RoleSuperAdmin || name == auth.RoleAdmin || name == auth.DefaultRole
+}
+
+// validatePermissions checks that every permission is known and removes duplicates.
+func validatePermissions(perms []string) ([]string, error) {
+	seen := make(map[string]bool, len(perms))
+	valid := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		if !auth.ValidPermission(perm) {
+			return nil, ErrUnknownPermission
+		}
+		if !seen[perm] {
+			seen[perm] = true
+			valid = append(valid, perm)
+		}
+	}
+	return valid, nil
+}
+
+// QueryRoles retrieves every Role with the permissions it grants.
+func (us userService) QueryRoles(ctx context.Context, traceID string, claims auth.Claims) ([]Role, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryRoles")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermRolesManage, auth.Attributes{auth.AttrType: resourceRole}); err != nil {
+		return nil, err
+	}
+
+	roles, err := us.repo.QueryRoles(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying roles")
+	}
+
+	return roles, nil
+}
+
+// CreateRole defines a new Role granting the given permissions. Roles are
+// shared by every tenant, so only Users acting across tenants define them.
+func (us userService) CreateRole(ctx context.Context, traceID string, claims auth.Claims, nr NewRoleRequest, now time.Time) (Role, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.createRole")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermRolesManage, auth.Attributes{auth.AttrType: resourceRole, auth.AttrID: nr.Name}); err != nil {
+		return Role{}, err
+	}
+
+	if err := us.requireAnyTenant(ctx, claims); err != nil {
+		return Role{}, err
+	}
+
+	perms, err := validatePermissions(nr.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+
+	r := Role{
+		Name:        nr.Name,
+		Permissions: perms,
+		DateCreated: now.UTC(),
+	}
+	if err := us.repo.CreateRole(ctx, r); err != nil {
+		switch err {
+		case ErrDuplicatedRole:
+			return Role{}, err
+		default:
+			return Role{}, errors.Wrapf(err, "creating role %s", r.Name)
+		}
+	}
+	us.perms.Invalidate()
+
+	return r, nil
+}
+
+// UpdateRole replaces the permissions granted by a Role. Built-in Roles
+// can't be modified.
+func (us userService) UpdateRole(ctx context.Context, traceID string, claims auth.Claims, name string, urr UpdateRoleRequest) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.updateRole")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermRolesManage, auth.Attributes{auth.AttrType: resourceRole, auth.AttrID: name}); err != nil {
+		return err
+	}
+
+	if err := us.requireAnyTenant(ctx, claims); err != nil {
+		return err
+	}
+
+	if builtinRole(name) {
+		return ErrForbidden
+	}
+
+	perms, err := validatePermissions(urr.Permissions)
+	if err != nil {
+		return err
+	}
+
+	if err := us.repo.UpdateRole(ctx, name, perms); err != nil {
+		switch err {
+		case ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "updating role %s", name)
+		}
+	}
+	us.perms.Invalidate()
+
+	return nil
+}
+
+// DeleteRole removes a Role that isn't assigned to any User. Built-in Roles
+// can't be deleted.
+func (us userService) DeleteRole(ctx context.Context, traceID string, claims auth.Claims, name string) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.deleteRole")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermRolesManage, auth.Attributes{auth.AttrType: resourceRole, auth.AttrID: name}); err != nil {
+		return err
+	}
+
+	if err := us.requireAnyTenant(ctx, claims); err != nil {
+		return err
+	}
+
+	if builtinRole(name) {
+		return ErrForbidden
+	}
+
+	if err := us.repo.DeleteRole(ctx, name); err != nil {
+		switch err {
+		case ErrNotFound, ErrRoleInUse:
+			return err
+		default:
+			return errors.Wrapf(err, "deleting role %s", name)
+		}
+	}
+	us.perms.Invalidate()
+
+	return nil
+}
+
+// requireAnyTenant forbids the operations that affect every tenant to the
+// Users limited to their own.
+func (us userService) requireAnyTenant(ctx context.Context, claims auth.Claims) error {
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return err
+	}
+	if tenant != AnyTenant {
+		return ErrForbidden
+	}
+	return nil
+}
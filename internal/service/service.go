@@ -3,6 +3,9 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -10,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/santiagoh1997/service-template/internal/auth"
+	"github.com/santiagoh1997/service-template/internal/pkg/mail"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -31,35 +35,311 @@ var (
 
 	// ErrForbidden occurs when a user tries to do something that is forbidden to them according to our access control policies.
 	ErrForbidden = errors.New("attempted action is not allowed")
+
+	// ErrIncorrectPassword occurs when the current password provided to change
+	// a password doesn't match the saved one.
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+
+	// ErrInvalidResetToken occurs when a password reset token doesn't exist,
+	// has expired or has already been used.
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+	// ErrInvalidVerificationToken occurs when an email verification token
+	// doesn't exist, has expired, has already been used or was issued for an
+	// email the User doesn't have anymore.
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+	// ErrEmailNotVerified occurs when a User that hasn't verified their email
+	// attempts to authenticate while verification is required.
+	ErrEmailNotVerified = errors.New("email not verified")
+
+	// ErrInvalidEmailChangeToken occurs when an email change token doesn't
+	// exist, has expired, has already been used or the User's email changed
+	// since it was issued.
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+
+	// ErrUnknownRole occurs when a role that isn't known by the service is
+	// assigned to a User.
+	ErrUnknownRole = errors.New("unknown role")
+
+	// ErrUnknownPermission occurs when a role is defined with a permission
+	// that isn't known by the service.
+	ErrUnknownPermission = errors.New("unknown permission")
+
+	// ErrDuplicatedRole occurs when a role is defined with the name of an
+	// existing one.
+	ErrDuplicatedRole = errors.New("role already exists")
+
+	// ErrRoleInUse occurs when a role that's still assigned to Users is deleted.
+	ErrRoleInUse = errors.New("role is assigned to users")
+
+	// ErrDuplicatedOrganization occurs when an Organization is created or
+	// renamed with the name of an existing one.
+	ErrDuplicatedOrganization = errors.New("organization already exists")
+
+	// ErrOrganizationInUse occurs when an Organization that still has Users
+	// is deleted.
+	ErrOrganizationInUse = errors.New("organization has users")
+
+	// ErrDuplicatedGroup occurs when a Group is created or renamed with the
+	// name of another Group of the same Organization.
+	ErrDuplicatedGroup = errors.New("group already exists")
+
+	// ErrInvalidRefreshToken occurs when a refresh token doesn't exist, has
+	// expired, was revoked or its User can't authenticate anymore.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+	// ErrRefreshTokenReused occurs when a refresh token that was already
+	// exchanged is presented again. The whole family of the token is revoked
+	// since either the client or an attacker holds a stolen copy.
+	ErrRefreshTokenReused = errors.New("refresh token already used")
+
+	// ErrInvalidClient occurs when an OAuth client doesn't exist, or a
+	// service account fails to authenticate.
+	ErrInvalidClient = errors.New("invalid client")
+
+	// ErrServiceAccountPermission occurs when a service account is granted a
+	// permission only Users can hold.
+	ErrServiceAccountPermission = errors.New("permission can't be granted to service accounts")
+
+	// ErrInvalidRedirectURI occurs when a redirect URI isn't registered for
+	// an OAuth client, or can't be registered since it's not absolute, has a
+	// fragment or isn't https, a loopback address or a private-use scheme.
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+
+	// ErrUnsupportedResponseType occurs when an authorization request asks
+	// for anything other than an authorization code.
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+
+	// ErrInvalidScope occurs when an OAuth client requests a scope it wasn't
+	// registered with, or is registered with a malformed one.
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrInvalidCodeChallenge occurs when an authorization request doesn't
+	// carry an S256 PKCE code challenge.
+	ErrInvalidCodeChallenge = errors.New("code challenge required using S256")
+
+	// ErrConsentRequired occurs when a User authorizes an OAuth client without
+	// consenting to scopes they didn't grant it before.
+	ErrConsentRequired = errors.New("consent required")
+
+	// ErrInvalidGrant occurs when an authorization code doesn't exist, has
+	// expired, was already exchanged or was issued to another client or
+	// redirect URI, or the code verifier doesn't match its challenge.
+	ErrInvalidGrant = errors.New("invalid authorization grant")
+
+	// ErrImpersonating occurs when an impersonated session attempts an
+	// operation only the User themselves or an admin acting as such can perform.
+	ErrImpersonating = errors.New("operation not allowed while impersonating")
+
+	// ErrConflict occurs when a User is modified based on a version of it
+	// that's no longer the current one.
+	ErrConflict = errors.New("user was modified concurrently")
+
+	// ErrInvalidOrderBy occurs when a query is ordered by an unknown field or direction.
+	ErrInvalidOrderBy = errors.New("invalid order by")
+
+	// ErrInvalidCursor occurs when a Cursor doesn't point to a valid position.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrEmptySearch occurs when a search is attempted without any terms.
+	ErrEmptySearch = errors.New("search terms can't be empty")
+
+	// ErrInvalidPagination occurs when the page or the rows per page of a query are out of range.
+	ErrInvalidPagination = errors.New("invalid pagination parameters")
 )
 
 // UserService manages the set of API's for user access.
 type UserService interface {
 	Create(ctx context.Context, traceID string, nur NewUserRequest, now time.Time) (User, error)
-	Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, now time.Time) error
-	Delete(ctx context.Context, traceID string, claims auth.Claims, userID string) error
+	Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, version int, now time.Time) (int, error)
+	Patch(ctx context.Context, traceID string, claims auth.Claims, userID string, pur PatchUserRequest, version int, now time.Time) (int, error)
+	ChangePassword(ctx context.Context, traceID string, claims auth.Claims, userID string, cpr ChangePasswordRequest, now time.Time) error
+	ForgotPassword(ctx context.Context, traceID string, email string, now time.Time) error
+	ResetPassword(ctx context.Context, traceID string, rpr ResetPasswordRequest, now time.Time) error
+	VerifyEmail(ctx context.Context, traceID string, token string, now time.Time) error
+	ResendVerification(ctx context.Context, traceID string, email string, now time.Time) error
+	RequestEmailChange(ctx context.Context, traceID string, claims auth.Claims, userID string, cer ChangeEmailRequest, now time.Time) error
+	ConfirmEmailChange(ctx context.Context, traceID string, token string, now time.Time) error
+	UpdateRoles(ctx context.Context, traceID string, claims auth.Claims, userID string, urr UpdateRolesRequest, now time.Time) error
+	QueryRoleChanges(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]RoleChange, error)
+	Impersonate(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) (auth.Claims, error)
+	IssueRefreshToken(ctx context.Context, traceID string, claims auth.Claims, now time.Time) (string, error)
+	Refresh(ctx context.Context, traceID string, token string, now time.Time) (auth.Claims, string, error)
+	Logout(ctx context.Context, traceID string, claims auth.Claims, lr LogoutRequest, now time.Time) error
+	QueryClients(ctx context.Context, traceID string, claims auth.Claims) ([]Client, error)
+	RegisterClient(ctx context.Context, traceID string, claims auth.Claims, ncr NewClientRequest, now time.Time) (Client, error)
+	DeleteClient(ctx context.Context, traceID string, claims auth.Claims, clientID string) error
+	CheckAuthorization(ctx context.Context, traceID string, ar AuthorizationRequest) (Authorization, error)
+	Authorize(ctx context.Context, traceID string, ar AuthorizationRequest, lr LoginRequest, consent bool, now time.Time) (string, error)
+	ExchangeCode(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error)
+	ExchangeRefreshToken(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error)
+	ExchangeClientCredentials(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error)
+	QueryServiceAccounts(ctx context.Context, traceID string, claims auth.Claims) ([]ServiceAccount, error)
+	CreateServiceAccount(ctx context.Context, traceID string, claims auth.Claims, nsr NewServiceAccountRequest, now time.Time) (ServiceAccount, string, error)
+	RotateServiceAccountSecret(ctx context.Context, traceID string, claims auth.Claims, accountID string, now time.Time) (string, error)
+	DeleteServiceAccount(ctx context.Context, traceID string, claims auth.Claims, accountID string) error
+	RevokeTokens(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error
+	QueryRoles(ctx context.Context, traceID string, claims auth.Claims) ([]Role, error)
+	CreateRole(ctx context.Context, traceID string, claims auth.Claims, nr NewRoleRequest, now time.Time) (Role, error)
+	UpdateRole(ctx context.Context, traceID string, claims auth.Claims, name string, urr UpdateRoleRequest) error
+	DeleteRole(ctx context.Context, traceID string, claims auth.Claims, name string) error
+	CreateInOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, nur NewUserRequest, now time.Time) (User, error)
+	QueryOrganizations(ctx context.Context, traceID string, claims auth.Claims) ([]Organization, error)
+	GetOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) (Organization, error)
+	CreateOrganization(ctx context.Context, traceID string, claims auth.Claims, nor NewOrganizationRequest, now time.Time) (Organization, error)
+	UpdateOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string, uor UpdateOrganizationRequest, now time.Time) error
+	DeleteOrganization(ctx context.Context, traceID string, claims auth.Claims, orgID string) error
+	QueryGroups(ctx context.Context, traceID string, claims auth.Claims) ([]Group, error)
+	CreateGroup(ctx context.Context, traceID string, claims auth.Claims, ngr NewGroupRequest, now time.Time) (Group, error)
+	UpdateGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string, ugr UpdateGroupRequest, now time.Time) error
+	DeleteGroup(ctx context.Context, traceID string, claims auth.Claims, groupID string) error
+	AddGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string, now time.Time) error
+	RemoveGroupMember(ctx context.Context, traceID string, claims auth.Claims, groupID, userID string) error
+	QueryGroupMembers(ctx context.Context, traceID string, claims auth.Claims, groupID string, page, rowsPerPage int) (QueryResult, error)
+	QueryUserGroups(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]Group, error)
+	QueryAuditEvents(ctx context.Context, traceID string, claims auth.Claims, filter AuditFilter, page, rowsPerPage int) (AuditResult, error)
+	VerifyAuditEvents(ctx context.Context, traceID string, claims auth.Claims) (AuditVerification, error)
+	Delete(ctx context.Context, traceID string, claims auth.Claims, userID string, version int, now time.Time) error
+	Restore(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error
 	GetByID(ctx context.Context, traceID string, claims auth.Claims, userID string) (User, error)
+	Query(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, orderBy OrderBy, page, rowsPerPage int) (QueryResult, error)
+	QueryAfter(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, after Cursor, rowsPerPage int) (QueryResult, error)
+	Search(ctx context.Context, traceID string, claims auth.Claims, terms string, limit int) ([]User, error)
 	Authenticate(ctx context.Context, traceID string, now time.Time, email, password string) (auth.Claims, error)
+	ValidateClaims(ctx context.Context, claims auth.Claims) error
+	Permissions(ctx context.Context, claims auth.Claims) (auth.Permissions, error)
+}
+
+// Defaults used when Config doesn't specify how long access, refresh and
+// impersonation tokens, authorization codes and the tokens sent to Users are
+// valid for or how long the permissions of roles are cached.
+const (
+	DefaultAccessTokenTTL       = time.Hour
+	DefaultRefreshTokenTTL      = 30 * 24 * time.Hour
+	DefaultAuthorizationCodeTTL = 10 * time.Minute
+	DefaultResetTokenTTL        = time.Hour
+	DefaultVerificationTokenTTL = 24 * time.Hour
+	DefaultImpersonationTTL     = 15 * time.Minute
+	DefaultPermissionsTTL       = time.Minute
+)
+
+// DefaultIssuer is the issuer of tokens when Config doesn't specify one.
+const DefaultIssuer = "http://localhost:3000"
+
+// Config holds the settings of a UserService. Zero values are replaced by
+// their defaults.
+type Config struct {
+	// Issuer identifies the service in the tokens it issues. OpenID Connect
+	// requires it to be the https URL the service is reached at.
+	Issuer string
+
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	AuthorizationCodeTTL time.Duration
+	ResetTokenTTL        time.Duration
+	VerificationTokenTTL time.Duration
+	ImpersonationTTL     time.Duration
+	PermissionsTTL       time.Duration
+
+	// Policy is evaluated along with the permissions granted by roles to
+	// authorize requests. Every decision is written to DecisionLog, if set.
+	Policy      auth.Policy
+	DecisionLog *log.Logger
+
+	// Log receives the errors of the work done in the background, such as
+	// delivering mail. They're discarded when it's not set.
+	Log *log.Logger
+
+	// Worker runs the work done in the background. Without one it's done
+	// before the call that triggered it returns.
+	Worker *Worker
+
+	// AuditKey signs the head of the chain of audit events. Without it the
+	// head isn't signed, so it can be changed along with the events.
+	AuditKey []byte
+
+	// RequireVerifiedEmail prevents Users that haven't verified their email
+	// from authenticating.
+	RequireVerifiedEmail bool
+
+	// Denylist keeps track of the tokens revoked before they expire. Tokens
+	// are kept in memory when it's not set.
+	Denylist auth.Denylist
+
+	// EmbedGroups adds the IDs of the Groups of a User to the claims they
+	// authenticate with. Tokens are revoked when the User leaves any of them.
+	EmbedGroups bool
 }
 
 type userService struct {
-	repo Repository
+	repo   Repository
+	mailer mail.Mailer
+	perms  *auth.Resolver
+	policy auth.Policy
+	cfg    Config
 }
 
 // NewBasicService constructs a UserService for api access.
-func NewBasicService(repo Repository) (UserService, error) {
+func NewBasicService(repo Repository, mailer mail.Mailer, cfg Config) (UserService, error) {
 	if repo == nil {
 		return nil, errors.New("repo can't be nil")
 	}
+	if mailer == nil {
+		return nil, errors.New("mailer can't be nil")
+	}
+
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultIssuer
+	}
+	if cfg.AccessTokenTTL == 0 {
+		cfg.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if cfg.RefreshTokenTTL == 0 {
+		cfg.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	if cfg.AuthorizationCodeTTL == 0 {
+		cfg.AuthorizationCodeTTL = DefaultAuthorizationCodeTTL
+	}
+	if cfg.ResetTokenTTL == 0 {
+		cfg.ResetTokenTTL = DefaultResetTokenTTL
+	}
+	if cfg.VerificationTokenTTL == 0 {
+		cfg.VerificationTokenTTL = DefaultVerificationTokenTTL
+	}
+	if cfg.ImpersonationTTL == 0 {
+		cfg.ImpersonationTTL = DefaultImpersonationTTL
+	}
+	if cfg.PermissionsTTL == 0 {
+		cfg.PermissionsTTL = DefaultPermissionsTTL
+	}
+	if cfg.Denylist == nil {
+		cfg.Denylist = auth.NewMemoryDenylist()
+	}
+
+	perms := auth.NewResolver(rolePermissions(repo), cfg.PermissionsTTL)
+	policies := []auth.Policy{auth.ScopePolicy{}, auth.PermissionPolicy{Resolver: perms}}
+	if cfg.Policy != nil {
+		policies = append(policies, cfg.Policy)
+	}
 
 	return userService{
-		repo: repo,
+		repo:   repo,
+		mailer: mailer,
+		perms:  perms,
+		policy: auth.NewEvaluator(cfg.DecisionLog, policies...),
+		cfg:    cfg,
 	}, nil
 }
 
-// New returns a UserService with instrumentation features.
-func New(repo Repository, requestCount metrics.Counter, requestLatency metrics.Histogram) (UserService, error) {
-	us, err := NewBasicService(repo)
+// New returns a UserService with auditing and instrumentation features.
+func New(repo Repository, mailer mail.Mailer, cfg Config, requestCount metrics.Counter, requestLatency metrics.Histogram) (UserService, error) {
+	us, err := NewBasicService(repo, mailer, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating service")
+	}
+
+	us, err = NewAuditingDecorator(repo, us, cfg.AuditKey, cfg.Log)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating service")
 	}
@@ -72,11 +352,19 @@ func New(repo Repository, requestCount metrics.Counter, requestLatency metrics.H
 	return us, nil
 }
 
-// Create creates a new user, generating a password hash.
+// Create creates a new user, generating a password hash. Users that sign up
+// by themselves belong to the default Organization.
 func (us userService) Create(ctx context.Context, traceID string, nur NewUserRequest, now time.Time) (User, error) {
 	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.create")
 	defer span.End()
 
+	return us.create(ctx, DefaultTenantID, nur, now)
+}
+
+// create saves a new User of the tenant with the default role and sends
+// them a token to verify their email. Failing to send it doesn't fail the
+// creation.
+func (us userService) create(ctx context.Context, tenantID string, nur NewUserRequest, now time.Time) (User, error) {
 	isInUse, err := us.repo.CheckEmailInUse(ctx, nur.Email)
 	if err != nil {
 		return User{}, errors.Wrap(err, "creating user")
@@ -93,12 +381,13 @@ func (us userService) Create(ctx context.Context, traceID string, nur NewUserReq
 
 	u := User{
 		ID:           uuid.New().String(),
+		TenantID:     tenantID,
 		Name:         nur.Name,
 		LastName:     nur.LastName,
 		Email:        nur.Email,
 		Country:      nur.Country,
 		PasswordHash: hash,
-		Roles:        nur.Roles,
+		Roles:        []string{auth.DefaultRole},
 	}
 
 	saved, err := us.repo.Create(ctx, u, now)
@@ -106,39 +395,516 @@ func (us userService) Create(ctx context.Context, traceID string, nur NewUserReq
 		return User{}, errors.Wrap(err, "inserting user")
 	}
 
+	// The User is saved already, so failing here would only keep them from
+	// signing up again. They can ask for the token to be resent.
+	if err := us.sendVerification(ctx, saved, now); err != nil {
+		us.logf("service: ERROR: %v", err)
+	}
+
 	return saved, nil
 }
 
 // Update allows a client to update certain fields of a saved User.
-func (us userService) Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, now time.Time) error {
+// If version isn't zero, the User is only updated if it's still at that version.
+// The version the User is at after the update is returned.
+func (us userService) Update(ctx context.Context, traceID string, claims auth.Claims, userID string, uur UpdateUserRequest, version int, now time.Time) (int, error) {
 	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.update")
 	defer span.End()
 
-	u, err := us.GetByID(ctx, traceID, claims, userID)
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersWrite, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := us.repo.Update(ctx, u.TenantID, u.ID, uur.Name, uur.LastName, uur.Country, version, now)
+	if err != nil {
+		switch err {
+		case ErrNotFound, ErrConflict:
+			return 0, err
+		default:
+			return 0, errors.Wrap(err, "updating user")
+		}
+	}
+
+	return updated, nil
+}
+
+// Patch allows a client to update only some fields of a saved User.
+// If version isn't zero, the User is only updated if it's still at that version.
+// The version the User is at after the patch is returned.
+func (us userService) Patch(ctx context.Context, traceID string, claims auth.Claims, userID string, pur PatchUserRequest, version int, now time.Time) (int, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.patch")
+	defer span.End()
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersWrite, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if pur.Empty() {
+		if version != 0 && version != u.Version {
+			return 0, ErrConflict
+		}
+		return u.Version, nil
+	}
+
+	patched, err := us.repo.Patch(ctx, u.TenantID, u.ID, pur, version, now)
+	if err != nil {
+		switch err {
+		case ErrNotFound, ErrConflict:
+			return 0, err
+		default:
+			return 0, errors.Wrap(err, "patching user")
+		}
+	}
+
+	return patched, nil
+}
+
+// ChangePassword replaces the password of a User. Users must provide their
+// current password, while admins can reset the password of other Users
+// without it. Tokens issued before the change stop being valid.
+func (us userService) ChangePassword(ctx context.Context, traceID string, claims auth.Claims, userID string, cpr ChangePasswordRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.changePassword")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersWrite, userID)
 	if err != nil {
 		return err
 	}
 
-	if err = us.repo.Update(ctx, u.ID, uur.Name, uur.LastName, uur.Country, now); err != nil {
-		return errors.Wrap(err, "updating user")
+	if err := checkCurrentPassword(claims, u, cpr.CurrentPassword); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(cpr.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "generating password hash")
+	}
+
+	if err := us.repo.UpdatePassword(ctx, u.TenantID, u.ID, hash, now); err != nil {
+		switch err {
+		case ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "changing password of user %s", u.ID)
+		}
 	}
 
 	return nil
 }
 
-// Delete deletes a User by its ID.
-func (us userService) Delete(ctx context.Context, traceID string, claims auth.Claims, userID string) error {
-	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.delete")
+// checkCurrentPassword verifies the current password of a User before a
+// sensitive change. Admins acting on other Users may omit it.
+func checkCurrentPassword(claims auth.Claims, u User, password string) error {
+	if claims.Subject != u.ID && password == "" {
+		return nil
+	}
+	if err := bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)); err != nil {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// ForgotPassword creates a single-use token to reset the password of the User
+// with the given email and mails it to them. Unknown emails are ignored and
+// the token is mailed in the background so callers can't tell whether an
+// account exists.
+func (us userService) ForgotPassword(ctx context.Context, traceID string, email string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.forgotPassword")
 	defer span.End()
 
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
+	u, err := us.repo.GetByEmail(ctx, email)
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			return nil
+		default:
+			return errors.Wrap(err, "searching for user by email")
+		}
+	}
+
+	// The token is issued in the background so known emails take as long to
+	// answer as unknown ones.
+	us.background(ctx, "sending reset token", func(ctx context.Context) error {
+		return us.sendResetToken(ctx, u, now)
+	})
+
+	return nil
+}
+
+// sendResetToken creates a token to reset the password of the User and mails
+// it to them.
+func (us userService) sendResetToken(ctx context.Context, u User, now time.Time) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := now.Add(us.cfg.ResetTokenTTL)
+	if err := us.repo.CreateResetToken(ctx, u.ID, hash, expiresAt, now); err != nil {
+		return errors.Wrapf(err, "creating reset token for user %s", u.ID)
+	}
+
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the following token to choose a new password: %s\n\n"+
+			"It expires at %s. If you didn't ask to reset your password you can ignore this email.\n",
+			token, expiresAt.UTC().Format(time.RFC1123)),
+	}
+	if err := us.mailer.Send(ctx, msg); err != nil {
+		return errors.Wrapf(err, "sending reset token to user %s", u.ID)
+	}
+
+	return nil
+}
+
+// background queues a job in the Worker of the service, if it has one, or
+// runs it right away otherwise. Either way its errors are logged instead of
+// returned.
+func (us userService) background(ctx context.Context, name string, job func(ctx context.Context) error) {
+	if us.cfg.Worker == nil {
+		if err := job(ctx); err != nil {
+			us.logf("service: ERROR: %s: %v", name, err)
+		}
+		return
+	}
+
+	if err := us.cfg.Worker.Enqueue(name, job); err != nil {
+		us.logf("service: ERROR: %s: %v", name, err)
+	}
+}
+
+// logf writes to the log of the service, if it has one.
+func (us userService) logf(format string, v ...interface{}) {
+	if us.cfg.Log != nil {
+		us.cfg.Log.Printf(format, v...)
+	}
+}
+
+// ResetPassword replaces the password of the User a reset token was issued
+// to. The token and any other pending token of the User can't be used again.
+func (us userService) ResetPassword(ctx context.Context, traceID string, rpr ResetPasswordRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.resetPassword")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(rpr.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "generating password hash")
+	}
+
+	if err := us.repo.ResetPassword(ctx, hashToken(rpr.Token), hash, now); err != nil {
+		switch err {
+		case ErrInvalidResetToken:
+			return err
+		default:
+			return errors.Wrap(err, "resetting password")
+		}
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the email of a User as verified using the token sent to it.
+func (us userService) VerifyEmail(ctx context.Context, traceID string, token string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.verifyEmail")
+	defer span.End()
+
+	if err := us.repo.VerifyEmail(ctx, hashToken(token), now); err != nil {
+		switch err {
+		case ErrInvalidVerificationToken:
+			return err
+		default:
+			return errors.Wrap(err, "verifying email")
+		}
+	}
+
+	return nil
+}
+
+// ResendVerification sends a new verification token to the User with the
+// given email. Unknown and already verified emails are ignored and the token
+// is mailed in the background so callers can't tell whether an account exists.
+func (us userService) ResendVerification(ctx context.Context, traceID string, email string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.resendVerification")
+	defer span.End()
+
+	u, err := us.repo.GetByEmail(ctx, email)
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			return nil
+		default:
+			return errors.Wrap(err, "searching for user by email")
+		}
+	}
+
+	if u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	// The token is issued in the background so known emails take as long to
+	// answer as unknown ones, and failing to mail it isn't seen by callers.
+	us.background(ctx, "sending verification token", func(ctx context.Context) error {
+		return us.sendVerification(ctx, u, now)
+	})
+
+	return nil
+}
+
+// sendVerification creates a token to verify the current email of a User
+// and mails it to them.
+func (us userService) sendVerification(ctx context.Context, u User, now time.Time) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := now.Add(us.cfg.VerificationTokenTTL)
+	if err := us.repo.CreateVerificationToken(ctx, u.ID, u.Email, hash, expiresAt, now); err != nil {
+		return errors.Wrapf(err, "creating verification token for user %s", u.ID)
+	}
+
+	msg := mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Use the following token to verify your email: %s\n\n"+
+			"It expires at %s.\n", token, expiresAt.UTC().Format(time.RFC1123)),
+	}
+	if err := us.mailer.Send(ctx, msg); err != nil {
+		return errors.Wrap(err, "sending verification token")
+	}
+
+	return nil
+}
+
+// RequestEmailChange starts the change of the email of a User. A token to
+// confirm the change is sent to the new email and a notice to the current
+// one. The email isn't changed until the token is redeemed.
+func (us userService) RequestEmailChange(ctx context.Context, traceID string, claims auth.Claims, userID string, cer ChangeEmailRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.requestEmailChange")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersWrite, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkCurrentPassword(claims, u, cer.CurrentPassword); err != nil {
+		return err
+	}
+
+	isInUse, err := us.repo.CheckEmailInUse(ctx, cer.Email)
+	if err != nil {
+		return errors.Wrap(err, "checking email")
+	}
+	if isInUse {
+		return ErrDuplicatedEmail
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := now.Add(us.cfg.VerificationTokenTTL)
+	if err := us.repo.CreateEmailChangeToken(ctx, u.ID, u.Email, cer.Email, hash, expiresAt, now); err != nil {
+		return errors.Wrapf(err, "creating email change token for user %s", u.ID)
+	}
+
+	confirmation := mail.Message{
+		To:      cer.Email,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf("Use the following token to confirm your new email: %s\n\n"+
+			"It expires at %s.\n", token, expiresAt.UTC().Format(time.RFC1123)),
+	}
+	if err := us.mailer.Send(ctx, confirmation); err != nil {
+		return errors.Wrap(err, "sending email change token")
+	}
+
+	notice := mail.Message{
+		To:      u.Email,
+		Subject: "Your email is about to change",
+		Body: fmt.Sprintf("A change of the email of your account to %s was requested. "+
+			"If you didn't request it, change your password and contact support.\n", cer.Email),
+	}
+	if err := us.mailer.Send(ctx, notice); err != nil {
+		return errors.Wrap(err, "sending email change notice")
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange redeems an email change token and replaces the email
+// of its User with the confirmed one.
+func (us userService) ConfirmEmailChange(ctx context.Context, traceID string, token string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.confirmEmailChange")
+	defer span.End()
+
+	if err := us.repo.ChangeEmail(ctx, hashToken(token), now); err != nil {
+		switch err {
+		case ErrInvalidEmailChangeToken, ErrDuplicatedEmail:
+			return err
+		default:
+			return errors.Wrap(err, "changing email")
+		}
+	}
+
+	return nil
+}
+
+// UpdateRoles replaces the roles of a User and records which roles were
+// granted and revoked by the admin. Admins can't give up their own permission
+// to manage roles so there's always someone able to do it, nor grant roles
+// that reach across tenants unless they can do it themselves.
+func (us userService) UpdateRoles(ctx context.Context, traceID string, claims auth.Claims, userID string, urr UpdateRolesRequest, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.updateRoles")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermRolesManage, auth.Attributes{auth.AttrType: resourceUser, auth.AttrID: userID}); err != nil {
+		return err
+	}
+
+	roles := make([]string, 0, len(urr.Roles))
+	want := make(map[string]bool, len(urr.Roles))
+	for _, role := range urr.Roles {
+		exists, err := us.perms.RoleExists(ctx, role)
+		if err != nil {
+			return errors.Wrap(err, "checking roles")
+		}
+		if !exists {
+			return ErrUnknownRole
+		}
+		if !want[role] {
+			want[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	granted, err := us.perms.Permissions(ctx, roles)
+	if err != nil {
+		return errors.Wrap(err, "resolving permissions")
+	}
+	if err := us.checkCrossTenant(ctx, claims, granted); err != nil {
+		return err
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	u, err := us.repo.GetByID(ctx, tenant, userID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "searching for user %q", userID)
+		}
+	}
+
+	if u.ID == claims.Subject && !granted.Has(auth.PermRolesManage) {
 		return ErrForbidden
 	}
 
-	if err := us.repo.Delete(ctx, userID); err != nil {
+	rc := RoleChange{
+		ID:          uuid.New().String(),
+		UserID:      u.ID,
+		ActorID:     claims.Subject,
+		Granted:     []string{},
+		Revoked:     []string{},
+		DateCreated: now.UTC(),
+	}
+	has := make(map[string]bool, len(u.Roles))
+	for _, role := range u.Roles {
+		has[role] = true
+		if !want[role] {
+			rc.Revoked = append(rc.Revoked, role)
+		}
+	}
+	for _, role := range roles {
+		if !has[role] {
+			rc.Granted = append(rc.Granted, role)
+		}
+	}
+
+	if len(rc.Granted) == 0 && len(rc.Revoked) == 0 {
+		return nil
+	}
+
+	if err := us.repo.UpdateRoles(ctx, u.TenantID, u.ID, roles, u.Version, rc); err != nil {
+		switch err {
+		case ErrNotFound, ErrConflict:
+			return err
+		default:
+			return errors.Wrapf(err, "updating roles of user %s", u.ID)
+		}
+	}
+
+	return nil
+}
+
+// QueryRoleChanges retrieves the changes made to the roles of a User, most
+// recent first. Only admins are allowed to see them.
+func (us userService) QueryRoleChanges(ctx context.Context, traceID string, claims auth.Claims, userID string) ([]RoleChange, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryRoleChanges")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermRolesManage, auth.Attributes{auth.AttrType: resourceUser, auth.AttrID: userID}); err != nil {
+		return nil, err
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := us.repo.QueryRoleChanges(ctx, tenant, userID)
+	if err != nil {
 		switch err {
 		case ErrInvalidID:
-			return ErrInvalidID
+			return nil, err
+		default:
+			return nil, errors.Wrapf(err, "querying role changes of user %s", userID)
+		}
+	}
+
+	return changes, nil
+}
+
+// Delete deletes a User by its ID. The User can be restored until it's purged.
+// If version isn't zero, the User is only deleted if it's still at that version.
+func (us userService) Delete(ctx context.Context, traceID string, claims auth.Claims, userID string, version int, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.delete")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersDelete, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := us.repo.Delete(ctx, u.TenantID, u.ID, version, now); err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound, ErrConflict:
+			return err
 		default:
 			return errors.Wrapf(err, "deleting user %s", userID)
 		}
@@ -147,30 +913,170 @@ func (us userService) Delete(ctx context.Context, traceID string, claims auth.Cl
 	return nil
 }
 
-// GetByID retrieves a User by its ID.
-func (us userService) GetByID(ctx context.Context, traceID string, claims auth.Claims, userID string) (User, error) {
-	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.getById")
+// Restore undoes the deletion of a User that hasn't been purged yet.
+// Only admins are allowed to restore Users.
+func (us userService) Restore(ctx context.Context, traceID string, claims auth.Claims, userID string, now time.Time) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.restore")
 	defer span.End()
 
-	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
-		return User{}, ErrForbidden
+	if claims.Impersonated() {
+		return ErrImpersonating
 	}
 
-	u, err := us.repo.GetByID(ctx, userID)
+	if err := us.authorize(ctx, traceID, claims, auth.PermUsersDelete, auth.Attributes{auth.AttrType: resourceUser, auth.AttrID: userID}); err != nil {
+		return err
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
 	if err != nil {
+		return err
+	}
+
+	if err := us.repo.Restore(ctx, tenant, userID, now); err != nil {
 		switch err {
-		case ErrInvalidID:
-			return User{}, ErrInvalidID
-		case ErrNotFound:
-			return User{}, ErrNotFound
+		case ErrInvalidID, ErrNotFound, ErrDuplicatedEmail:
+			return err
 		default:
-			return User{}, errors.Wrapf(err, "searching for user %q", userID)
+			return errors.Wrapf(err, "restoring user %s", userID)
 		}
 	}
 
+	return nil
+}
+
+// GetByID retrieves a User by its ID.
+func (us userService) GetByID(ctx context.Context, traceID string, claims auth.Claims, userID string) (User, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.getById")
+	defer span.End()
+
+	u, err := us.authorizeUser(ctx, traceID, claims, auth.PermUsersRead, userID)
+	if err != nil {
+		return User{}, err
+	}
+
 	return u, nil
 }
 
+// Query retrieves a page of Users matching the filter, ordered as requested.
+// Only admins are allowed to list Users.
+func (us userService) Query(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, orderBy OrderBy, page, rowsPerPage int) (QueryResult, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.query")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermUsersRead, auth.Attributes{auth.AttrType: resourceUser}); err != nil {
+		return QueryResult{}, err
+	}
+
+	if err := orderBy.validate(); err != nil {
+		return QueryResult{}, err
+	}
+
+	if page < 1 || rowsPerPage < 1 || rowsPerPage > MaxRowsPerPage {
+		return QueryResult{}, ErrInvalidPagination
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	users, total, err := us.repo.Query(ctx, tenant, filter, orderBy, page, rowsPerPage)
+	if err != nil {
+		return QueryResult{}, errors.Wrap(err, "querying users")
+	}
+
+	qr := QueryResult{
+		Items:       users,
+		Total:       total,
+		Page:        page,
+		RowsPerPage: rowsPerPage,
+	}
+
+	// Following pages can be seeked when ordering by the creation date.
+	if orderBy.Field == OrderByDateCreated && len(users) > 0 && page*rowsPerPage < total {
+		qr.Next = cursorAfter(users, orderBy.Direction)
+	}
+
+	return qr, nil
+}
+
+// QueryAfter retrieves the page of Users following the Cursor, using the
+// same order the Cursor was generated with. Only admins are allowed to list Users.
+func (us userService) QueryAfter(ctx context.Context, traceID string, claims auth.Claims, filter QueryFilter, after Cursor, rowsPerPage int) (QueryResult, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryAfter")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermUsersRead, auth.Attributes{auth.AttrType: resourceUser}); err != nil {
+		return QueryResult{}, err
+	}
+
+	if _, err := uuid.Parse(after.ID); err != nil || after.DateCreated.IsZero() {
+		return QueryResult{}, ErrInvalidCursor
+	}
+	if after.Direction != ASC && after.Direction != DESC {
+		return QueryResult{}, ErrInvalidCursor
+	}
+
+	if rowsPerPage < 1 || rowsPerPage > MaxRowsPerPage {
+		return QueryResult{}, ErrInvalidPagination
+	}
+
+	// Request an extra row to find out if there's a following page.
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	users, err := us.repo.QueryAfter(ctx, tenant, filter, after, rowsPerPage+1)
+	if err != nil {
+		return QueryResult{}, errors.Wrap(err, "querying users")
+	}
+
+	qr := QueryResult{
+		Items:       users,
+		RowsPerPage: rowsPerPage,
+	}
+
+	if len(users) > rowsPerPage {
+		qr.Items = users[:rowsPerPage]
+		qr.Next = cursorAfter(qr.Items, after.Direction)
+	}
+
+	return qr, nil
+}
+
+// Search retrieves the Users that best match the search terms by name, last
+// name or email, ordered by relevance. Only admins are allowed to search Users.
+func (us userService) Search(ctx context.Context, traceID string, claims auth.Claims, terms string, limit int) ([]User, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.search")
+	defer span.End()
+
+	if err := us.authorize(ctx, traceID, claims, auth.PermUsersRead, auth.Attributes{auth.AttrType: resourceUser}); err != nil {
+		return nil, err
+	}
+
+	terms = strings.TrimSpace(terms)
+	if terms == "" {
+		return nil, ErrEmptySearch
+	}
+
+	if limit < 1 || limit > MaxRowsPerPage {
+		return nil, ErrInvalidPagination
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := us.repo.Search(ctx, tenant, terms, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "searching users")
+	}
+
+	return users, nil
+}
+
 // Authenticate finds a user by their email and verifies their password. On
 // success it returns a Claims representing the user. The claims can be
 // used to generate a token for future authentication.
@@ -190,17 +1096,193 @@ func (us userService) Authenticate(ctx context.Context, traceID string, now time
 		return auth.Claims{}, ErrAuthenticationFailure
 	}
 
+	if us.cfg.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		return auth.Claims{}, ErrEmailNotVerified
+	}
+
+	return us.newClaims(ctx, u, now, us.cfg.AccessTokenTTL)
+}
+
+// newClaims returns the Claims representing a User for the given period of time.
+func (us userService) newClaims(ctx context.Context, u User, now time.Time, ttl time.Duration) (auth.Claims, error) {
 	claims := auth.Claims{
 		// TODO: Customize claims to suit the project.
 		StandardClaims: jwt.StandardClaims{
-			Issuer:    "service template",
+			Id:        uuid.New().String(),
+			Issuer:    us.cfg.Issuer,
 			Subject:   u.ID,
 			Audience:  "clients",
-			ExpiresAt: now.Add(time.Hour).Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
 			IssuedAt:  now.Unix(),
 		},
-		Roles: u.Roles,
+		TenantID:      u.TenantID,
+		Roles:         u.Roles,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		IssuedAtMicro: now.UnixNano() / int64(time.Microsecond),
+	}
+
+	if us.cfg.EmbedGroups {
+		var err error
+		if claims.Groups, err = us.groupIDs(ctx, u); err != nil {
+			return auth.Claims{}, err
+		}
 	}
 
 	return claims, nil
 }
+
+// ValidateClaims checks that the User a token was issued to still exists, that
+// the token was issued after the last time the User changed their password
+// and that the User still has the roles and groups of the token. The actor of
+// impersonated sessions is checked as well.
+func (us userService) ValidateClaims(ctx context.Context, claims auth.Claims) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.validateClaims")
+	defer span.End()
+
+	if claims.Machine {
+		return us.validateServiceAccount(ctx, claims)
+	}
+
+	u, err := us.repo.GetByID(ctx, claims.TenantID, claims.Subject)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return auth.ErrTokenRevoked
+		default:
+			return errors.Wrapf(err, "searching for user %q", claims.Subject)
+		}
+	}
+
+	if u.PasswordChangedAt != nil && claims.IssuedBefore(*u.PasswordChangedAt) {
+		return auth.ErrTokenRevoked
+	}
+
+	// Revoked roles take effect before the token expires.
+	current := auth.Claims{Roles: u.Roles}
+	for _, role := range claims.Roles {
+		if !current.Authorized(role) {
+			return auth.ErrTokenRevoked
+		}
+	}
+
+	// Impersonated sessions end as soon as the actor can't impersonate anymore.
+	if claims.Impersonated() {
+		if err := us.validateActor(ctx, claims); err != nil {
+			return err
+		}
+	}
+
+	// So do removed group memberships.
+	if len(claims.Groups) > 0 {
+		ids, err := us.groupIDs(ctx, u)
+		if err != nil {
+			return err
+		}
+		member := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			member[id] = true
+		}
+		for _, id := range claims.Groups {
+			if !member[id] {
+				return auth.ErrTokenRevoked
+			}
+		}
+	}
+
+	return nil
+}
+
+// Permissions resolves the permissions granted by the roles of the claims,
+// or by their scope for service accounts.
+func (us userService) Permissions(ctx context.Context, claims auth.Claims) (auth.Permissions, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.permissions")
+	defer span.End()
+
+	perms, err := us.perms.Granted(ctx, claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving permissions")
+	}
+
+	return perms, nil
+}
+
+// These are the types of the resources authorization requests are made for.
+const (
+	resourceUser       = "user"
+	resourceRole       = "role"
+	resourceAuditEvent = "audit_event"
+)
+
+// authorize checks that the policy allows the action over the resource.
+// When a policy is configured the attributes of the User making the request
+// are loaded so rules can refer to them. Service accounts have none.
+func (us userService) authorize(ctx context.Context, traceID string, claims auth.Claims, action string, resource auth.Attributes) error {
+	req := auth.Request{
+		TraceID:  traceID,
+		Claims:   claims,
+		Action:   action,
+		Resource: resource,
+	}
+
+	if us.cfg.Policy != nil && !claims.Machine {
+		u, err := us.repo.GetByID(ctx, claims.TenantID, claims.Subject)
+		switch err {
+		case nil:
+			req.Subject = auth.Attributes{"country": u.Country}
+		case ErrInvalidID, ErrNotFound:
+		default:
+			return errors.Wrapf(err, "searching for user %q", claims.Subject)
+		}
+	}
+
+	d, err := us.policy.Evaluate(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "evaluating policy")
+	}
+	if d.Effect != auth.Allow {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// authorizeUser retrieves a User of the tenant of the claims and checks that
+// the policy allows the action over it. Missing Users, including the ones of
+// other tenants, are only reported to the ones allowed to perform the action,
+// so their existence isn't disclosed.
+func (us userService) authorizeUser(ctx context.Context, traceID string, claims auth.Claims, action, userID string) (User, error) {
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return User{}, err
+	}
+
+	u, err := us.repo.GetByID(ctx, tenant, userID)
+	switch err {
+	case nil:
+		if err := us.authorize(ctx, traceID, claims, action, userAttributes(u)); err != nil {
+			return User{}, err
+		}
+		return u, nil
+	case ErrInvalidID, ErrNotFound:
+		if err := us.authorize(ctx, traceID, claims, action, userAttributes(User{ID: userID, TenantID: claims.TenantID})); err != nil {
+			return User{}, err
+		}
+		return User{}, err
+	default:
+		return User{}, errors.Wrapf(err, "searching for user %q", userID)
+	}
+}
+
+// userAttributes returns the attributes of a User as the resource of an
+// authorization request. Users own themselves.
+func userAttributes(u User) auth.Attributes {
+	attrs := auth.Attributes{
+		auth.AttrType:     resourceUser,
+		auth.AttrID:       u.ID,
+		auth.AttrOwnerID:  u.ID,
+		auth.AttrTenantID: u.TenantID,
+	}
+	if u.Country != "" {
+		attrs["country"] = u.Country
+	}
+	return attrs
+}
@@ -2,6 +2,9 @@ package service_test
 
 import (
 	"context"
+	"io/ioutil"
+	"log"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,7 +23,7 @@ func TestCreate(t *testing.T) {
 	t.Cleanup(teardown)
 
 	ur, _ := repository.NewRepository(db)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
 	ctx := context.Background()
 	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
 	traceID := "00000000-0000-0000-0000-000000000000"
@@ -31,7 +34,6 @@ func TestCreate(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -50,7 +52,8 @@ func TestCreate(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleUser},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
 		}
 
 		saved, err := us.GetByID(ctx, traceID, claims, u.ID)
@@ -69,7 +72,6 @@ func TestCreate(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Fernández",
 			Country:         "Colombia",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -87,7 +89,7 @@ func TestUpdate(t *testing.T) {
 	t.Cleanup(teardown)
 
 	ur, _ := repository.NewRepository(db)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
 	ctx := context.Background()
 	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
 	traceID := "00000000-0000-0000-0000-000000000000"
@@ -98,7 +100,6 @@ func TestUpdate(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -122,10 +123,11 @@ func TestUpdate(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleUser},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
 		}
 
-		if err = us.Update(ctx, traceID, claims, u.ID, uur, now); err != nil {
+		if _, err = us.Update(ctx, traceID, claims, u.ID, uur, 0, now); err != nil {
 			tt.Fatalf("\t%s\tUpdate() err = %v, want %v", tests.Failed, err, nil)
 		}
 	})
@@ -139,7 +141,8 @@ func TestUpdate(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleAdmin},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleAdmin},
 		}
 
 		uur := service.UpdateUserRequest{
@@ -148,10 +151,58 @@ func TestUpdate(t *testing.T) {
 			Country:  "Argentina",
 		}
 
-		if err := us.Update(ctx, traceID, claims, "invalidID", uur, now); err != service.ErrInvalidID {
+		if _, err := us.Update(ctx, traceID, claims, "invalidID", uur, 0, now); err != service.ErrInvalidID {
 			tt.Fatalf("\t%s\tUpdate() err = %v, want %v", tests.Failed, err, service.ErrInvalidID)
 		}
 	})
+
+	t.Run("Conflict case", func(tt *testing.T) {
+		nur := service.NewUserRequest{
+			Name:            "Santiago",
+			Email:           "conflict@santiago.com",
+			LastName:        "Hernández",
+			Country:         "Argentina",
+			Password:        "password",
+			PasswordConfirm: "password",
+		}
+
+		u, err := us.Create(ctx, traceID, nur, now)
+		if err != nil {
+			tt.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		claims := auth.Claims{
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    "service template",
+				Subject:   u.ID,
+				Audience:  "clients",
+				ExpiresAt: now.Add(time.Hour).Unix(),
+				IssuedAt:  now.Unix(),
+			},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
+		}
+
+		uur := service.UpdateUserRequest{
+			Name:     "Jorgito",
+			LastName: "Porcel",
+			Country:  "Argentina",
+		}
+
+		// The first update based on the current version succeeds...
+		version, err := us.Update(ctx, traceID, claims, u.ID, uur, u.Version, now)
+		if err != nil {
+			tt.Fatalf("\t%s\tUpdate() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if version != u.Version+1 {
+			tt.Fatalf("\t%s\tUpdate() version = %d, want %d", tests.Failed, version, u.Version+1)
+		}
+
+		// ...while the second one is based on a stale version.
+		if _, err := us.Update(ctx, traceID, claims, u.ID, uur, u.Version, now); err != service.ErrConflict {
+			tt.Fatalf("\t%s\tUpdate() err = %v, want %v", tests.Failed, err, service.ErrConflict)
+		}
+	})
 }
 
 func TestDelete(t *testing.T) {
@@ -159,7 +210,7 @@ func TestDelete(t *testing.T) {
 	t.Cleanup(teardown)
 
 	ur, _ := repository.NewRepository(db)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
 	ctx := context.Background()
 	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
 	traceID := "00000000-0000-0000-0000-000000000000"
@@ -170,7 +221,6 @@ func TestDelete(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleUser},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -189,11 +239,12 @@ func TestDelete(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleUser},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
 		}
 
 		// Deleting User...
-		if err = us.Delete(ctx, traceID, claims, u.ID); err != nil {
+		if err = us.Delete(ctx, traceID, claims, u.ID, 0, now); err != nil {
 			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, nil)
 		}
 
@@ -210,7 +261,6 @@ func TestDelete(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -229,11 +279,12 @@ func TestDelete(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleAdmin},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleAdmin},
 		}
 
 		// Deleting User using Claims with Admin role...
-		if err = us.Delete(ctx, traceID, claims, u.ID); err != nil {
+		if err = us.Delete(ctx, traceID, claims, u.ID, 0, now); err != nil {
 			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, nil)
 		}
 
@@ -250,7 +301,6 @@ func TestDelete(t *testing.T) {
 			Email:           "santiago@santiago.com",
 			LastName:        "Hernández",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -269,11 +319,12 @@ func TestDelete(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleUser},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
 		}
 
 		// Attempting to delete User with invalid claims...
-		if err = us.Delete(ctx, traceID, claims, u.ID); err != service.ErrForbidden {
+		if err = us.Delete(ctx, traceID, claims, u.ID, 0, now); err != service.ErrForbidden {
 			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
 		}
 
@@ -295,11 +346,12 @@ func TestDelete(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleAdmin},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleAdmin},
 		}
 
 		// Attempting to delete User with invalid ID...
-		if err := us.Delete(ctx, traceID, claims, "invalidID"); err != service.ErrInvalidID {
+		if err := us.Delete(ctx, traceID, claims, "invalidID", 0, now); err != service.ErrInvalidID {
 			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, service.ErrInvalidID)
 		}
 	})
@@ -314,7 +366,7 @@ func TestGetByID(t *testing.T) {
 	}
 
 	ur, _ := repository.NewRepository(db)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
 	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
 	ctx := context.Background()
 	traceID := "00000000-0000-0000-0000-000000000000"
@@ -324,7 +376,6 @@ func TestGetByID(t *testing.T) {
 		Email:           "santiago@santiago.com",
 		LastName:        "Hernández",
 		Country:         "Argentina",
-		Roles:           []string{auth.RoleAdmin},
 		Password:        "password",
 		PasswordConfirm: "password",
 	}
@@ -345,7 +396,8 @@ func TestGetByID(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleUser},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
 		}
 
 		// Retrieving User...
@@ -368,7 +420,8 @@ func TestGetByID(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleAdmin},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleAdmin},
 		}
 
 		// Retrieving User...
@@ -391,7 +444,8 @@ func TestGetByID(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleUser},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleUser},
 		}
 
 		// Retrieving User...
@@ -409,7 +463,8 @@ func TestGetByID(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleAdmin},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleAdmin},
 		}
 
 		// Retrieving User...
@@ -427,7 +482,8 @@ func TestGetByID(t *testing.T) {
 				ExpiresAt: now.Add(time.Hour).Unix(),
 				IssuedAt:  now.Unix(),
 			},
-			Roles: []string{auth.RoleAdmin},
+			TenantID: service.DefaultTenantID,
+			Roles:    []string{auth.RoleAdmin},
 		}
 
 		// Retrieving User...
@@ -445,7 +501,7 @@ func TestAuthenticate(t *testing.T) {
 	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
 	traceID := "00000000-0000-0000-0000-000000000000"
 	ur, _ := repository.NewRepository(db)
-	us, _ := service.NewBasicService(ur)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
 
 	t.Run("Success case", func(tt *testing.T) {
 		nur := service.NewUserRequest{
@@ -453,7 +509,6 @@ func TestAuthenticate(t *testing.T) {
 			LastName:        "Hernández",
 			Email:           "santiago@santiago.com",
 			Country:         "Argentina",
-			Roles:           []string{auth.RoleAdmin},
 			Password:        "password",
 			PasswordConfirm: "password",
 		}
@@ -470,7 +525,8 @@ func TestAuthenticate(t *testing.T) {
 
 		// Compare Claims returned by Authenticate() with expected Claims...
 		want := auth.Claims{
-			Roles: u.Roles,
+			TenantID: u.TenantID,
+			Roles:    u.Roles,
 			StandardClaims: jwt.StandardClaims{
 				Issuer:    "service template",
 				Subject:   u.ID,
@@ -491,3 +547,1122 @@ func TestAuthenticate(t *testing.T) {
 		}
 	})
 }
+
+func TestQuery(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatalf("\tschema.Seed() err = %v", err)
+	}
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   tests.AdminID,
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleAdmin},
+	}
+
+	t.Run("Success case (filter by country)", func(tt *testing.T) {
+		filter := service.QueryFilter{Country: "Algeria"}
+
+		qr, err := us.Query(ctx, traceID, claims, filter, service.DefaultOrderBy, 1, 10)
+		if err != nil {
+			tt.Fatalf("\t%s\tQuery() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if qr.Total != 1 || len(qr.Items) != 1 || qr.Items[0].ID != tests.UserID {
+			tt.Fatalf("\t%s\tQuery() result = %+v, want only user %s", tests.Failed, qr, tests.UserID)
+		}
+	})
+
+	t.Run("Success case (pagination)", func(tt *testing.T) {
+		orderBy := service.OrderBy{Field: service.OrderByEmail, Direction: service.ASC}
+
+		qr, err := us.Query(ctx, traceID, claims, service.QueryFilter{}, orderBy, 2, 1)
+		if err != nil {
+			tt.Fatalf("\t%s\tQuery() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if qr.Total != 2 || len(qr.Items) != 1 || qr.Items[0].ID != tests.UserID {
+			tt.Fatalf("\t%s\tQuery() result = %+v, want only user %s", tests.Failed, qr, tests.UserID)
+		}
+	})
+
+	t.Run("Invalid pagination", func(tt *testing.T) {
+		_, err := us.Query(ctx, traceID, claims, service.QueryFilter{}, service.DefaultOrderBy, 0, 10)
+		if err != service.ErrInvalidPagination {
+			tt.Fatalf("\t%s\tQuery() err = %v, want %v", tests.Failed, err, service.ErrInvalidPagination)
+		}
+	})
+
+	t.Run("Forbidden", func(tt *testing.T) {
+		claims := claims
+		claims.Roles = []string{auth.RoleUser}
+
+		_, err := us.Query(ctx, traceID, claims, service.QueryFilter{}, service.DefaultOrderBy, 1, 10)
+		if err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tQuery() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+	})
+}
+
+func TestParseOrderBy(t *testing.T) {
+	tt := []struct {
+		name    string
+		in      string
+		want    service.OrderBy
+		wantErr error
+	}{
+		{"Empty", "", service.DefaultOrderBy, nil},
+		{"Field only", "email", service.OrderBy{Field: service.OrderByEmail, Direction: service.ASC}, nil},
+		{"Field and direction", "country,desc", service.OrderBy{Field: service.OrderByCountry, Direction: service.DESC}, nil},
+		{"Unknown field", "password_hash", service.OrderBy{}, service.ErrInvalidOrderBy},
+		{"Unknown direction", "email,sideways", service.OrderBy{}, service.ErrInvalidOrderBy},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := service.ParseOrderBy(tc.in)
+			if err != tc.wantErr {
+				t.Fatalf("\t%s\tParseOrderBy() err = %v, want %v", tests.Failed, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Fatalf("\t%s\tParseOrderBy() = %+v, want %+v", tests.Failed, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatalf("\tschema.Seed() err = %v", err)
+	}
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   tests.AdminID,
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleAdmin},
+	}
+
+	t.Run("Success case (typo in last name)", func(tt *testing.T) {
+		users, err := us.Search(ctx, traceID, claims, "Admn", 10)
+		if err != nil {
+			tt.Fatalf("\t%s\tSearch() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if len(users) == 0 || users[0].ID != tests.AdminID {
+			tt.Fatalf("\t%s\tSearch() users = %+v, want %s first", tests.Failed, users, tests.AdminID)
+		}
+	})
+
+	t.Run("Success case (partial email)", func(tt *testing.T) {
+		users, err := us.Search(ctx, traceID, claims, "user@exam", 10)
+		if err != nil {
+			tt.Fatalf("\t%s\tSearch() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if len(users) == 0 || users[0].ID != tests.UserID {
+			tt.Fatalf("\t%s\tSearch() users = %+v, want %s first", tests.Failed, users, tests.UserID)
+		}
+	})
+
+	t.Run("Empty search", func(tt *testing.T) {
+		if _, err := us.Search(ctx, traceID, claims, "  ", 10); err != service.ErrEmptySearch {
+			tt.Fatalf("\t%s\tSearch() err = %v, want %v", tests.Failed, err, service.ErrEmptySearch)
+		}
+	})
+
+	t.Run("Forbidden", func(tt *testing.T) {
+		claims := claims
+		claims.Roles = []string{auth.RoleUser}
+
+		if _, err := us.Search(ctx, traceID, claims, "Admin", 10); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tSearch() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+	})
+}
+
+func TestRestore(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   uuid.New().String(),
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleAdmin},
+	}
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+
+	t.Run("Success case", func(tt *testing.T) {
+		u, err := us.Create(ctx, traceID, nur, now)
+		if err != nil {
+			tt.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		if err := us.Delete(ctx, traceID, claims, u.ID, 0, now); err != nil {
+			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		// Deleting it again fails since it's already deleted...
+		if err := us.Delete(ctx, traceID, claims, u.ID, 0, now); err != service.ErrNotFound {
+			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, service.ErrNotFound)
+		}
+
+		if err := us.Restore(ctx, traceID, claims, u.ID, now); err != nil {
+			tt.Fatalf("\t%s\tRestore() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		if _, err := us.GetByID(ctx, traceID, claims, u.ID); err != nil {
+			tt.Fatalf("\t%s\tGetByID() err = %v, want %v", tests.Failed, err, nil)
+		}
+	})
+
+	t.Run("Email taken while deleted", func(tt *testing.T) {
+		nur := nur
+		nur.Email = "taken@santiago.com"
+
+		u, err := us.Create(ctx, traceID, nur, now)
+		if err != nil {
+			tt.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if err := us.Delete(ctx, traceID, claims, u.ID, 0, now); err != nil {
+			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		// The email of a deleted User can be used by a new one...
+		if _, err := us.Create(ctx, traceID, nur, now); err != nil {
+			tt.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		// ...so the deleted User can't be restored anymore.
+		if err := us.Restore(ctx, traceID, claims, u.ID, now); err != service.ErrDuplicatedEmail {
+			tt.Fatalf("\t%s\tRestore() err = %v, want %v", tests.Failed, err, service.ErrDuplicatedEmail)
+		}
+	})
+
+	t.Run("Purged user", func(tt *testing.T) {
+		nur := nur
+		nur.Email = "purged@santiago.com"
+
+		u, err := us.Create(ctx, traceID, nur, now)
+		if err != nil {
+			tt.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if err := us.Delete(ctx, traceID, claims, u.ID, 0, now); err != nil {
+			tt.Fatalf("\t%s\tDelete() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		purger, err := service.NewPurger(ur, log.New(ioutil.Discard, "", 0), 24*time.Hour, time.Hour)
+		if err != nil {
+			tt.Fatalf("\t%s\tNewPurger() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		// Nothing is purged within the retention period...
+		if n, err := purger.Purge(ctx, now.Add(time.Hour)); err != nil || n != 0 {
+			tt.Fatalf("\t%s\tPurge() = %d, %v, want 0, nil", tests.Failed, n, err)
+		}
+
+		// ...but everything deleted is purged after it.
+		if n, err := purger.Purge(ctx, now.Add(25*time.Hour)); err != nil || n == 0 {
+			tt.Fatalf("\t%s\tPurge() = %d, %v, want purged users", tests.Failed, n, err)
+		}
+
+		if err := us.Restore(ctx, traceID, claims, u.ID, now); err != service.ErrNotFound {
+			tt.Fatalf("\t%s\tRestore() err = %v, want %v", tests.Failed, err, service.ErrNotFound)
+		}
+	})
+
+	t.Run("Forbidden", func(tt *testing.T) {
+		claims := claims
+		claims.Roles = []string{auth.RoleUser}
+
+		if err := us.Restore(ctx, traceID, claims, uuid.New().String(), now); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tRestore() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+	})
+}
+
+func TestImpersonating(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   uuid.New().String(),
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleSuperAdmin},
+		Actor:    &auth.Actor{Subject: uuid.New().String()},
+	}
+	id := uuid.New().String()
+
+	destructive := map[string]func() error{
+		"Restore":              func() error { return us.Restore(ctx, traceID, claims, id, now) },
+		"DeleteRole":           func() error { return us.DeleteRole(ctx, traceID, claims, "AUDITOR") },
+		"DeleteGroup":          func() error { return us.DeleteGroup(ctx, traceID, claims, id) },
+		"RemoveGroupMember":    func() error { return us.RemoveGroupMember(ctx, traceID, claims, id, id) },
+		"DeleteOrganization":   func() error { return us.DeleteOrganization(ctx, traceID, claims, id) },
+		"DeleteClient":         func() error { return us.DeleteClient(ctx, traceID, claims, id) },
+		"DeleteServiceAccount": func() error { return us.DeleteServiceAccount(ctx, traceID, claims, id) },
+	}
+	for name, fn := range destructive {
+		if err := fn(); err != service.ErrImpersonating {
+			t.Fatalf("\t%s\t%s() err = %v, want %v", tests.Failed, name, err, service.ErrImpersonating)
+		}
+	}
+	t.Logf("\t%s\tShould not be able to perform destructive operations while impersonating.", tests.Success)
+}
+
+func TestChangePassword(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+
+	u, err := us.Create(ctx, traceID, nur, now)
+	if err != nil {
+		t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   u.ID,
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleUser},
+	}
+
+	admin := claims
+	admin.Subject = uuid.New().String()
+	admin.Roles = []string{auth.RoleAdmin}
+
+	t.Run("Incorrect current password", func(tt *testing.T) {
+		cpr := service.ChangePasswordRequest{
+			CurrentPassword: "wrong",
+			Password:        "new password",
+			PasswordConfirm: "new password",
+		}
+		if err := us.ChangePassword(ctx, traceID, claims, u.ID, cpr, now); err != service.ErrIncorrectPassword {
+			tt.Fatalf("\t%s\tChangePassword() err = %v, want %v", tests.Failed, err, service.ErrIncorrectPassword)
+		}
+		tt.Logf("\t%s\tShould require the current password.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		cpr := service.ChangePasswordRequest{
+			CurrentPassword: "password",
+			Password:        "new password",
+			PasswordConfirm: "new password",
+		}
+		changed := now.Add(time.Minute)
+		if err := us.ChangePassword(ctx, traceID, claims, u.ID, cpr, changed); err != nil {
+			tt.Fatalf("\t%s\tChangePassword() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		if _, err := us.Authenticate(ctx, traceID, changed, nur.Email, "password"); err != service.ErrAuthenticationFailure {
+			tt.Fatalf("\t%s\tAuthenticate() err = %v, want %v", tests.Failed, err, service.ErrAuthenticationFailure)
+		}
+		newClaims, err := us.Authenticate(ctx, traceID, changed, nur.Email, "new password")
+		if err != nil {
+			tt.Fatalf("\t%s\tAuthenticate() err = %v, want %v", tests.Failed, err, nil)
+		}
+		tt.Logf("\t%s\tShould be able to authenticate with the new password.", tests.Success)
+
+		if err := us.ValidateClaims(ctx, claims); err != auth.ErrTokenRevoked {
+			tt.Fatalf("\t%s\tValidateClaims() err = %v, want %v", tests.Failed, err, auth.ErrTokenRevoked)
+		}
+		if err := us.ValidateClaims(ctx, newClaims); err != nil {
+			tt.Fatalf("\t%s\tValidateClaims() err = %v, want %v", tests.Failed, err, nil)
+		}
+		tt.Logf("\t%s\tShould only accept tokens issued after the change.", tests.Success)
+	})
+
+	t.Run("Admin reset", func(tt *testing.T) {
+		cpr := service.ChangePasswordRequest{
+			Password:        "reset password",
+			PasswordConfirm: "reset password",
+		}
+		if err := us.ChangePassword(ctx, traceID, admin, u.ID, cpr, now.Add(2*time.Minute)); err != nil {
+			tt.Fatalf("\t%s\tChangePassword() err = %v, want %v", tests.Failed, err, nil)
+		}
+		tt.Logf("\t%s\tShould allow admins to reset the password without the current one.", tests.Success)
+	})
+
+	t.Run("Forbidden case", func(tt *testing.T) {
+		other := claims
+		other.Subject = uuid.New().String()
+		cpr := service.ChangePasswordRequest{
+			Password:        "other password",
+			PasswordConfirm: "other password",
+		}
+		if err := us.ChangePassword(ctx, traceID, other, u.ID, cpr, now); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tChangePassword() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		tt.Logf("\t%s\tShould not allow changing the password of other users.", tests.Success)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	mailer := &tests.Mailer{}
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, mailer, service.Config{ResetTokenTTL: time.Hour})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	if _, err := us.Create(ctx, traceID, nur, now); err != nil {
+		t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	// resetToken requests a reset token and extracts it from the email.
+	resetToken := func(tt *testing.T) string {
+		tt.Helper()
+		sent := mailer.Count(nur.Email)
+		if err := us.ForgotPassword(ctx, traceID, nur.Email, now); err != nil {
+			tt.Fatalf("\t%s\tForgotPassword() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if _, ok := mailer.Await(nur.Email, sent); !ok {
+			tt.Fatalf("\t%s\tShould send the reset token to %s.", tests.Failed, nur.Email)
+		}
+		return mailedToken(tt, mailer, nur.Email)
+	}
+
+	t.Run("Unknown email", func(tt *testing.T) {
+		if err := us.ForgotPassword(ctx, traceID, "unknown@santiago.com", now); err != nil {
+			tt.Fatalf("\t%s\tForgotPassword() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if _, ok := mailer.Last("unknown@santiago.com"); ok {
+			tt.Fatalf("\t%s\tShould not send emails to unknown addresses.", tests.Failed)
+		}
+		tt.Logf("\t%s\tShould ignore unknown emails.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		token := resetToken(tt)
+		rpr := service.ResetPasswordRequest{
+			Token:           token,
+			Password:        "new password",
+			PasswordConfirm: "new password",
+		}
+		if err := us.ResetPassword(ctx, traceID, rpr, now.Add(time.Minute)); err != nil {
+			tt.Fatalf("\t%s\tResetPassword() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if _, err := us.Authenticate(ctx, traceID, now, nur.Email, "new password"); err != nil {
+			tt.Fatalf("\t%s\tAuthenticate() err = %v, want %v", tests.Failed, err, nil)
+		}
+		tt.Logf("\t%s\tShould be able to reset the password.", tests.Success)
+
+		if err := us.ResetPassword(ctx, traceID, rpr, now.Add(time.Minute)); err != service.ErrInvalidResetToken {
+			tt.Fatalf("\t%s\tResetPassword() err = %v, want %v", tests.Failed, err, service.ErrInvalidResetToken)
+		}
+		tt.Logf("\t%s\tShould not be able to use a token twice.", tests.Success)
+	})
+
+	t.Run("Expired token", func(tt *testing.T) {
+		rpr := service.ResetPasswordRequest{
+			Token:           resetToken(tt),
+			Password:        "expired password",
+			PasswordConfirm: "expired password",
+		}
+		if err := us.ResetPassword(ctx, traceID, rpr, now.Add(2*time.Hour)); err != service.ErrInvalidResetToken {
+			tt.Fatalf("\t%s\tResetPassword() err = %v, want %v", tests.Failed, err, service.ErrInvalidResetToken)
+		}
+		tt.Logf("\t%s\tShould not be able to use an expired token.", tests.Success)
+	})
+
+	t.Run("Superseded token", func(tt *testing.T) {
+		first, second := resetToken(tt), resetToken(tt)
+		rpr := service.ResetPasswordRequest{
+			Token:           second,
+			Password:        "second password",
+			PasswordConfirm: "second password",
+		}
+		if err := us.ResetPassword(ctx, traceID, rpr, now.Add(time.Minute)); err != nil {
+			tt.Fatalf("\t%s\tResetPassword() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		rpr.Token = first
+		if err := us.ResetPassword(ctx, traceID, rpr, now.Add(time.Minute)); err != service.ErrInvalidResetToken {
+			tt.Fatalf("\t%s\tResetPassword() err = %v, want %v", tests.Failed, err, service.ErrInvalidResetToken)
+		}
+		tt.Logf("\t%s\tShould invalidate the other pending tokens after a reset.", tests.Success)
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	mailer := &tests.Mailer{}
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, mailer, service.Config{RequireVerifiedEmail: true})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	if _, err := us.Create(ctx, traceID, nur, now); err != nil {
+		t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+	}
+	token := mailedToken(t, mailer, nur.Email)
+	t.Logf("\t%s\tShould send a verification token on creation.", tests.Success)
+
+	if _, err := us.Authenticate(ctx, traceID, now, nur.Email, nur.Password); err != service.ErrEmailNotVerified {
+		t.Fatalf("\t%s\tAuthenticate() err = %v, want %v", tests.Failed, err, service.ErrEmailNotVerified)
+	}
+	t.Logf("\t%s\tShould not authenticate users with unverified emails.", tests.Success)
+
+	if err := us.VerifyEmail(ctx, traceID, "invalid", now); err != service.ErrInvalidVerificationToken {
+		t.Fatalf("\t%s\tVerifyEmail() err = %v, want %v", tests.Failed, err, service.ErrInvalidVerificationToken)
+	}
+	if err := us.VerifyEmail(ctx, traceID, token, now.Add(25*time.Hour)); err != service.ErrInvalidVerificationToken {
+		t.Fatalf("\t%s\tVerifyEmail() err = %v, want %v", tests.Failed, err, service.ErrInvalidVerificationToken)
+	}
+	t.Logf("\t%s\tShould reject invalid and expired tokens.", tests.Success)
+
+	if err := us.ResendVerification(ctx, traceID, nur.Email, now); err != nil {
+		t.Fatalf("\t%s\tResendVerification() err = %v, want %v", tests.Failed, err, nil)
+	}
+	token = mailedToken(t, mailer, nur.Email)
+	if err := us.VerifyEmail(ctx, traceID, token, now); err != nil {
+		t.Fatalf("\t%s\tVerifyEmail() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if err := us.VerifyEmail(ctx, traceID, token, now); err != service.ErrInvalidVerificationToken {
+		t.Fatalf("\t%s\tVerifyEmail() err = %v, want %v", tests.Failed, err, service.ErrInvalidVerificationToken)
+	}
+	t.Logf("\t%s\tShould verify the email with a resent token only once.", tests.Success)
+
+	claims, err := us.Authenticate(ctx, traceID, now, nur.Email, nur.Password)
+	if err != nil {
+		t.Fatalf("\t%s\tAuthenticate() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if !claims.EmailVerified {
+		t.Fatalf("\t%s\tShould include the verification state in the claims.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould include the verification state in the claims.", tests.Success)
+
+	sent := len(mailer.Messages)
+	if err := us.ResendVerification(ctx, traceID, nur.Email, now); err != nil {
+		t.Fatalf("\t%s\tResendVerification() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if len(mailer.Messages) != sent {
+		t.Fatalf("\t%s\tShould not send tokens to verified emails.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould not send tokens to verified emails.", tests.Success)
+}
+
+func TestChangeEmail(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	mailer := &tests.Mailer{}
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, mailer, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	u, err := us.Create(ctx, traceID, nur, now)
+	if err != nil {
+		t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   u.ID,
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleUser},
+	}
+
+	t.Run("Incorrect current password", func(tt *testing.T) {
+		cer := service.ChangeEmailRequest{Email: "new@santiago.com", CurrentPassword: "wrong"}
+		if err := us.RequestEmailChange(ctx, traceID, claims, u.ID, cer, now); err != service.ErrIncorrectPassword {
+			tt.Fatalf("\t%s\tRequestEmailChange() err = %v, want %v", tests.Failed, err, service.ErrIncorrectPassword)
+		}
+		tt.Logf("\t%s\tShould require the current password.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		cer := service.ChangeEmailRequest{Email: "new@santiago.com", CurrentPassword: "password"}
+		if err := us.RequestEmailChange(ctx, traceID, claims, u.ID, cer, now); err != nil {
+			tt.Fatalf("\t%s\tRequestEmailChange() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if _, ok := mailer.Last(nur.Email); !ok {
+			tt.Fatalf("\t%s\tShould send a notice to the current email.", tests.Failed)
+		}
+		token := mailedToken(tt, mailer, cer.Email)
+
+		saved, err := us.GetByID(ctx, traceID, claims, u.ID)
+		if err != nil {
+			tt.Fatalf("\t%s\tGetByID() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if saved.Email != nur.Email {
+			tt.Fatalf("\t%s\tShould keep the email until the change is confirmed : got %s", tests.Failed, saved.Email)
+		}
+		tt.Logf("\t%s\tShould keep the email until the change is confirmed.", tests.Success)
+
+		if err := us.ConfirmEmailChange(ctx, traceID, token, now); err != nil {
+			tt.Fatalf("\t%s\tConfirmEmailChange() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if saved, _ = us.GetByID(ctx, traceID, claims, u.ID); saved.Email != cer.Email || saved.EmailVerifiedAt == nil {
+			tt.Fatalf("\t%s\tShould change to the verified new email : got %+v", tests.Failed, saved)
+		}
+		tt.Logf("\t%s\tShould change to the verified new email.", tests.Success)
+
+		if err := us.ConfirmEmailChange(ctx, traceID, token, now); err != service.ErrInvalidEmailChangeToken {
+			tt.Fatalf("\t%s\tConfirmEmailChange() err = %v, want %v", tests.Failed, err, service.ErrInvalidEmailChangeToken)
+		}
+		tt.Logf("\t%s\tShould not be able to use a token twice.", tests.Success)
+	})
+
+	t.Run("Email taken before confirmation", func(tt *testing.T) {
+		cer := service.ChangeEmailRequest{Email: "taken@santiago.com", CurrentPassword: "password"}
+		if err := us.RequestEmailChange(ctx, traceID, claims, u.ID, cer, now); err != nil {
+			tt.Fatalf("\t%s\tRequestEmailChange() err = %v, want %v", tests.Failed, err, nil)
+		}
+		token := mailedToken(tt, mailer, cer.Email)
+
+		other := nur
+		other.Email = cer.Email
+		if _, err := us.Create(ctx, traceID, other, now); err != nil {
+			tt.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		if err := us.ConfirmEmailChange(ctx, traceID, token, now); err != service.ErrDuplicatedEmail {
+			tt.Fatalf("\t%s\tConfirmEmailChange() err = %v, want %v", tests.Failed, err, service.ErrDuplicatedEmail)
+		}
+		tt.Logf("\t%s\tShould not change to an email taken in the meantime.", tests.Success)
+	})
+}
+
+func TestUpdateRoles(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	u, err := us.Create(ctx, traceID, nur, now)
+	if err != nil {
+		t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if diff := cmp.Diff([]string(u.Roles), []string{auth.DefaultRole}); diff != "" {
+		t.Fatalf("\t%s\tShould sign up with the default role. Diff:\n%s", tests.Failed, diff)
+	}
+	t.Logf("\t%s\tShould sign up with the default role.", tests.Success)
+
+	admin := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   uuid.New().String(),
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleAdmin},
+	}
+
+	t.Run("Forbidden case", func(tt *testing.T) {
+		claims := admin
+		claims.Subject = u.ID
+		claims.Roles = []string{auth.RoleUser}
+		urr := service.UpdateRolesRequest{Roles: []string{auth.RoleAdmin}}
+		if err := us.UpdateRoles(ctx, traceID, claims, u.ID, urr, now); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tUpdateRoles() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		tt.Logf("\t%s\tShould not allow users to grant themselves roles.", tests.Success)
+	})
+
+	t.Run("Unknown role", func(tt *testing.T) {
+		urr := service.UpdateRolesRequest{Roles: []string{"ROOT"}}
+		if err := us.UpdateRoles(ctx, traceID, admin, u.ID, urr, now); err != service.ErrUnknownRole {
+			tt.Fatalf("\t%s\tUpdateRoles() err = %v, want %v", tests.Failed, err, service.ErrUnknownRole)
+		}
+		tt.Logf("\t%s\tShould only accept known roles.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		urr := service.UpdateRolesRequest{Roles: []string{auth.RoleAdmin}}
+		if err := us.UpdateRoles(ctx, traceID, admin, u.ID, urr, now); err != nil {
+			tt.Fatalf("\t%s\tUpdateRoles() err = %v, want %v", tests.Failed, err, nil)
+		}
+
+		saved, err := us.GetByID(ctx, traceID, admin, u.ID)
+		if err != nil {
+			tt.Fatalf("\t%s\tGetByID() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if diff := cmp.Diff([]string(saved.Roles), urr.Roles); diff != "" {
+			tt.Fatalf("\t%s\tShould replace the roles. Diff:\n%s", tests.Failed, diff)
+		}
+		tt.Logf("\t%s\tShould replace the roles.", tests.Success)
+
+		changes, err := us.QueryRoleChanges(ctx, traceID, admin, u.ID)
+		if err != nil {
+			tt.Fatalf("\t%s\tQueryRoleChanges() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if len(changes) != 1 || changes[0].ActorID != admin.Subject ||
+			!cmp.Equal([]string(changes[0].Granted), []string{auth.RoleAdmin}) ||
+			!cmp.Equal([]string(changes[0].Revoked), []string{auth.RoleUser}) {
+			tt.Fatalf("\t%s\tShould record who granted and revoked which roles : got %+v", tests.Failed, changes)
+		}
+		tt.Logf("\t%s\tShould record who granted and revoked which roles.", tests.Success)
+
+		old := admin
+		old.Subject = u.ID
+		old.Roles = []string{auth.RoleUser}
+		if err := us.ValidateClaims(ctx, old); err != auth.ErrTokenRevoked {
+			tt.Fatalf("\t%s\tValidateClaims() err = %v, want %v", tests.Failed, err, auth.ErrTokenRevoked)
+		}
+		tt.Logf("\t%s\tShould reject tokens with revoked roles.", tests.Success)
+	})
+
+	t.Run("Revoking own admin role", func(tt *testing.T) {
+		claims := admin
+		claims.Subject = u.ID
+		urr := service.UpdateRolesRequest{Roles: []string{auth.RoleUser}}
+		if err := us.UpdateRoles(ctx, traceID, claims, u.ID, urr, now); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tUpdateRoles() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		tt.Logf("\t%s\tShould not allow admins to revoke their own admin role.", tests.Success)
+	})
+}
+
+func TestRoles(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	admin := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   uuid.New().String(),
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleSuperAdmin},
+	}
+	user := admin
+	user.Roles = []string{auth.RoleUser}
+
+	t.Run("Forbidden case", func(tt *testing.T) {
+		nr := service.NewRoleRequest{Name: "SUPPORT", Permissions: []string{auth.PermUsersRead}}
+		if _, err := us.CreateRole(ctx, traceID, user, nr, now); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tCreateRole() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		tt.Logf("\t%s\tShould not allow users without roles:manage to create roles.", tests.Success)
+	})
+
+	t.Run("Forbidden case (tenant admin)", func(tt *testing.T) {
+		claims := admin
+		claims.Roles = []string{auth.RoleAdmin}
+		nr := service.NewRoleRequest{Name: "SUPPORT", Permissions: []string{auth.PermUsersRead}}
+		if _, err := us.CreateRole(ctx, traceID, claims, nr, now); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tCreateRole() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		tt.Logf("\t%s\tShould only allow users acting across tenants to define roles.", tests.Success)
+	})
+
+	t.Run("Unknown permission", func(tt *testing.T) {
+		nr := service.NewRoleRequest{Name: "SUPPORT", Permissions: []string{"users:admin"}}
+		if _, err := us.CreateRole(ctx, traceID, admin, nr, now); err != service.ErrUnknownPermission {
+			tt.Fatalf("\t%s\tCreateRole() err = %v, want %v", tests.Failed, err, service.ErrUnknownPermission)
+		}
+		tt.Logf("\t%s\tShould only accept known permissions.", tests.Success)
+	})
+
+	t.Run("Built-in role", func(tt *testing.T) {
+		urr := service.UpdateRoleRequest{Permissions: []string{auth.PermUsersRead}}
+		if err := us.UpdateRole(ctx, traceID, admin, auth.RoleAdmin, urr); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tUpdateRole() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		if err := us.DeleteRole(ctx, traceID, admin, auth.RoleUser); err != service.ErrForbidden {
+			tt.Fatalf("\t%s\tDeleteRole() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+		}
+		tt.Logf("\t%s\tShould not allow built-in roles to be changed.", tests.Success)
+	})
+
+	t.Run("Success case", func(tt *testing.T) {
+		nr := service.NewRoleRequest{Name: "SUPPORT", Permissions: []string{auth.PermUsersRead}}
+		if _, err := us.CreateRole(ctx, traceID, admin, nr, now); err != nil {
+			tt.Fatalf("\t%s\tCreateRole() err = %v, want %v", tests.Failed, err, nil)
+		}
+		tt.Logf("\t%s\tShould create a role.", tests.Success)
+
+		if _, err := us.CreateRole(ctx, traceID, admin, nr, now); err != service.ErrDuplicatedRole {
+			tt.Fatalf("\t%s\tCreateRole() err = %v, want %v", tests.Failed, err, service.ErrDuplicatedRole)
+		}
+		tt.Logf("\t%s\tShould not create a role twice.", tests.Success)
+
+		support := user
+		support.Roles = []string{"SUPPORT"}
+		perms, err := us.Permissions(ctx, support)
+		if err != nil {
+			tt.Fatalf("\t%s\tPermissions() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if !perms.Has(auth.PermUsersRead) || perms.Has(auth.PermUsersWrite) {
+			tt.Fatalf("\t%s\tShould grant the permissions of the role : got %v", tests.Failed, perms)
+		}
+		tt.Logf("\t%s\tShould grant the permissions of the role.", tests.Success)
+
+		urr := service.UpdateRoleRequest{Permissions: []string{auth.PermUsersRead, auth.PermUsersWrite}}
+		if err := us.UpdateRole(ctx, traceID, admin, "SUPPORT", urr); err != nil {
+			tt.Fatalf("\t%s\tUpdateRole() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if perms, _ = us.Permissions(ctx, support); !perms.Has(auth.PermUsersWrite) {
+			tt.Fatalf("\t%s\tShould grant the updated permissions right away : got %v", tests.Failed, perms)
+		}
+		tt.Logf("\t%s\tShould grant the updated permissions right away.", tests.Success)
+
+		if err := us.DeleteRole(ctx, traceID, admin, "SUPPORT"); err != nil {
+			tt.Fatalf("\t%s\tDeleteRole() err = %v, want %v", tests.Failed, err, nil)
+		}
+		if err := us.DeleteRole(ctx, traceID, admin, "SUPPORT"); err != service.ErrNotFound {
+			tt.Fatalf("\t%s\tDeleteRole() err = %v, want %v", tests.Failed, err, service.ErrNotFound)
+		}
+		tt.Logf("\t%s\tShould delete the role.", tests.Success)
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	policy, err := auth.ParsePolicy(strings.NewReader(`{"rules":[{
+		"name": "support-reads-own-country",
+		"effect": "allow",
+		"actions": ["users:read"],
+		"roles": ["SUPPORT"],
+		"conditions": [{"attribute": "country", "equals_subject": "country"}]
+	}]}`))
+	if err != nil {
+		t.Fatalf("\t%s\tParsePolicy() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{Policy: policy})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	admin := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service template",
+			Subject:   uuid.New().String(),
+			Audience:  "clients",
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID: service.DefaultTenantID,
+		Roles:    []string{auth.RoleSuperAdmin},
+	}
+	nr := service.NewRoleRequest{Name: "SUPPORT", Permissions: []string{auth.Self(auth.PermUsersRead)}}
+	if _, err := us.CreateRole(ctx, traceID, admin, nr, now); err != nil {
+		t.Fatalf("\t%s\tCreateRole() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	create := func(email, country string) service.User {
+		nur := service.NewUserRequest{
+			Name:            "Santiago",
+			Email:           email,
+			LastName:        "Hernández",
+			Country:         country,
+			Password:        "password",
+			PasswordConfirm: "password",
+		}
+		u, err := us.Create(ctx, traceID, nur, now)
+		if err != nil {
+			t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+		}
+		return u
+	}
+	staff := create("support@example.com", "Argentina")
+	local := create("local@example.com", "Argentina")
+	foreign := create("foreign@example.com", "Chile")
+
+	support := admin
+	support.Subject = staff.ID
+	support.Roles = []string{"SUPPORT"}
+
+	if _, err := us.GetByID(ctx, traceID, support, local.ID); err != nil {
+		t.Fatalf("\t%s\tGetByID() err = %v, want %v", tests.Failed, err, nil)
+	}
+	t.Logf("\t%s\tShould allow support staff to read Users of their country.", tests.Success)
+
+	if _, err := us.GetByID(ctx, traceID, support, foreign.ID); err != service.ErrForbidden {
+		t.Fatalf("\t%s\tGetByID() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+	}
+	t.Logf("\t%s\tShould not allow support staff to read Users of other countries.", tests.Success)
+
+	if _, err := us.GetByID(ctx, traceID, support, uuid.New().String()); err != service.ErrForbidden {
+		t.Fatalf("\t%s\tGetByID() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+	}
+	t.Logf("\t%s\tShould not disclose missing Users.", tests.Success)
+
+	uur := service.UpdateUserRequest{Name: "Santi", LastName: "Hernández", Country: "Argentina"}
+	if _, err := us.Update(ctx, traceID, support, local.ID, uur, 0, now); err != service.ErrForbidden {
+		t.Fatalf("\t%s\tUpdate() err = %v, want %v", tests.Failed, err, service.ErrForbidden)
+	}
+	t.Logf("\t%s\tShould deny actions no rule allows.", tests.Success)
+}
+
+// mailedToken extracts the token from the last email sent to an address.
+func mailedToken(t *testing.T, mailer *tests.Mailer, to string) string {
+	t.Helper()
+
+	msg, ok := mailer.Last(to)
+	if !ok {
+		t.Fatalf("\t%s\tShould send a token to %s.", tests.Failed, to)
+	}
+	body := msg.Body[strings.Index(msg.Body, ": ")+2:]
+	return body[:strings.Index(body, "\n")]
+}
+
+func TestEmbedGroups(t *testing.T) {
+	_, db, teardown := tests.NewUnit(t)
+	t.Cleanup(teardown)
+
+	ur, _ := repository.NewRepository(db)
+	us, _ := service.NewBasicService(ur, &tests.Mailer{}, service.Config{EmbedGroups: true})
+	ctx := context.Background()
+	now := time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "00000000-0000-0000-0000-000000000000"
+
+	nur := service.NewUserRequest{
+		Name:            "Santiago",
+		Email:           "santiago@santiago.com",
+		LastName:        "Hernández",
+		Country:         "Argentina",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	u, err := us.Create(ctx, traceID, nur, now)
+	if err != nil {
+		t.Fatalf("\t%s\tCreate() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	admin := auth.Claims{
+		StandardClaims: jwt.StandardClaims{Subject: uuid.New().String()},
+		TenantID:       service.DefaultTenantID,
+		Roles:          []string{auth.RoleAdmin},
+	}
+
+	g, err := us.CreateGroup(ctx, traceID, admin, service.NewGroupRequest{Name: "Engineering"}, now)
+	if err != nil {
+		t.Fatalf("\t%s\tCreateGroup() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if err := us.AddGroupMember(ctx, traceID, admin, g.ID, u.ID, now); err != nil {
+		t.Fatalf("\t%s\tAddGroupMember() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	claims, err := us.Authenticate(ctx, traceID, now, "santiago@santiago.com", "password")
+	if err != nil {
+		t.Fatalf("\t%s\tAuthenticate() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if diff := cmp.Diff(claims.Groups, []string{g.ID}); diff != "" {
+		t.Fatalf("\t%s\tShould embed the groups of the user in the claims. Diff:\n%s", tests.Failed, diff)
+	}
+	t.Logf("\t%s\tShould embed the groups of the user in the claims.", tests.Success)
+
+	if err := us.ValidateClaims(ctx, claims); err != nil {
+		t.Fatalf("\t%s\tValidateClaims() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if err := us.RemoveGroupMember(ctx, traceID, admin, g.ID, u.ID); err != nil {
+		t.Fatalf("\t%s\tRemoveGroupMember() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if err := us.ValidateClaims(ctx, claims); err != auth.ErrTokenRevoked {
+		t.Fatalf("\t%s\tValidateClaims() err = %v, want %v", tests.Failed, err, auth.ErrTokenRevoked)
+	}
+	t.Logf("\t%s\tShould revoke the token once the user leaves a group.", tests.Success)
+
+	other := admin
+	other.TenantID = uuid.New().String()
+	if err := us.AddGroupMember(ctx, traceID, other, g.ID, u.ID, now); err != service.ErrNotFound {
+		t.Fatalf("\t%s\tAddGroupMember() err = %v, want %v", tests.Failed, err, service.ErrNotFound)
+	}
+	t.Logf("\t%s\tShould not reach the groups of other tenants.", tests.Success)
+}
+
+func TestAuditEventHash(t *testing.T) {
+	e := service.AuditEvent{
+		ID:          "6f3c1c6e-8f0f-4a8e-9d59-7b0bd0b0e3a1",
+		ActorID:     tests.AdminID,
+		Action:      service.AuditUserDelete,
+		TargetID:    tests.UserID,
+		TraceID:     "00000000-0000-0000-0000-000000000000",
+		Changes:     `{}`,
+		DateCreated: time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	hash := e.ComputeHash("")
+	if hash != e.ComputeHash("") {
+		t.Fatalf("\t%s\tShould compute the same hash for the same event.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould compute the same hash for the same event.", tests.Success)
+
+	if hash == e.ComputeHash("previous") {
+		t.Fatalf("\t%s\tShould chain the hash to the previous one.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould chain the hash to the previous one.", tests.Success)
+
+	tampered := e
+	tampered.TargetID = tests.AdminID
+	if hash == tampered.ComputeHash("") {
+		t.Fatalf("\t%s\tShould change the hash when the event is modified.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould change the hash when the event is modified.", tests.Success)
+}
+
+func TestWorker(t *testing.T) {
+	w, err := service.NewWorker(log.New(ioutil.Discard, "", 0), 1, time.Second)
+	if err != nil {
+		t.Fatalf("\t%s\tNewWorker() err = %v, want %v", tests.Failed, err, nil)
+	}
+
+	var deadline bool
+	job := func(ctx context.Context) error {
+		_, deadline = ctx.Deadline()
+		return nil
+	}
+
+	if err := w.Enqueue("first", job); err != nil {
+		t.Fatalf("\t%s\tEnqueue() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if err := w.Enqueue("second", job); err != service.ErrWorkerBusy {
+		t.Fatalf("\t%s\tEnqueue() err = %v, want %v", tests.Failed, err, service.ErrWorkerBusy)
+	}
+	t.Logf("\t%s\tShould bound the pending jobs.", tests.Success)
+
+	go w.Run()
+	if err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("\t%s\tShutdown() err = %v, want %v", tests.Failed, err, nil)
+	}
+	if !deadline {
+		t.Fatalf("\t%s\tShould run the pending jobs with a timeout before shutting down.", tests.Failed)
+	}
+	t.Logf("\t%s\tShould run the pending jobs with a timeout before shutting down.", tests.Success)
+
+	if err := w.Enqueue("third", job); err != service.ErrWorkerStopped {
+		t.Fatalf("\t%s\tEnqueue() err = %v, want %v", tests.Failed, err, service.ErrWorkerStopped)
+	}
+	t.Logf("\t%s\tShould reject jobs once shut down.", tests.Success)
+}
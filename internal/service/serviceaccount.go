@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/santiagoh1997/service-template/internal/auth"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// resourceServiceAccount is the type of the ServiceAccounts authorization
+// requests are made for.
+const resourceServiceAccount = "service_account"
+
+// QueryServiceAccounts retrieves the ServiceAccounts of the tenant of the
+// claims.
+func (us userService) QueryServiceAccounts(ctx context.Context, traceID string, claims auth.Claims) ([]ServiceAccount, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.queryServiceAccounts")
+	defer span.End()
+
+	resource := auth.Attributes{auth.AttrType: resourceServiceAccount, auth.AttrTenantID: claims.TenantID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermServiceAccountsManage, resource); err != nil {
+		return nil, err
+	}
+
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := us.repo.QueryServiceAccounts(ctx, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying service accounts")
+	}
+
+	return accounts, nil
+}
+
+// CreateServiceAccount creates a ServiceAccount in the Organization of the
+// claims and returns it along with its secret, which isn't stored and can't
+// be retrieved again. Service accounts can only be granted the permissions
+// held by whoever creates them.
+func (us userService) CreateServiceAccount(ctx context.Context, traceID string, claims auth.Claims, nsr NewServiceAccountRequest, now time.Time) (ServiceAccount, string, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.createServiceAccount")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ServiceAccount{}, "", ErrImpersonating
+	}
+
+	resource := auth.Attributes{auth.AttrType: resourceServiceAccount, auth.AttrTenantID: claims.TenantID}
+	if err := us.authorize(ctx, traceID, claims, auth.PermServiceAccountsManage, resource); err != nil {
+		return ServiceAccount{}, "", err
+	}
+
+	perms, err := validatePermissions(nsr.Permissions)
+	if err != nil {
+		return ServiceAccount{}, "", err
+	}
+	granted := make(auth.Permissions, len(perms))
+	for _, perm := range perms {
+		if !auth.ServiceAccountPermission(perm) {
+			return ServiceAccount{}, "", ErrServiceAccountPermission
+		}
+		granted[perm] = true
+	}
+
+	held, err := us.perms.Granted(ctx, claims)
+	if err != nil {
+		return ServiceAccount{}, "", errors.Wrap(err, "resolving permissions")
+	}
+	if !held.Includes(granted) {
+		return ServiceAccount{}, "", ErrForbidden
+	}
+
+	secret, hash, err := newToken()
+	if err != nil {
+		return ServiceAccount{}, "", err
+	}
+
+	sa := ServiceAccount{
+		ID:          uuid.New().String(),
+		TenantID:    claims.TenantID,
+		Name:        nsr.Name,
+		SecretHash:  hash,
+		Permissions: perms,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+	if err := us.repo.CreateServiceAccount(ctx, sa); err != nil {
+		return ServiceAccount{}, "", errors.Wrapf(err, "creating service account %s", sa.Name)
+	}
+
+	return sa, secret, nil
+}
+
+// RotateServiceAccountSecret replaces the secret of a ServiceAccount and
+// returns the new one. Tokens issued with the previous secret are revoked.
+func (us userService) RotateServiceAccountSecret(ctx context.Context, traceID string, claims auth.Claims, accountID string, now time.Time) (string, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.rotateServiceAccountSecret")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return "", ErrImpersonating
+	}
+
+	sa, err := us.authorizeServiceAccount(ctx, traceID, claims, auth.PermServiceAccountsManage, accountID)
+	if err != nil {
+		return "", err
+	}
+
+	secret, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := us.repo.UpdateServiceAccountSecret(ctx, sa.TenantID, sa.ID, hash, now); err != nil {
+		switch err {
+		case ErrNotFound:
+			return "", err
+		default:
+			return "", errors.Wrapf(err, "rotating secret of service account %s", accountID)
+		}
+	}
+
+	return secret, nil
+}
+
+// DeleteServiceAccount deletes a ServiceAccount. Its tokens are revoked.
+func (us userService) DeleteServiceAccount(ctx context.Context, traceID string, claims auth.Claims, accountID string) error {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.deleteServiceAccount")
+	defer span.End()
+
+	if claims.Impersonated() {
+		return ErrImpersonating
+	}
+
+	sa, err := us.authorizeServiceAccount(ctx, traceID, claims, auth.PermServiceAccountsManage, accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := us.repo.DeleteServiceAccount(ctx, sa.TenantID, sa.ID); err != nil {
+		switch err {
+		case ErrNotFound:
+			return err
+		default:
+			return errors.Wrapf(err, "deleting service account %s", accountID)
+		}
+	}
+
+	return nil
+}
+
+// ExchangeClientCredentials authenticates a ServiceAccount with its ID and
+// secret and returns the claims of an access token for it. Tokens are
+// granted the permissions requested as their scope, or every permission of
+// the ServiceAccount when none is requested. No refresh token is issued,
+// since the credentials can be exchanged again.
+func (us userService) ExchangeClientCredentials(ctx context.Context, traceID string, tr TokenRequest, now time.Time) (Grant, error) {
+	ctx, span := trace.SpanFromContext(ctx).Tracer().Start(ctx, "business.service.exchangeClientCredentials")
+	defer span.End()
+
+	sa, err := us.repo.GetServiceAccount(ctx, AnyTenant, tr.ClientID)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return Grant{}, ErrInvalidClient
+		default:
+			return Grant{}, errors.Wrapf(err, "searching for service account %q", tr.ClientID)
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(tr.ClientSecret)), []byte(sa.SecretHash)) != 1 {
+		return Grant{}, ErrInvalidClient
+	}
+
+	scopes := strings.Fields(tr.Scope)
+	if len(scopes) == 0 {
+		scopes = sa.Permissions
+	}
+	granted := []string{}
+	for _, scope := range scopes {
+		if !contains(sa.Permissions, scope) {
+			return Grant{}, ErrInvalidScope
+		}
+		granted = appendMissing(granted, scope)
+	}
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
+			Issuer:    us.cfg.Issuer,
+			Subject:   sa.ID,
+			Audience:  "clients",
+			ExpiresAt: now.Add(us.cfg.AccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+		TenantID:      sa.TenantID,
+		Roles:         []string{},
+		ClientID:      sa.ID,
+		Scope:         strings.Join(granted, " "),
+		Machine:       true,
+		IssuedAtMicro: now.UnixNano() / int64(time.Microsecond),
+	}
+
+	return Grant{Claims: claims}, nil
+}
+
+// validateServiceAccount checks that the ServiceAccount of machine claims
+// still exists, that its secret wasn't rotated since the token was issued
+// and that it still holds the permissions of the token.
+func (us userService) validateServiceAccount(ctx context.Context, claims auth.Claims) error {
+	sa, err := us.repo.GetServiceAccount(ctx, claims.TenantID, claims.Subject)
+	if err != nil {
+		switch err {
+		case ErrInvalidID, ErrNotFound:
+			return auth.ErrTokenRevoked
+		default:
+			return errors.Wrapf(err, "searching for service account %q", claims.Subject)
+		}
+	}
+
+	if claims.IssuedBefore(sa.DateUpdated) {
+		return auth.ErrTokenRevoked
+	}
+
+	for _, perm := range strings.Fields(claims.Scope) {
+		if !contains(sa.Permissions, perm) {
+			return auth.ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
+
+// authorizeServiceAccount retrieves a ServiceAccount of the tenant of the
+// claims and checks that the policy allows the action over it.
+func (us userService) authorizeServiceAccount(ctx context.Context, traceID string, claims auth.Claims, action, accountID string) (ServiceAccount, error) {
+	tenant, err := us.tenantOf(ctx, claims)
+	if err != nil {
+		return ServiceAccount{}, err
+	}
+
+	sa, err := us.repo.GetServiceAccount(ctx, tenant, accountID)
+	switch err {
+	case nil:
+		resource := auth.Attributes{auth.AttrType: resourceServiceAccount, auth.AttrID: sa.ID, auth.AttrTenantID: sa.TenantID}
+		if err := us.authorize(ctx, traceID, claims, action, resource); err != nil {
+			return ServiceAccount{}, err
+		}
+		return sa, nil
+	case ErrInvalidID, ErrNotFound:
+		resource := auth.Attributes{auth.AttrType: resourceServiceAccount, auth.AttrID: accountID, auth.AttrTenantID: claims.TenantID}
+		if err := us.authorize(ctx, traceID, claims, action, resource); err != nil {
+			return ServiceAccount{}, err
+		}
+		return ServiceAccount{}, err
+	default:
+		return ServiceAccount{}, errors.Wrapf(err, "searching for service account %q", accountID)
+	}
+}
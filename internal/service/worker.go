@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrWorkerBusy is returned when a job is queued while the Worker has
+	// as many pending jobs as it holds.
+	ErrWorkerBusy = errors.New("too many pending jobs")
+
+	// ErrWorkerStopped is returned when a job is queued after the Worker
+	// was shut down.
+	ErrWorkerStopped = errors.New("worker is shut down")
+)
+
+// Worker runs the work the service does in the background, such as mailing
+// tokens, one job at a time. Jobs wait in a bounded queue and each one is
+// given a timeout. Shutdown waits for the queued jobs to finish.
+type Worker struct {
+	log     *log.Logger
+	timeout time.Duration
+	jobs    chan job
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// job is a unit of work queued in a Worker.
+type job struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// NewWorker constructs a Worker holding up to size pending jobs.
+func NewWorker(log *log.Logger, size int, timeout time.Duration) (*Worker, error) {
+	if log == nil {
+		return nil, errors.New("log can't be nil")
+	}
+	if size <= 0 || timeout <= 0 {
+		return nil, errors.New("size and timeout must be positive")
+	}
+
+	return &Worker{
+		log:     log,
+		timeout: timeout,
+		jobs:    make(chan job, size),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Enqueue queues a job to be run by the Worker. The job isn't waited for,
+// so its errors are logged under the given name instead.
+func (w *Worker) Enqueue(name string, run func(ctx context.Context) error) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return ErrWorkerStopped
+	}
+
+	select {
+	case w.jobs <- job{name: name, run: run}:
+		return nil
+	default:
+		return ErrWorkerBusy
+	}
+}
+
+// Run runs the queued jobs until the Worker is shut down and its queue is
+// drained.
+func (w *Worker) Run() {
+	defer close(w.done)
+
+	for j := range w.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		if err := j.run(ctx); err != nil {
+			w.log.Printf("worker: ERROR: %s: %v", j.name, err)
+		}
+		cancel()
+	}
+}
+
+// Shutdown stops the Worker from accepting jobs and waits for the queued
+// ones to finish or for the context to be done.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.jobs)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "waiting for pending jobs")
+	}
+}
@@ -3,21 +3,27 @@ package tests
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/santiagoh1997/service-template/internal/auth"
 	"github.com/santiagoh1997/service-template/internal/data/schema"
+	"github.com/santiagoh1997/service-template/internal/pkg/cursor"
 	"github.com/santiagoh1997/service-template/internal/pkg/database"
+	"github.com/santiagoh1997/service-template/internal/pkg/mail"
 	"github.com/santiagoh1997/service-template/internal/repository"
 	"github.com/santiagoh1997/service-template/internal/service"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Success and failure markers.
@@ -91,6 +97,8 @@ type Test struct {
 	DB       *sqlx.DB
 	Log      *log.Logger
 	Auth     *auth.Auth
+	Cursors  *cursor.Signer
+	Mailer   *Mailer
 	Metrics  []metrics.Counter
 	KID      string
 	Teardown func()
@@ -115,7 +123,7 @@ func NewIntegration(t *testing.T) *Test {
 	// Build an authenticator using this key lookup function to retrieve
 	// the corresponding public key.
 	kidID := "4754d86b-7a6d-4df5-9c65-224741361492"
-	lookup := func(kid string) (*rsa.PublicKey, error) {
+	lookup := func(kid string) (crypto.PublicKey, error) {
 		switch kid {
 		case kidID:
 			return &privateKey.PublicKey, nil
@@ -127,12 +135,22 @@ func NewIntegration(t *testing.T) *Test {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if err := auth.SetActiveKID(kidID); err != nil {
+		t.Fatal(err)
+	}
+
+	cursors, err := cursor.NewSigner([]byte("00000000000000000000000000000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	test := Test{
 		TraceID:  "00000000-0000-0000-0000-000000000000",
 		DB:       db,
 		Log:      log,
 		Auth:     auth,
+		Cursors:  cursors,
+		Mailer:   &Mailer{},
 		KID:      kidID,
 		t:        t,
 		Teardown: teardown,
@@ -146,7 +164,7 @@ func (test *Test) Token(email, pass string) string {
 	test.t.Log("Generating token for test ...")
 
 	ur, _ := repository.NewRepository(test.DB)
-	u, _ := service.NewBasicService(ur)
+	u, _ := service.NewBasicService(ur, test.Mailer, service.Config{})
 	claims, err := u.Authenticate(context.Background(), test.TraceID, time.Now(), email, pass)
 	if err != nil {
 		test.t.Fatal(err)
@@ -159,3 +177,84 @@ func (test *Test) Token(email, pass string) string {
 
 	return token
 }
+
+// SuperAdminToken creates a User with the super admin role in the default
+// Organization and generates a token for it.
+func (test *Test) SuperAdminToken() string {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+
+	u := service.User{
+		ID:           uuid.New().String(),
+		TenantID:     service.DefaultTenantID,
+		Name:         "Example",
+		LastName:     "Super Admin",
+		Email:        "superadmin@example.com",
+		Country:      "Andorra",
+		Roles:        []string{auth.RoleSuperAdmin, auth.RoleUser},
+		PasswordHash: hash,
+	}
+
+	ur, _ := repository.NewRepository(test.DB)
+	if _, err := ur.Create(context.Background(), u, time.Now()); err != nil {
+		test.t.Fatal(err)
+	}
+
+	return test.Token(u.Email, "password")
+}
+
+// Mailer records the messages sent during a test instead of delivering them.
+type Mailer struct {
+	mu       sync.Mutex
+	Messages []mail.Message
+}
+
+// Send records the message.
+func (m *Mailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Messages = append(m.Messages, msg)
+	return nil
+}
+
+// Last returns the last message sent to the given address.
+func (m *Mailer) Last(to string) (mail.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.Messages) - 1; i >= 0; i-- {
+		if m.Messages[i].To == to {
+			return m.Messages[i], true
+		}
+	}
+	return mail.Message{}, false
+}
+
+// Count returns the number of messages sent to the given address.
+func (m *Mailer) Count(to string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int
+	for _, msg := range m.Messages {
+		if msg.To == to {
+			n++
+		}
+	}
+	return n
+}
+
+// Await waits up to a second for more than n messages to be sent to the given
+// address and returns the last one. It's meant for messages sent in the
+// background.
+func (m *Mailer) Await(to string, n int) (mail.Message, bool) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if m.Count(to) > n {
+			return m.Last(to)
+		}
+	}
+	return mail.Message{}, false
+}